@@ -3,31 +3,96 @@ package account
 import (
 	"net/http"
 	"regexp"
+	"time"
 
 	"github.com/coffemanfp/chat/auth"
 	"github.com/coffemanfp/chat/errors"
 )
 
-// Account is the representation of the common account data
+// AnonymizedName is the name of the deleted accounts once their personal data is removed.
+const AnonymizedName = "Deleted account"
+
+// Account is the representation of the common account data.
+// It's only read from the requests, the responses send its Profile or Details projections.
 type Account struct {
-	ID       int    `json:"id,omitempty"`
-	Nickname string `json:"nickname,omitempty"`
-	Email    string `json:"email,omitempty"`
-	Password string `json:"password,omitempty"`
+	ID         int        `json:"id,omitempty"`
+	Name       string     `json:"name,omitempty"`
+	LastName   string     `json:"last_name,omitempty"`
+	Nickname   string     `json:"nickname,omitempty"`
+	Email      string     `json:"email,omitempty"`
+	Password   string     `json:"password,omitempty"`
+	PictureURL string     `json:"picture_url,omitempty"`
+	CreatedAt  time.Time  `json:"created_at,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+
+	// EmailVerified is true once the account proves it owns its email.
+	EmailVerified bool `json:"email_verified,omitempty"`
+}
+
+// Profile gets the public projection of the account.
+func (a Account) Profile() Profile {
+	return Profile{
+		ID:         a.ID,
+		Nickname:   a.Nickname,
+		Name:       a.Name,
+		LastName:   a.LastName,
+		PictureURL: a.PictureURL,
+	}
+}
+
+// Details gets the projection of the account shown to its owner.
+func (a Account) Details() Details {
+	return Details{
+		Profile:       a.Profile(),
+		Email:         a.Email,
+		EmailVerified: a.EmailVerified,
+		CreatedAt:     a.CreatedAt,
+		UpdatedAt:     a.UpdatedAt,
+	}
 }
 
 // New initializes a new account based on the basic data provided from the account passed as param.
 //
 //	@param accountR Account: Basic data of the account to build.
+//	@param hashCost int: bcrypt cost used to encrypt the password.
 //	@return account Account: Account builded
 //	@return err error: error in the validation of the based account.
-func New(accountR Account) (account Account, err error) {
+func New(accountR Account, hashCost int) (account Account, err error) {
 	err = ValidateNickname(accountR.Nickname)
 	if err != nil {
 		return
 	}
+	err = ValidateEmail(accountR.Email)
+	if err != nil {
+		return
+	}
+	err = ValidateName(accountR.Name)
+	if err != nil {
+		return
+	}
+	err = ValidatePassword(accountR.Password)
+	if err != nil {
+		return
+	}
 	account = accountR
-	account.Password, err = auth.HashPassword(account.Password)
+	account.Password, err = auth.HashPassword(account.Password, hashCost)
+	return
+}
+
+// ValidateProfile validates the profile fields of the account which can be changed by its owner.
+//
+//	@param account Account: account to validate.
+//	 @return err error: invalid nickname, name or last name.
+func ValidateProfile(account Account) (err error) {
+	err = ValidateNickname(account.Nickname)
+	if err != nil {
+		return
+	}
+	err = ValidateName(account.Name)
+	if err != nil {
+		return
+	}
+	err = ValidateLastName(account.LastName)
 	return
 }
 
@@ -44,7 +109,7 @@ func ValidateNickname(nickname string) (err error) {
 	return
 }
 
-var emailRegex = regexp.MustCompile(`^([A-Za-zÑñÁáÉéÍíÓóÚú]+['-]{0,1}[A-Za-zÑñÁáÉéÍíÓóÚú]+)(\s+([A-Za-zÑñÁáÉéÍíÓóÚú]+['-]{0,1}[A-Za-zÑñÁáÉéÍíÓóÚú]+))*$`)
+var emailRegex = regexp.MustCompile(`^[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}$`)
 
 // ValidateEmail validate the email with a regular expression.
 //
@@ -56,3 +121,56 @@ func ValidateEmail(email string) (err error) {
 	}
 	return
 }
+
+var nameRegex = regexp.MustCompile(`^([A-Za-zÑñÁáÉéÍíÓóÚú]+['-]{0,1}[A-Za-zÑñÁáÉéÍíÓóÚú]+)(\s+([A-Za-zÑñÁáÉéÍíÓóÚú]+['-]{0,1}[A-Za-zÑñÁáÉéÍíÓóÚú]+))*$`)
+
+// ValidateName validate the name with a regular expression.
+//
+//	@param name string: name to validate.
+//	 @return err error: don't match the regex with the string provided.
+func ValidateName(name string) (err error) {
+	if !nameRegex.MatchString(name) {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid name: invalid name format of %s", name)
+	}
+	return
+}
+
+// ValidateLastName validate the last name, which is optional, with the name regular expression.
+//
+//	@param lastName string: last name to validate.
+//	 @return err error: don't match the regex with the string provided.
+func ValidateLastName(lastName string) (err error) {
+	if lastName != "" && !nameRegex.MatchString(lastName) {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid last name: invalid last name format of %s", lastName)
+	}
+	return
+}
+
+// ValidatePassword checks the password length.
+// The upper limit is the max input length supported by bcrypt.
+//
+//	@param password string: password to validate.
+//	 @return err error: password too short or too long.
+func ValidatePassword(password string) (err error) {
+	if len(password) < 8 || len(password) > 72 {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid password: password must have between 8 and 72 characters")
+	}
+	return
+}
+
+// NewNotFoundError initializes the error returned when the account doesn't exists or is deleted.
+//
+//	@param id int: account id.
+//	@return $1 error: not found ClientError.
+func NewNotFoundError(id int) error {
+	return errors.NewClientError(http.StatusNotFound, "not found: account %d not found", id)
+}
+
+// NewNicknameNotFoundError initializes the error returned when the account of a nickname
+// doesn't exists, is deleted or is not visible.
+//
+//	@param nickname string: account nickname.
+//	@return $1 error: not found ClientError.
+func NewNicknameNotFoundError(nickname string) error {
+	return errors.NewClientError(http.StatusNotFound, "not found: account %s not found", nickname)
+}
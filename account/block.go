@@ -0,0 +1,9 @@
+package account
+
+import "time"
+
+// BlockedAccount is a account blocked by other account.
+type BlockedAccount struct {
+	Profile   Profile   `json:"profile"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
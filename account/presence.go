@@ -0,0 +1,96 @@
+package account
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/coffemanfp/chat/errors"
+)
+
+// PresenceStatus is the connection status of a account.
+type PresenceStatus string
+
+const (
+	// OnlineStatus is the status of the accounts with active connections.
+	OnlineStatus PresenceStatus = "online"
+
+	// AwayStatus is the status of the accounts with connections idle for more than AwayAfter.
+	AwayStatus PresenceStatus = "away"
+
+	// OfflineStatus is the status of the accounts without connections.
+	OfflineStatus PresenceStatus = "offline"
+)
+
+const (
+	// AwayAfter is the idle time of the connections of a account to be shown as away.
+	AwayAfter = 5 * time.Minute
+
+	// MaxPresenceAccounts is the max number of accounts of a presence query.
+	MaxPresenceAccounts = 100
+)
+
+// LastSeenVisibility is the privacy setting of the last seen of a account.
+type LastSeenVisibility string
+
+const (
+	// EveryoneVisibility shows the last seen to every account.
+	EveryoneVisibility LastSeenVisibility = "everyone"
+
+	// ContactsVisibility shows the last seen to the accounts in the contacts of the account.
+	ContactsVisibility LastSeenVisibility = "contacts"
+
+	// NobodyVisibility hides the last seen to every account.
+	NobodyVisibility LastSeenVisibility = "nobody"
+)
+
+// ValidateLastSeenVisibility validates the last seen privacy setting.
+//
+//	@param visibility LastSeenVisibility: setting to validate.
+//	@return err error: unknown setting ClientError.
+func ValidateLastSeenVisibility(visibility LastSeenVisibility) (err error) {
+	switch visibility {
+	case EveryoneVisibility, ContactsVisibility, NobodyVisibility:
+	default:
+		err = errors.NewClientError(http.StatusBadRequest, "invalid last seen visibility: unknown visibility %s", visibility)
+	}
+	return
+}
+
+// Presence is the presence of a account shown to other accounts.
+type Presence struct {
+	AccountID int            `json:"account_id"`
+	Status    PresenceStatus `json:"status"`
+
+	// LastSeenAt is the last activity of the account. Is missing if the account hides it.
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+// NewPresence builds the presence of a account by its connections activity and last seen.
+//
+//	@param accountID int: id of the account.
+//	@param online bool: account has active connections.
+//	@param lastActivity time.Time: last activity of the account connections.
+//	@param lastSeen *time.Time: visible last seen of the account, nil if it's hidden.
+//	@param now time.Time: current time.
+//	@return presence Presence: presence of the account.
+func NewPresence(accountID int, online bool, lastActivity time.Time, lastSeen *time.Time, now time.Time) (presence Presence) {
+	presence = Presence{
+		AccountID: accountID,
+		Status:    OfflineStatus,
+	}
+	if online {
+		presence.Status = OnlineStatus
+		if now.Sub(lastActivity) > AwayAfter {
+			presence.Status = AwayStatus
+		}
+	}
+
+	if lastSeen != nil {
+		seen := *lastSeen
+		if online && lastActivity.After(seen) {
+			seen = lastActivity
+		}
+		presence.LastSeenAt = &seen
+	}
+	return
+}
@@ -0,0 +1,21 @@
+package account
+
+import "time"
+
+// Profile is the public projection of a account, safe to be shown to other accounts.
+type Profile struct {
+	ID         int    `json:"id,omitempty"`
+	Nickname   string `json:"nickname,omitempty"`
+	Name       string `json:"name,omitempty"`
+	LastName   string `json:"last_name,omitempty"`
+	PictureURL string `json:"picture_url,omitempty"`
+}
+
+// Details is the projection of a account shown to its owner, which never includes the password.
+type Details struct {
+	Profile
+	Email         string     `json:"email,omitempty"`
+	EmailVerified bool       `json:"email_verified"`
+	CreatedAt     time.Time  `json:"created_at,omitempty"`
+	UpdatedAt     *time.Time `json:"updated_at,omitempty"`
+}
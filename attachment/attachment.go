@@ -0,0 +1,168 @@
+package attachment
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/coffemanfp/chat/errors"
+)
+
+// MaxNameLength is the max number of characters of a attachment name.
+const MaxNameLength = 255
+
+// allowedMimeTypes are the MIME types accepted on the uploads, detected by the file content.
+var allowedMimeTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"application/zip": true,
+	"text/plain":      true,
+	"audio/mpeg":      true,
+	"audio/wave":      true,
+	"video/mp4":       true,
+	"video/webm":      true,
+}
+
+// Attachment is a file uploaded by a account. The files with the same content share the same blob.
+type Attachment struct {
+	ID        int    `json:"id,omitempty"`
+	AccountID int    `json:"account_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	MimeType  string `json:"mime_type,omitempty"`
+	Size      int64  `json:"size,omitempty"`
+
+	// Hash is the hex SHA-256 of the file content, used as the blob identity.
+	Hash      string    `json:"-"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+
+	// URL is the signed download URL, set on the responses.
+	URL string `json:"url,omitempty"`
+
+	// Variants are the thumbnails generated of the image, empty while they are processed.
+	Variants []Variant `json:"variants,omitempty"`
+}
+
+// New initializes a new attachment of a uploaded file.
+//
+//	@param accountID int: id of the account which uploads the file.
+//	@param name string: file name provided by the client.
+//	@param mimeType string: MIME type detected by the file content.
+//	@param size int64: file size in bytes.
+//	@param hash string: hex SHA-256 of the file content.
+//	@param maxSize int64: max size in bytes of a file.
+//	@return attachment Attachment: Attachment builded.
+//	@return err error: invalid name, type or size.
+func New(accountID int, name, mimeType string, size int64, hash string, maxSize int64) (attachment Attachment, err error) {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == "/" {
+		name = "file"
+	}
+	if utf8.RuneCountInString(name) > MaxNameLength {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid name: file name exceeds %d characters", MaxNameLength)
+		return
+	}
+
+	err = ValidateSize(size, maxSize)
+	if err != nil {
+		return
+	}
+
+	err = ValidateMimeType(mimeType)
+	if err != nil {
+		return
+	}
+
+	attachment = Attachment{
+		AccountID: accountID,
+		Name:      name,
+		MimeType:  mimeType,
+		Size:      size,
+		Hash:      hash,
+		CreatedAt: time.Now(),
+	}
+	return
+}
+
+// DetectMimeType detects the MIME type of a file by its first bytes, ignoring the client declared type.
+//
+//	@param head []byte: first 512 bytes of the file, or the whole file if it's smaller.
+//	@return $1 string: MIME type without parameters.
+func DetectMimeType(head []byte) string {
+	mimeType := http.DetectContentType(head)
+	if i := strings.Index(mimeType, ";"); i != -1 {
+		mimeType = mimeType[:i]
+	}
+	return strings.TrimSpace(mimeType)
+}
+
+// ValidateMimeType checks the MIME type is allowed on the uploads.
+//
+//	@param mimeType string: MIME type to validate.
+//	 @return err error: unsupported type ClientError.
+func ValidateMimeType(mimeType string) (err error) {
+	if !allowedMimeTypes[mimeType] {
+		err = errors.NewClientError(http.StatusUnsupportedMediaType, "unsupported file: file type %s is not allowed", mimeType)
+	}
+	return
+}
+
+// ValidateSize checks the size of a file.
+//
+//	@param size int64: file size in bytes.
+//	@param maxSize int64: max size in bytes of a file.
+//	 @return err error: empty or too large file ClientError.
+func ValidateSize(size, maxSize int64) (err error) {
+	if size == 0 {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid file: empty file")
+		return
+	}
+	if size > maxSize {
+		err = errors.NewClientError(http.StatusRequestEntityTooLarge, "file too large: file exceeds %d bytes", maxSize)
+	}
+	return
+}
+
+// IsImage checks if the attachment is a image.
+func (a Attachment) IsImage() bool {
+	return strings.HasPrefix(a.MimeType, "image/")
+}
+
+// Key is the blob store key of the attachment content.
+func (a Attachment) Key() string {
+	return a.Blob().Key()
+}
+
+// CheckPicture checks the attachment can be used as a picture by the account.
+// The pictures are sent by their PictureVariant, so its thumbnails must be already generated.
+//
+//	@param attachment Attachment: attachment to use, with its variants.
+//	@param accountID int: id of the account which sets the picture.
+//	 @return err error: not owned, not supported image or not processed ClientError.
+func CheckPicture(attachment Attachment, accountID int) (err error) {
+	if attachment.AccountID != accountID {
+		err = NewNotFoundError(attachment.ID)
+		return
+	}
+	if !attachment.Processable() {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid picture: attachment %d is not a supported image", attachment.ID)
+		return
+	}
+	if _, ok := attachment.Variant(PictureVariant); !ok {
+		err = errors.NewClientError(http.StatusConflict, "picture not ready: attachment %d is still being processed", attachment.ID)
+	}
+	return
+}
+
+// NewNotFoundError initializes the error returned when the attachment doesn't exists
+// or is not visible for the account.
+//
+//	@param id int: attachment id.
+//	@return $1 error: not found ClientError.
+func NewNotFoundError(id int) error {
+	return errors.NewClientError(http.StatusNotFound, "not found: attachment %d not found", id)
+}
@@ -0,0 +1,3 @@
+// Package attachment defines the uploaded files of the accounts, used on the messages and as pictures.
+
+package attachment
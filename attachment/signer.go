@@ -0,0 +1,116 @@
+package attachment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coffemanfp/chat/errors"
+)
+
+// The signatures are bound to their purpose, so a signature of a URL can't be used for another kind of URL.
+const (
+	// downloadPurpose signs the expiring download URLs.
+	downloadPurpose = "download"
+
+	// picturePurpose signs the pictures URLs, which don't expire.
+	picturePurpose = "picture"
+)
+
+// Signer signs the download URLs of the attachments, so they can be downloaded without the session token.
+type Signer struct {
+	key     []byte
+	baseURL string
+}
+
+// NewSigner initializes a new Signer instance.
+//
+//	@param secret string: secret key of the signatures.
+//	@param baseURL string: absolute base URL of the download route, like https://host/api/v1/files.
+//	@return signer Signer: new Signer instance.
+func NewSigner(secret, baseURL string) (signer Signer) {
+	// The key is derived from the secret, to not share the signatures with other uses of the secret.
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("attachment-url"))
+	return Signer{
+		key:     mac.Sum(nil),
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+// URL gets the signed download URL of the attachment, valid until the expiration time.
+//
+//	@param attachment Attachment: attachment to download.
+//	@param expiresAt time.Time: expiration time of the URL.
+//	@return $1 string: signed URL.
+func (s Signer) URL(attachment Attachment, expiresAt time.Time) string {
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	return s.url(attachment.ID, url.Values{"expires": {expires}}, s.sign(downloadPurpose, attachment.ID, expires))
+}
+
+// PictureURL gets a signed download URL without expiration of the PictureVariant of the attachment,
+// used for the pictures. The variant is signed, so the URL can't download the original file.
+//
+//	@param attachment Attachment: attachment of the picture.
+//	@return $1 string: signed URL.
+func (s Signer) PictureURL(attachment Attachment) string {
+	return s.url(attachment.ID, url.Values{"variant": {PictureVariant}}, s.sign(picturePurpose, attachment.ID, PictureVariant))
+}
+
+// Verify verifies the signature of a download URL, and gets the variant of the attachment to send.
+// The URLs without expiration must be signed as pictures URLs, which just send their signed variant.
+// The expiring URLs send the original file, so they can send any of its variants.
+//
+//	@param id int: attachment id of the URL.
+//	@param query url.Values: query params of the URL.
+//	@param now time.Time: current time.
+//	@return variant string: variant name to send, empty for the original file.
+//	 @return err error: invalid or expired signature ClientError.
+func (s Signer) Verify(id int, query url.Values, now time.Time) (variant string, err error) {
+	signature, dErr := base64.RawURLEncoding.DecodeString(query.Get("signature"))
+	if dErr != nil {
+		err = newInvalidSignatureError()
+		return
+	}
+
+	variant = query.Get("variant")
+	rawExpires := query.Get("expires")
+	if rawExpires == "" {
+		if variant == "" || !hmac.Equal(signature, s.sign(picturePurpose, id, variant)) {
+			variant, err = "", newInvalidSignatureError()
+		}
+		return
+	}
+
+	expires, pErr := strconv.ParseInt(rawExpires, 10, 64)
+	if pErr != nil || !hmac.Equal(signature, s.sign(downloadPurpose, id, rawExpires)) {
+		variant, err = "", newInvalidSignatureError()
+		return
+	}
+	if now.Unix() > expires {
+		variant, err = "", errors.NewClientError(http.StatusForbidden, "forbidden: expired file signature")
+	}
+	return
+}
+
+func (s Signer) url(id int, query url.Values, signature []byte) string {
+	query.Set("signature", base64.RawURLEncoding.EncodeToString(signature))
+	return fmt.Sprintf("%s/%d?%s", s.baseURL, id, query.Encode())
+}
+
+// sign signs the attachment id and the value of the URL for the purpose provided.
+func (s Signer) sign(purpose string, id int, value string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	fmt.Fprintf(mac, "%s:%d:%s", purpose, id, value)
+	return mac.Sum(nil)
+}
+
+func newInvalidSignatureError() error {
+	return errors.NewClientError(http.StatusForbidden, "forbidden: invalid file signature")
+}
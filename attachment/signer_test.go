@@ -0,0 +1,175 @@
+package attachment
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	sErrors "github.com/coffemanfp/chat/errors"
+)
+
+// signedQuery gets the query of a signed URL.
+func signedQuery(t *testing.T, rawURL string) url.Values {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Query()
+}
+
+func TestSignerVerify(t *testing.T) {
+	now := time.Now()
+	signer := NewSigner("secret", "https://chat.example.com/api/v1/files/")
+	att := Attachment{ID: 7}
+
+	tests := []struct {
+		name        string
+		id          int
+		query       func() url.Values
+		wantVariant string
+		wantErr     bool
+	}{
+		{
+			name:  "download url",
+			id:    7,
+			query: func() url.Values { return signedQuery(t, signer.URL(att, now.Add(time.Hour))) },
+		},
+		{
+			name:    "expired download url",
+			id:      7,
+			query:   func() url.Values { return signedQuery(t, signer.URL(att, now.Add(-time.Second))) },
+			wantErr: true,
+		},
+		{
+			name: "download url of another attachment",
+			id:   8,
+			query: func() url.Values {
+				q := signedQuery(t, signer.URL(att, now.Add(time.Hour)))
+				return q
+			},
+			wantErr: true,
+		},
+		{
+			name: "extended expiration",
+			id:   7,
+			query: func() url.Values {
+				q := signedQuery(t, signer.URL(att, now.Add(time.Hour)))
+				q.Set("expires", "99999999999")
+				return q
+			},
+			wantErr: true,
+		},
+		{
+			name: "download signature without expiration",
+			id:   7,
+			query: func() url.Values {
+				q := signedQuery(t, signer.URL(att, now.Add(time.Hour)))
+				q.Del("expires")
+				return q
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero expiration",
+			id:   7,
+			query: func() url.Values {
+				q := signedQuery(t, signer.URL(att, time.Unix(0, 0)))
+				return q
+			},
+			wantErr: true,
+		},
+		{
+			name: "download url of a variant",
+			id:   7,
+			query: func() url.Values {
+				q := signedQuery(t, signer.URL(att, now.Add(time.Hour)))
+				q.Set("variant", PictureVariant)
+				return q
+			},
+			wantVariant: PictureVariant,
+		},
+		{
+			name:        "picture url",
+			id:          7,
+			query:       func() url.Values { return signedQuery(t, signer.PictureURL(att)) },
+			wantVariant: PictureVariant,
+		},
+		{
+			name: "picture url without variant",
+			id:   7,
+			query: func() url.Values {
+				q := signedQuery(t, signer.PictureURL(att))
+				q.Del("variant")
+				return q
+			},
+			wantErr: true,
+		},
+		{
+			name: "picture url of another variant",
+			id:   7,
+			query: func() url.Values {
+				q := signedQuery(t, signer.PictureURL(att))
+				q.Set("variant", "1024")
+				return q
+			},
+			wantErr: true,
+		},
+		{
+			name: "picture signature with expiration",
+			id:   7,
+			query: func() url.Values {
+				q := signedQuery(t, signer.PictureURL(att))
+				q.Set("expires", "0")
+				return q
+			},
+			wantErr: true,
+		},
+		{
+			name: "signature of another secret",
+			id:   7,
+			query: func() url.Values {
+				q := signedQuery(t, NewSigner("other", "https://chat.example.com").URL(att, now.Add(time.Hour)))
+				return q
+			},
+			wantErr: true,
+		},
+		{
+			name:    "missing signature",
+			id:      7,
+			query:   func() url.Values { return url.Values{"expires": {"99999999999"}} },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			variant, err := signer.Verify(tt.id, tt.query(), now)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Verify failed: %s", err)
+				}
+				if variant != tt.wantVariant {
+					t.Errorf("variant = %q, want %q", variant, tt.wantVariant)
+				}
+				return
+			}
+
+			var cErr sErrors.ClientError
+			if !errors.As(err, &cErr) || cErr.HTTPCode() != http.StatusForbidden {
+				t.Errorf("Verify error = %v, want forbidden client error", err)
+			}
+		})
+	}
+}
+
+func TestSignerURLPath(t *testing.T) {
+	signer := NewSigner("secret", "https://chat.example.com/api/v1/files/")
+
+	rawURL := signer.URL(Attachment{ID: 42}, time.Now())
+	if !strings.HasPrefix(rawURL, "https://chat.example.com/api/v1/files/42?") {
+		t.Errorf("URL = %s, want the attachment path under the base URL", rawURL)
+	}
+}
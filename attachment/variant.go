@@ -0,0 +1,82 @@
+package attachment
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/coffemanfp/chat/errors"
+	"github.com/coffemanfp/chat/thumbnail"
+)
+
+// PictureVariant is the variant used on the picture URLs of the accounts and conversations.
+// The clients can request the other sizes changing the variant param of the URL.
+const PictureVariant = "256"
+
+// Blob is the content shared by the attachments with the same hash.
+type Blob struct {
+	Hash     string
+	MimeType string
+	Size     int64
+}
+
+// Variant is a square thumbnail generated of a image blob, without its metadata.
+type Variant struct {
+	Name      string    `json:"name,omitempty"`
+	MimeType  string    `json:"mime_type,omitempty"`
+	Width     int       `json:"width,omitempty"`
+	Height    int       `json:"height,omitempty"`
+	Size      int64     `json:"size,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// Blob gets the blob of the attachment content.
+func (a Attachment) Blob() Blob {
+	return Blob{
+		Hash:     a.Hash,
+		MimeType: a.MimeType,
+		Size:     a.Size,
+	}
+}
+
+// Processable checks if the variants of the attachment can be generated.
+func (a Attachment) Processable() bool {
+	return thumbnail.Supported(a.MimeType)
+}
+
+// Variant gets a generated variant of the attachment by its name.
+//
+//	@param name string: variant name.
+//	@return variant Variant: found variant.
+//	@return ok bool: the variant is generated.
+func (a Attachment) Variant(name string) (variant Variant, ok bool) {
+	for _, v := range a.Variants {
+		if v.Name == name {
+			variant, ok = v, true
+			return
+		}
+	}
+	return
+}
+
+// Key is the blob store key of the blob content.
+func (b Blob) Key() string {
+	return "blobs/" + b.Hash[:2] + "/" + b.Hash
+}
+
+// VariantKey is the blob store key of a variant content of the blob.
+//
+//	@param name string: variant name.
+//	@return $1 string: blob store key.
+func (b Blob) VariantKey(name string) string {
+	return "variants/" + b.Hash[:2] + "/" + b.Hash + "/" + name
+}
+
+// NewVariantNotFoundError initializes the error returned when a variant of the attachment
+// doesn't exists or is not generated yet.
+//
+//	@param id int: attachment id.
+//	@param name string: variant name.
+//	@return $1 error: not found ClientError.
+func NewVariantNotFoundError(id int, name string) error {
+	return errors.NewClientError(http.StatusNotFound, "not found: variant %s of attachment %d not found", name, id)
+}
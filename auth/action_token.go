@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coffemanfp/chat/errors"
+)
+
+// ActionPurpose is the action authorized by a ActionToken.
+type ActionPurpose string
+
+const (
+	// VerifyEmailPurpose authorizes the verification of the account email.
+	VerifyEmailPurpose ActionPurpose = "verify_email"
+
+	// ResetPasswordPurpose authorizes the replacement of the account password.
+	ResetPasswordPurpose ActionPurpose = "reset_password"
+
+	// LinkIdentityPurpose authorizes the link of a external platform identity to the account.
+	LinkIdentityPurpose ActionPurpose = "link_identity"
+)
+
+const (
+	// VerifyEmailLifetime is the time to expire of the email verification tokens.
+	VerifyEmailLifetime = 24 * time.Hour
+
+	// ResetPasswordLifetime is the time to expire of the password reset tokens.
+	ResetPasswordLifetime = time.Hour
+
+	// LinkIdentityLifetime is the time to expire of the identity link tokens.
+	LinkIdentityLifetime = 10 * time.Minute
+
+	// ActionTokenThrottle is the time to wait before a new token of the same purpose is sent to a account.
+	ActionTokenThrottle = 5 * time.Minute
+)
+
+// ActionToken is a signed single use token sent by email to authorize a account action.
+// The token is stored by its ID, which is used once.
+type ActionToken struct {
+	ID        string
+	AccountID int
+	Purpose   ActionPurpose
+	ExpiresAt time.Time
+
+	// Token is the signed token sent to the account. It's not stored.
+	Token string
+}
+
+// ActionTokenSigner signs and verifies the action tokens.
+type ActionTokenSigner struct {
+	key []byte
+}
+
+// NewActionTokenSigner initializes a new ActionTokenSigner instance.
+//
+//	@param secret string: secret key of the signatures.
+//	@return s ActionTokenSigner: new ActionTokenSigner instance.
+func NewActionTokenSigner(secret string) (s ActionTokenSigner) {
+	// The key is derived from the secret, to not share the signatures with other uses of the secret.
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("action-token"))
+	return ActionTokenSigner{
+		key: mac.Sum(nil),
+	}
+}
+
+// New generates a new signed action token.
+//
+//	@param accountID int: id of the account of the action.
+//	@param purpose ActionPurpose: authorized action.
+//	@param lifetime time.Duration: time to expire of the token.
+//	@return token ActionToken: new token.
+//	@return err error: random source error.
+func (s ActionTokenSigner) New(accountID int, purpose ActionPurpose, lifetime time.Duration) (token ActionToken, err error) {
+	id, err := RandomToken(16)
+	if err != nil {
+		return
+	}
+
+	token = ActionToken{
+		ID:        id,
+		AccountID: accountID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(lifetime).Truncate(time.Second),
+	}
+
+	payload := fmt.Sprintf("%s.%d.%s.%d", token.ID, token.AccountID, token.Purpose, token.ExpiresAt.Unix())
+	token.Token = base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(s.sign(payload))
+	return
+}
+
+// Parse verifies the signature, purpose and expiration of a action token.
+// The single use must be checked by the caller with the token ID.
+//
+//	@param tokenString string: signed token.
+//	@param purpose ActionPurpose: expected action.
+//	@param now time.Time: current time.
+//	@return token ActionToken: verified token.
+//	@return err error: invalid or expired token ClientError.
+func (s ActionTokenSigner) Parse(tokenString string, purpose ActionPurpose, now time.Time) (token ActionToken, err error) {
+	err = NewInvalidActionTokenError()
+
+	parts := strings.Split(tokenString, ".")
+	if len(parts) != 2 {
+		return
+	}
+	payload, pErr := base64.RawURLEncoding.DecodeString(parts[0])
+	signature, sErr := base64.RawURLEncoding.DecodeString(parts[1])
+	if pErr != nil || sErr != nil || !hmac.Equal(signature, s.sign(string(payload))) {
+		return
+	}
+
+	fields := strings.Split(string(payload), ".")
+	if len(fields) != 4 || ActionPurpose(fields[2]) != purpose {
+		return
+	}
+	accountID, aErr := strconv.Atoi(fields[1])
+	expires, eErr := strconv.ParseInt(fields[3], 10, 64)
+	if aErr != nil || eErr != nil || now.Unix() > expires {
+		return
+	}
+
+	err = nil
+	token = ActionToken{
+		ID:        fields[0],
+		AccountID: accountID,
+		Purpose:   purpose,
+		ExpiresAt: time.Unix(expires, 0),
+		Token:     tokenString,
+	}
+	return
+}
+
+func (s ActionTokenSigner) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// NewInvalidActionTokenError initializes the error returned when a action token is invalid, expired or used.
+//
+//	@return $1 error: bad request ClientError.
+func NewInvalidActionTokenError() error {
+	return errors.NewClientError(http.StatusBadRequest, "invalid token: token is invalid, expired or already used")
+}
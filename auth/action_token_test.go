@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	sErrors "github.com/coffemanfp/chat/errors"
+)
+
+// tamperPayload replaces the account id of the token payload, keeping its signature.
+func tamperPayload(t *testing.T, token string) string {
+	parts := strings.Split(token, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := strings.Split(string(payload), ".")
+	fields[1] = "2"
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(fields, "."))) + "." + parts[1]
+}
+
+// tamperSignature flips a bit of the token signature.
+func tamperSignature(t *testing.T, token string) string {
+	parts := strings.Split(token, ".")
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature[0] ^= 1
+	return parts[0] + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestActionTokenSigner(t *testing.T) {
+	signer := NewActionTokenSigner("secret")
+	token, err := signer.New(1, ResetPasswordPurpose, ResetPasswordLifetime)
+	if err != nil {
+		t.Fatalf("New failed: %s", err)
+	}
+
+	other, err := NewActionTokenSigner("other-secret").New(1, ResetPasswordPurpose, ResetPasswordLifetime)
+	if err != nil {
+		t.Fatalf("New failed: %s", err)
+	}
+
+	now := time.Now()
+	tests := []struct {
+		name    string
+		token   string
+		purpose ActionPurpose
+		now     time.Time
+		wantErr bool
+	}{
+		{name: "valid", token: token.Token, purpose: ResetPasswordPurpose, now: now},
+		{name: "valid until expiration", token: token.Token, purpose: ResetPasswordPurpose, now: token.ExpiresAt},
+		{name: "expired", token: token.Token, purpose: ResetPasswordPurpose, now: token.ExpiresAt.Add(time.Second), wantErr: true},
+		{name: "wrong purpose", token: token.Token, purpose: VerifyEmailPurpose, now: now, wantErr: true},
+		{name: "tampered payload", token: tamperPayload(t, token.Token), purpose: ResetPasswordPurpose, now: now, wantErr: true},
+		{name: "tampered signature", token: tamperSignature(t, token.Token), purpose: ResetPasswordPurpose, now: now, wantErr: true},
+		{name: "other secret", token: other.Token, purpose: ResetPasswordPurpose, now: now, wantErr: true},
+		{name: "missing signature", token: strings.Split(token.Token, ".")[0], purpose: ResetPasswordPurpose, now: now, wantErr: true},
+		{name: "not base64", token: "not a token!.signature", purpose: ResetPasswordPurpose, now: now, wantErr: true},
+		{name: "empty", token: "", purpose: ResetPasswordPurpose, now: now, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := signer.Parse(tt.token, tt.purpose, tt.now)
+			if tt.wantErr {
+				var cErr sErrors.ClientError
+				if !errors.As(err, &cErr) || cErr.HTTPCode() != http.StatusBadRequest {
+					t.Fatalf("Parse error = %v, want bad request client error", err)
+				}
+				if parsed != (ActionToken{}) {
+					t.Errorf("Parse = %v, want zero token", parsed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse failed: %s", err)
+			}
+
+			if parsed.ID != token.ID || parsed.AccountID != token.AccountID || parsed.Purpose != token.Purpose {
+				t.Errorf("Parse = %+v, want %+v", parsed, token)
+			}
+			if !parsed.ExpiresAt.Equal(token.ExpiresAt) {
+				t.Errorf("ExpiresAt = %s, want %s", parsed.ExpiresAt, token.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestActionTokenSignerUniqueIDs(t *testing.T) {
+	signer := NewActionTokenSigner("secret")
+
+	ids := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		token, err := signer.New(1, VerifyEmailPurpose, VerifyEmailLifetime)
+		if err != nil {
+			t.Fatalf("New failed: %s", err)
+		}
+		if ids[token.ID] {
+			t.Fatalf("duplicated token id %s", token.ID)
+		}
+		ids[token.ID] = true
+	}
+}
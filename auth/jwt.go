@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+// Claims represents the claims of a session token.
+type Claims struct {
+	jwt.StandardClaims
+
+	// AccountID is the id of the account which owns the token.
+	AccountID int `json:"id"`
+
+	// SessionID is the Session.ID which the token belongs.
+	SessionID string `json:"sid"`
+}
+
+// Valid validates the time based claims, requiring the expiration time.
+func (c Claims) Valid() (err error) {
+	if c.ExpiresAt == 0 {
+		err = fmt.Errorf("invalid token: missing expiration time")
+		return
+	}
+	return c.StandardClaims.Valid()
+}
+
+// JWTManager generates and parses the session tokens.
+// The tokens are signed with the asymmetric keys of the KeySet, or with the HMAC secret key if the KeySet is empty.
+type JWTManager struct {
+	secretKey string
+	keys      KeySet
+	issuer    string
+	audience  string
+	lifetime  time.Duration
+}
+
+// NewJWTManager initializes a new JWTManager instance.
+//
+//	@param secretKey string: HMAC key used to sign the tokens if there are no asymmetric keys.
+//	@param keys KeySet: asymmetric keys used to sign and verify the tokens.
+//	@param issuer string: issuer of the tokens.
+//	@param audience string: audience of the tokens.
+//	@param lifetime time.Duration: time to expire of the tokens.
+//	@return m JWTManager: new JWTManager instance.
+func NewJWTManager(secretKey string, keys KeySet, issuer, audience string, lifetime time.Duration) (m JWTManager) {
+	return JWTManager{
+		secretKey: secretKey,
+		keys:      keys,
+		issuer:    issuer,
+		audience:  audience,
+		lifetime:  lifetime,
+	}
+}
+
+// JWKS gets the public keys to verify the tokens.
+func (m JWTManager) JWKS() JWKS {
+	return m.keys.JWKS()
+}
+
+// Lifetime gets the time to expire of the generated tokens.
+func (m JWTManager) Lifetime() time.Duration {
+	return m.lifetime
+}
+
+// Generate generates a new signed token for the account session.
+//
+//	@param id int: account id.
+//	@param sessionID string: id of the session which the token belongs.
+//	@return tokenS string: signed token.
+//	@return err error: signing error.
+func (m JWTManager) Generate(id int, sessionID string) (tokenS string, err error) {
+	now := time.Now()
+
+	claims := Claims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.NewString(),
+			Issuer:    m.issuer,
+			Audience:  m.audience,
+			Subject:   fmt.Sprint(id),
+			IssuedAt:  now.Unix(),
+			NotBefore: now.Unix(),
+			ExpiresAt: now.Add(m.lifetime).Unix(),
+		},
+		AccountID: id,
+		SessionID: sessionID,
+	}
+
+	var token *jwt.Token
+	var key interface{}
+	if m.keys.Empty() {
+		token = jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		key = []byte(m.secretKey)
+	} else {
+		signingKey := m.keys.signingKey()
+		token = jwt.NewWithClaims(signingKey.Method, claims)
+		token.Header["kid"] = signingKey.ID
+		key = signingKey.PrivateKey
+	}
+
+	tokenS, err = token.SignedString(key)
+	if err != nil {
+		err = fmt.Errorf("failed to sign token: %s", err)
+	}
+	return
+}
+
+// Parse verifies the signature, time, issuer and audience of the token and gets its claims.
+//
+//	@param tokenS string: signed token.
+//	@return claims Claims: claims of the token.
+//	@return err error: invalid token error.
+func (m JWTManager) Parse(tokenS string) (claims Claims, err error) {
+	_, err = jwt.ParseWithClaims(tokenS, &claims, m.keyFunc)
+	if err != nil {
+		return
+	}
+
+	if !claims.VerifyIssuer(m.issuer, true) {
+		err = fmt.Errorf("invalid token: unexpected issuer %s", claims.Issuer)
+		return
+	}
+	if !claims.VerifyAudience(m.audience, true) {
+		err = fmt.Errorf("invalid token: unexpected audience %s", claims.Audience)
+		return
+	}
+	if claims.SessionID == "" {
+		err = fmt.Errorf("invalid token: missing session id")
+	}
+	return
+}
+
+// keyFunc gets the key to verify the token.
+// HMAC tokens are just accepted if there are no asymmetric keys.
+func (m JWTManager) keyFunc(token *jwt.Token) (key interface{}, err error) {
+	if m.keys.Empty() {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			err = fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			return
+		}
+		key = []byte(m.secretKey)
+		return
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	signingKey, err := m.keys.verificationKey(kid, time.Now())
+	if err != nil {
+		return
+	}
+	if token.Method.Alg() != signingKey.Method.Alg() {
+		err = fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		return
+	}
+	key = signingKey.PublicKey
+	return
+}
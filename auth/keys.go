@@ -0,0 +1,284 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// SigningKey is a asymmetric key used to sign or verify the session tokens.
+type SigningKey struct {
+	// ID is the key id sent on the kid header of the tokens.
+	ID     string
+	Method jwt.SigningMethod
+
+	// PrivateKey is the key used to sign the tokens. Is nil for verification only keys.
+	PrivateKey crypto.PrivateKey
+	PublicKey  crypto.PublicKey
+
+	// VerifyUntil is the end of the rotation window of a retired key.
+	// Tokens signed with the key are rejected after it. Zero means no limit.
+	VerifyUntil time.Time
+}
+
+// LoadSigningKey loads a new SigningKey from its PEM files.
+// Just one of the files is required, the public key is taken from the private key if it's provided.
+//
+//	@param id string: key id.
+//	@param alg string: signing algorithm. Supported algorithms: RS256, EdDSA.
+//	@param privateKeyFile string: path to the PEM private key file. Empty for verification only keys.
+//	@param publicKeyFile string: path to the PEM public key file. Ignored if privateKeyFile is provided.
+//	@param verifyUntil time.Time: end of the rotation window of the key.
+//	@return key SigningKey: new SigningKey instance.
+//	@return err error: reading, parsing or unsupported algorithm error.
+func LoadSigningKey(id, alg, privateKeyFile, publicKeyFile string, verifyUntil time.Time) (key SigningKey, err error) {
+	key = SigningKey{
+		ID:          id,
+		VerifyUntil: verifyUntil,
+	}
+
+	var raw []byte
+	if privateKeyFile != "" {
+		raw, err = ioutil.ReadFile(privateKeyFile)
+	} else {
+		raw, err = ioutil.ReadFile(publicKeyFile)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to read signing key %s: %s", id, err)
+		return
+	}
+
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		key.Method = jwt.SigningMethodRS256
+		if privateKeyFile != "" {
+			var privateKey *rsa.PrivateKey
+			privateKey, err = jwt.ParseRSAPrivateKeyFromPEM(raw)
+			if err == nil {
+				key.PrivateKey = privateKey
+				key.PublicKey = &privateKey.PublicKey
+			}
+		} else {
+			key.PublicKey, err = jwt.ParseRSAPublicKeyFromPEM(raw)
+		}
+	case jwt.SigningMethodEdDSA.Alg():
+		key.Method = jwt.SigningMethodEdDSA
+		if privateKeyFile != "" {
+			var privateKey crypto.PrivateKey
+			privateKey, err = jwt.ParseEdPrivateKeyFromPEM(raw)
+			if err == nil {
+				key.PrivateKey = privateKey
+				key.PublicKey = privateKey.(ed25519.PrivateKey).Public()
+			}
+		} else {
+			key.PublicKey, err = jwt.ParseEdPublicKeyFromPEM(raw)
+		}
+	default:
+		err = fmt.Errorf("unsupported signing algorithm %s of signing key %s", alg, id)
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to parse signing key %s: %s", id, err)
+	}
+	return
+}
+
+// KeySet keeps the asymmetric keys of the session tokens.
+type KeySet struct {
+	signingKeyID string
+	keys         map[string]SigningKey
+}
+
+// NewKeySet initializes a new KeySet instance.
+//
+//	@param signingKeyID string: id of the key used to sign the new tokens.
+//	@param keys []SigningKey: available keys, including the retired keys in their rotation window.
+//	@return set KeySet: new KeySet instance.
+//	@return err error: missing or verification only signing key.
+func NewKeySet(signingKeyID string, keys []SigningKey) (set KeySet, err error) {
+	set = KeySet{
+		signingKeyID: signingKeyID,
+		keys:         make(map[string]SigningKey, len(keys)),
+	}
+	for _, key := range keys {
+		set.keys[key.ID] = key
+	}
+
+	if len(keys) == 0 {
+		return
+	}
+
+	signingKey, ok := set.keys[signingKeyID]
+	if !ok {
+		err = fmt.Errorf("missing signing key: key %s not found", signingKeyID)
+		return
+	}
+	if signingKey.PrivateKey == nil {
+		err = fmt.Errorf("invalid signing key: key %s has no private key", signingKeyID)
+	}
+	return
+}
+
+// Empty checks if the KeySet has no keys.
+func (k KeySet) Empty() bool {
+	return len(k.keys) == 0
+}
+
+func (k KeySet) signingKey() SigningKey {
+	return k.keys[k.signingKeyID]
+}
+
+// verificationKey gets the key to verify the tokens with the kid provided.
+func (k KeySet) verificationKey(kid string, now time.Time) (key SigningKey, err error) {
+	key, ok := k.keys[kid]
+	if !ok {
+		err = fmt.Errorf("unknown signing key %s", kid)
+		return
+	}
+	if !key.VerifyUntil.IsZero() && now.After(key.VerifyUntil) {
+		err = fmt.Errorf("retired signing key %s", kid)
+	}
+	return
+}
+
+// JWK represents a public JSON Web Key.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+
+	// RSA keys fields.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC and OKP keys fields.
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// PublicKey gets the public key represented by the JWK.
+// Supported key types: RSA, EC (P-256, P-384, P-521) and OKP (Ed25519).
+//
+//	@return key crypto.PublicKey: *rsa.PublicKey, *ecdsa.PublicKey or ed25519.PublicKey.
+//	@return err error: invalid or unsupported key error.
+func (j JWK) PublicKey() (key crypto.PublicKey, err error) {
+	switch j.KeyType {
+	case "RSA":
+		var n, e []byte
+		n, err = base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			break
+		}
+		e, err = base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			break
+		}
+		key = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			err = fmt.Errorf("unsupported curve %s", j.Curve)
+		}
+		if err != nil {
+			break
+		}
+		var x, y []byte
+		x, err = base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			break
+		}
+		y, err = base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil {
+			break
+		}
+		key = &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+	case "OKP":
+		if j.Curve != "Ed25519" {
+			err = fmt.Errorf("unsupported curve %s", j.Curve)
+			break
+		}
+		var x []byte
+		x, err = base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			break
+		}
+		if len(x) != ed25519.PublicKeySize {
+			err = fmt.Errorf("invalid Ed25519 key size %d", len(x))
+			break
+		}
+		key = ed25519.PublicKey(x)
+	default:
+		err = fmt.Errorf("unsupported key type %s", j.KeyType)
+	}
+	if err != nil {
+		err = fmt.Errorf("invalid JWK %s: %s", j.KeyID, err)
+	}
+	return
+}
+
+// JWKS represents a public JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS gets the public keys still valid to verify tokens.
+func (k KeySet) JWKS() (set JWKS) {
+	set.Keys = []JWK{}
+	now := time.Now()
+	for kid := range k.keys {
+		key, err := k.verificationKey(kid, now)
+		if err != nil {
+			continue
+		}
+
+		jwk := JWK{
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: key.Method.Alg(),
+		}
+		switch pub := key.PublicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].KeyID < set.Keys[j].KeyID
+	})
+	return
+}
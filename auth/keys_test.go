@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// writePEM writes the PEM block of the DER bytes in a new file of the directory.
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) (path string) {
+	path = filepath.Join(dir, name)
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+// writeKeyFiles writes the PEM files of the private key and its public key.
+func writeKeyFiles(t *testing.T, name string, privateKey crypto.Signer) (privateFile, publicFile string) {
+	dir := t.TempDir()
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateFile = writePEM(t, dir, name+".pem", "PRIVATE KEY", der)
+
+	der, err = x509.MarshalPKIXPublicKey(privateKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicFile = writePEM(t, dir, name+".pub.pem", "PUBLIC KEY", der)
+	return
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newEdKey(t *testing.T) ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestLoadSigningKey(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	edKey := newEdKey(t)
+	rsaPrivate, rsaPublic := writeKeyFiles(t, "rsa", rsaKey)
+	edPrivate, edPublic := writeKeyFiles(t, "ed", edKey)
+
+	tests := []struct {
+		name        string
+		alg         string
+		privateFile string
+		publicFile  string
+		wantPublic  crypto.PublicKey
+		wantPrivate bool
+		wantErr     bool
+	}{
+		{name: "RSA private key", alg: "RS256", privateFile: rsaPrivate, wantPublic: &rsaKey.PublicKey, wantPrivate: true},
+		{name: "RSA public key", alg: "RS256", publicFile: rsaPublic, wantPublic: &rsaKey.PublicKey},
+		{name: "Ed25519 private key", alg: "EdDSA", privateFile: edPrivate, wantPublic: edKey.Public(), wantPrivate: true},
+		{name: "Ed25519 public key", alg: "EdDSA", publicFile: edPublic, wantPublic: edKey.Public()},
+		{name: "algorithm of other key", alg: "RS256", privateFile: edPrivate, wantErr: true},
+		{name: "unsupported algorithm", alg: "HS256", privateFile: rsaPrivate, wantErr: true},
+		{name: "missing file", alg: "RS256", privateFile: filepath.Join(t.TempDir(), "missing.pem"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := LoadSigningKey("key-1", tt.alg, tt.privateFile, tt.publicFile, time.Time{})
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("LoadSigningKey succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadSigningKey failed: %s", err)
+			}
+
+			if key.ID != "key-1" || key.Method.Alg() != tt.alg {
+				t.Errorf("key = %s %s, want key-1 %s", key.ID, key.Method.Alg(), tt.alg)
+			}
+			if !reflect.DeepEqual(key.PublicKey, tt.wantPublic) {
+				t.Errorf("PublicKey = %v, want %v", key.PublicKey, tt.wantPublic)
+			}
+			if (key.PrivateKey != nil) != tt.wantPrivate {
+				t.Errorf("has PrivateKey = %t, want %t", key.PrivateKey != nil, tt.wantPrivate)
+			}
+		})
+	}
+}
+
+func TestNewKeySet(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	signing := SigningKey{ID: "new", Method: jwt.SigningMethodRS256, PrivateKey: rsaKey, PublicKey: &rsaKey.PublicKey}
+	verifyOnly := SigningKey{ID: "old", Method: jwt.SigningMethodRS256, PublicKey: &rsaKey.PublicKey}
+
+	tests := []struct {
+		name         string
+		signingKeyID string
+		keys         []SigningKey
+		wantErr      bool
+	}{
+		{name: "no keys"},
+		{name: "signing key", signingKeyID: "new", keys: []SigningKey{signing, verifyOnly}},
+		{name: "missing signing key", signingKeyID: "other", keys: []SigningKey{signing}, wantErr: true},
+		{name: "verification only signing key", signingKeyID: "old", keys: []SigningKey{signing, verifyOnly}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKeySet(tt.signingKeyID, tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewKeySet error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// newTestManager initializes a JWTManager with the keys provided.
+func newTestManager(t *testing.T, signingKeyID string, keys ...SigningKey) JWTManager {
+	set, err := NewKeySet(signingKeyID, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewJWTManager("secret", set, "chat", "chat", time.Minute)
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldRSA := newRSAKey(t)
+	newEd := newEdKey(t)
+	old := SigningKey{ID: "old", Method: jwt.SigningMethodRS256, PrivateKey: oldRSA, PublicKey: &oldRSA.PublicKey}
+	current := SigningKey{ID: "new", Method: jwt.SigningMethodEdDSA, PrivateKey: newEd, PublicKey: newEd.Public()}
+
+	oldToken, err := newTestManager(t, "old", old).Generate(1, "session-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hmacToken, err := NewJWTManager("secret", KeySet{}, "chat", "chat", time.Minute).Generate(1, "session-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotating := old
+	rotating.VerifyUntil = time.Now().Add(time.Hour)
+	retired := old
+	retired.VerifyUntil = time.Now().Add(-time.Second)
+
+	tests := []struct {
+		name    string
+		manager JWTManager
+		token   string
+		wantErr bool
+	}{
+		{name: "key in rotation window", manager: newTestManager(t, "new", current, rotating), token: oldToken},
+		{name: "retired key", manager: newTestManager(t, "new", current, retired), token: oldToken, wantErr: true},
+		{name: "removed key", manager: newTestManager(t, "new", current), token: oldToken, wantErr: true},
+		{name: "hmac token with keys", manager: newTestManager(t, "new", current, rotating), token: hmacToken, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := tt.manager.Parse(tt.token)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Parse succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse failed: %s", err)
+			}
+			if claims.AccountID != 1 || claims.SessionID != "session-1" {
+				t.Errorf("claims = %+v, want session-1 of account 1", claims)
+			}
+		})
+	}
+
+	// The new tokens are signed with the current key.
+	manager := newTestManager(t, "new", current, rotating)
+	token, err := manager.Generate(1, "session-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, err := new(jwt.Parser).ParseUnverified(token, &Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header["kid"] != "new" || parsed.Method.Alg() != "EdDSA" {
+		t.Errorf("token header = %v, want kid new and EdDSA", parsed.Header)
+	}
+	_, err = manager.Parse(token)
+	if err != nil {
+		t.Errorf("Parse of new token failed: %s", err)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey := newRSAKey(t)
+	edKey := newEdKey(t)
+	retiredKey := newRSAKey(t)
+
+	set, err := NewKeySet("ed", []SigningKey{
+		{ID: "ed", Method: jwt.SigningMethodEdDSA, PrivateKey: edKey, PublicKey: edKey.Public()},
+		{ID: "rsa", Method: jwt.SigningMethodRS256, PublicKey: &rsaKey.PublicKey, VerifyUntil: time.Now().Add(time.Hour)},
+		{ID: "retired", Method: jwt.SigningMethodRS256, PublicKey: &retiredKey.PublicKey, VerifyUntil: time.Now().Add(-time.Second)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := set.JWKS()
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("len(Keys) = %d, want 2", len(jwks.Keys))
+	}
+
+	want := map[string]crypto.PublicKey{
+		"ed":  edKey.Public(),
+		"rsa": &rsaKey.PublicKey,
+	}
+	for _, jwk := range jwks.Keys {
+		wantKey, ok := want[jwk.KeyID]
+		if !ok {
+			t.Errorf("unexpected key %s", jwk.KeyID)
+			continue
+		}
+		if jwk.Use != "sig" {
+			t.Errorf("key %s use = %q, want sig", jwk.KeyID, jwk.Use)
+		}
+
+		// The published keys are read back by the OIDC platforms verification.
+		key, err := jwk.PublicKey()
+		if err != nil {
+			t.Errorf("PublicKey of key %s failed: %s", jwk.KeyID, err)
+			continue
+		}
+		if !reflect.DeepEqual(key, wantKey) {
+			t.Errorf("PublicKey of key %s = %v, want %v", jwk.KeyID, key, wantKey)
+		}
+	}
+}
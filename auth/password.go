@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
 
 	"golang.org/x/crypto/bcrypt"
@@ -9,12 +10,49 @@ import (
 // HashPassword uses the bcrypt algorithm to encrypt the password provided.
 //
 //	@param password string: password to encrypt.
+//	@param cost int: bcrypt cost. Values lower than bcrypt.MinCost use bcrypt.DefaultCost.
 //	@return $1 string: password encrypted.
 //	@return $2 error: bcrypt encryptation error.
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+func HashPassword(password string, cost int) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	if err != nil {
 		err = fmt.Errorf("failed to generate password: %s", err)
 	}
 	return string(bytes), err
 }
+
+// ComparePassword checks if the password provided matches with the bcrypt hash.
+//
+//	@param hash string: bcrypt hash stored.
+//	@param password string: plain password to check.
+//	@return match bool: true if the password matches with the hash.
+//	@return err error: invalid hash error.
+func ComparePassword(hash, password string) (match bool, err error) {
+	err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			err = nil
+			return
+		}
+		err = fmt.Errorf("failed to compare password: %s", err)
+		return
+	}
+	match = true
+	return
+}
+
+// NeedsRehash checks if the bcrypt hash was generated with a lower cost than the provided.
+//
+//	@param hash string: bcrypt hash stored.
+//	@param cost int: bcrypt cost configured. Values lower than bcrypt.MinCost use bcrypt.DefaultCost.
+//	@return $1 bool: true if the hash must be generated again.
+func NeedsRehash(hash string, cost int) bool {
+	if cost < bcrypt.MinCost {
+		cost = bcrypt.DefaultCost
+	}
+	hashCost, err := bcrypt.Cost([]byte(hash))
+	if err != nil {
+		return false
+	}
+	return hashCost < cost
+}
@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+)
+
+// NewPKCEVerifier generates a new PKCE code verifier for the OAuth2 authorization code flow.
+//
+//	@return verifier string: new code verifier.
+//	@return err error: random source error.
+func NewPKCEVerifier() (verifier string, err error) {
+	return RandomToken(32)
+}
+
+// PKCEChallenge gets the S256 code challenge of the PKCE code verifier.
+//
+//	@param verifier string: code verifier.
+//	@return $1 string: S256 code challenge.
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
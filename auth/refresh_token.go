@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// TokenPair keeps the tokens sent to the client for a session.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+
+	// ExpiresIn is the number of seconds to expire of the access token.
+	ExpiresIn int `json:"expires_in"`
+}
+
+// NewRefreshToken generates a new random refresh token.
+//
+//	@return token string: new refresh token.
+//	@return err error: random source error.
+func NewRefreshToken() (token string, err error) {
+	return RandomToken(32)
+}
+
+// RandomToken generates a new random URL safe token.
+//
+//	@param size int: number of random bytes of the token.
+//	@return token string: base64url encoded token.
+//	@return err error: random source error.
+func RandomToken(size int) (token string, err error) {
+	b := make([]byte, size)
+	_, err = rand.Read(b)
+	if err != nil {
+		err = fmt.Errorf("failed to generate random token: %s", err)
+		return
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return
+}
+
+// HashToken gets the SHA-256 hash of the token to be stored instead of the token itself.
+//
+//	@param token string: token to hash.
+//	@return $1 string: hex encoded hash.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
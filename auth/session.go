@@ -5,8 +5,12 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
+// TmpIDLifetime is the time to expire of the TmpID of a new session.
+const TmpIDLifetime = 5 * time.Minute
+
 // Session represents the session of a account in a client.
 type Session struct {
 	ID string `json:"id,omitempty"`
@@ -17,6 +21,9 @@ type Session struct {
 	TmpID     string `json:"tmp_id,omitempty"`
 	AccountID int    `json:"account_id,omitempty"`
 
+	// TmpIDExpiresAt is the expiration time of the TmpID.
+	TmpIDExpiresAt time.Time `json:"-"`
+
 	// First time that the account has been sign.
 	LoggedAt time.Time `json:"logged_at,omitempty"`
 
@@ -38,13 +45,13 @@ type Session struct {
 //		@return err error: session encryptation error.
 func NewSession(accountID int, loggedWith string) (session Session, err error) {
 	// Generate new encrypted session ID with the accountID and a random uuid string.
-	sessionID, err := HashPassword(fmt.Sprint(accountID, uuid.NewString()))
+	sessionID, err := HashPassword(fmt.Sprint(accountID, uuid.NewString()), bcrypt.MinCost)
 	if err != nil {
 		return
 	}
 
 	// Generate a new encrypted temp ID of just one use.
-	tmpID, err := HashPassword(fmt.Sprint(uuid.NewString(), sessionID))
+	tmpID, err := HashPassword(fmt.Sprint(uuid.NewString(), sessionID), bcrypt.MinCost)
 	if err != nil {
 		return
 	}
@@ -52,13 +59,14 @@ func NewSession(accountID int, loggedWith string) (session Session, err error) {
 	now := time.Now()
 
 	session = Session{
-		ID:         sessionID,
-		TmpID:      tmpID,
-		AccountID:  accountID,
-		LoggedWith: loggedWith,
-		LoggedAt:   now,
-		LastSeenAt: now,
-		Actived:    true,
+		ID:             sessionID,
+		TmpID:          tmpID,
+		AccountID:      accountID,
+		TmpIDExpiresAt: now.Add(TmpIDLifetime),
+		LoggedWith:     loggedWith,
+		LoggedAt:       now,
+		LastSeenAt:     now,
+		Actived:        true,
 	}
 	return
 }
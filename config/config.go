@@ -1,6 +1,13 @@
 package config
 
-import "golang.org/x/oauth2"
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/endpoints"
+)
 
 // Config is a interface to get the config of a given implementation.
 type Config interface {
@@ -13,26 +20,184 @@ type ConfigInfo struct {
 	Server               server               `yaml:"server"`
 	OAuth                oauth                `yaml:"oauth"`
 	PostgreSQLProperties postgreSQLProperties `yaml:"psql"`
+	Storage              storage              `yaml:"storage"`
+	Mail                 mail                 `yaml:"mail"`
 }
 
 type server struct {
 	Port           int      `yaml:"port"`
 	Host           string   `yaml:"host"`
 	AllowedOrigins []string `yaml:"allowed_origins"`
-	SecretKey      string   `yaml:"secret_key"`
+
+	// SecretKey signs the file URLs and the email tokens, and the session tokens when Token.Keys is empty.
+	// Always required.
+	SecretKey string `yaml:"secret_key"`
+
+	// HashCost is the bcrypt cost used to encrypt the passwords, up to bcrypt.MaxCost.
+	HashCost int `yaml:"hash_cost"`
+
+	// PublicURL is the absolute base URL of the server, used on the URLs sent to the clients.
+	PublicURL string `yaml:"public_url"`
+
+	Token    token    `yaml:"token"`
+	Messages messages `yaml:"messages"`
+	Accounts accounts `yaml:"accounts"`
+}
+
+// accounts keeps the properties of the accounts lifecycle.
+type accounts struct {
+	// DeletionGracePeriod is the time since a account is deleted while it can be restored signing in again.
+	// Then its personal data is removed.
+	DeletionGracePeriod time.Duration `yaml:"deletion_grace_period"`
+}
+
+// messages keeps the properties of the conversation messages.
+type messages struct {
+	// EditWindow is the time since a message is sent while its author can edit it.
+	EditWindow time.Duration `yaml:"edit_window"`
+}
+
+// token keeps the properties of the session tokens.
+type token struct {
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+
+	// AccessLifetime is the time to expire of the access tokens.
+	AccessLifetime time.Duration `yaml:"access_lifetime"`
+
+	// RefreshLifetime is the time to expire of the refresh tokens.
+	RefreshLifetime time.Duration `yaml:"refresh_lifetime"`
+
+	// SigningKeyID is the id of the key used to sign the new tokens.
+	SigningKeyID string `yaml:"signing_key_id"`
+
+	// Keys keeps the asymmetric signing keys. If it's empty, the tokens are signed with the Server.SecretKey,
+	// which is required anyway for the other signatures.
+	Keys []signingKey `yaml:"keys"`
+}
+
+// signingKey keeps the properties of a asymmetric signing key.
+type signingKey struct {
+	ID string `yaml:"id"`
+
+	// Algorithm is the signing algorithm of the key. Supported algorithms: RS256, EdDSA.
+	Algorithm      string `yaml:"algorithm"`
+	PrivateKeyFile string `yaml:"private_key_file"`
+	PublicKeyFile  string `yaml:"public_key_file"`
+
+	// VerifyUntil is the end of the rotation window of a retired key. Required for all the keys
+	// except the signing key.
+	VerifyUntil time.Time `yaml:"verify_until"`
 }
 
 type oauth struct {
-	Google   oauthProperties `yaml:"google"`
-	Facebook oauthProperties `yaml:"facebook"`
+	Google   OAuthProperties `yaml:"google"`
+	Facebook OAuthProperties `yaml:"facebook"`
+
+	// OIDC keeps the OpenID Connect sign platforms.
+	OIDC []OIDCProperties `yaml:"oidc"`
+
+	// ClientRedirectURL is the client URL to redirect after a external sign.
+	// The TmpID of the new session is sent in the URL fragment. If it's empty, the TmpID is sent as JSON.
+	ClientRedirectURL string `yaml:"client_redirect_url"`
+}
+
+// OAuthProperties keeps the properties of a OAuth2 sign platform.
+type OAuthProperties struct {
+	ClientID     string          `yaml:"client_id"`
+	ClientSecret string          `yaml:"client_secret"`
+	RedirectURIS []string        `yaml:"redirect_uris"`
+	Scopes       []string        `yaml:"scopes"`
+	Endpoint     oauth2.Endpoint `yaml:"endpoint"`
+
+	// UserInfoURL is the platform URL to get the account info with the access token.
+	UserInfoURL string `yaml:"user_info_url"`
 }
 
-type oauthProperties struct {
+// Enabled checks if the platform is configured.
+func (o OAuthProperties) Enabled() bool {
+	return o.ClientID != ""
+}
+
+// OIDCProperties keeps the properties of a OpenID Connect sign platform.
+// The platform endpoints are discovered with its issuer URL.
+type OIDCProperties struct {
+	// Name is the platform name used in the sign routes. Must be unique.
+	Name         string   `yaml:"name"`
+	IssuerURL    string   `yaml:"issuer_url"`
 	ClientID     string   `yaml:"client_id"`
 	ClientSecret string   `yaml:"client_secret"`
 	RedirectURIS []string `yaml:"redirect_uris"`
 	Scopes       []string `yaml:"scopes"`
-	Endpoint     oauth2.Endpoint
+
+	Claims oidcClaims `yaml:"claims"`
+
+	// AllowedEmailDomains restricts the sign to the accounts with a verified email of the domains.
+	// If it's empty, any account can sign.
+	AllowedEmailDomains []string `yaml:"allowed_email_domains"`
+}
+
+// oidcClaims maps the ID token claims to the account fields.
+type oidcClaims struct {
+	Nickname string `yaml:"nickname"`
+	Email    string `yaml:"email"`
+	Name     string `yaml:"name"`
+	LastName string `yaml:"last_name"`
+}
+
+// storage keeps the properties of the uploaded files store.
+type storage struct {
+	// Driver is the blob store implementation. Supported drivers: local, s3.
+	Driver string `yaml:"driver"`
+
+	// MaxUploadSize is the max size in bytes of a uploaded file.
+	MaxUploadSize int64 `yaml:"max_upload_size"`
+
+	// URLLifetime is the time to expire of the signed download URLs.
+	URLLifetime time.Duration `yaml:"url_lifetime"`
+
+	// ThumbnailWorkers is the number of background workers which generate the image thumbnails.
+	ThumbnailWorkers int `yaml:"thumbnail_workers"`
+
+	Local localStorage `yaml:"local"`
+	S3    s3Storage    `yaml:"s3"`
+}
+
+// localStorage keeps the properties of the local filesystem store.
+type localStorage struct {
+	Dir string `yaml:"dir"`
+}
+
+// s3Storage keeps the properties of a S3 compatible store.
+type s3Storage struct {
+	Endpoint        string `yaml:"endpoint"`
+	Region          string `yaml:"region"`
+	Bucket          string `yaml:"bucket"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+}
+
+// mail keeps the properties of the emails sent to the accounts.
+type mail struct {
+	// Driver is the mailer implementation. Supported drivers: smtp, memory.
+	// Always required, the memory driver doesn't send the emails.
+	Driver string `yaml:"driver"`
+
+	// From is the sender address of the emails.
+	From string `yaml:"from"`
+
+	// AppURL is the absolute base URL of the client app, used on the links of the emails.
+	AppURL string `yaml:"app_url"`
+
+	SMTP smtpMail `yaml:"smtp"`
+}
+
+// smtpMail keeps the properties of the SMTP server.
+type smtpMail struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
 }
 
 type postgreSQLProperties struct {
@@ -42,3 +207,123 @@ type postgreSQLProperties struct {
 	Host     string `yaml:"host"`
 	Port     int    `yaml:"port"`
 }
+
+// validate checks the config values which can't be fixed with a default.
+//
+//	@param conf ConfigInfo: config to validate.
+//	 @return err error: invalid config error.
+func validate(conf ConfigInfo) (err error) {
+	if conf.Server.SecretKey == "" {
+		err = fmt.Errorf("invalid config: secret key is required")
+		return
+	}
+
+	if conf.Mail.Driver == "" {
+		err = fmt.Errorf("invalid config: mail driver is required")
+		return
+	}
+
+	if conf.Server.HashCost > bcrypt.MaxCost {
+		err = fmt.Errorf("invalid config: hash cost %d exceeds the max bcrypt cost %d", conf.Server.HashCost, bcrypt.MaxCost)
+		return
+	}
+
+	// The keys which don't sign the new tokens are retired, so they must have a end of rotation.
+	for _, key := range conf.Server.Token.Keys {
+		if key.ID != conf.Server.Token.SigningKeyID && key.VerifyUntil.IsZero() {
+			err = fmt.Errorf("invalid config: verify until of the not signing key %s is required", key.ID)
+			return
+		}
+	}
+	return
+}
+
+// setDefaults fills the optional config fields not provided.
+func setDefaults(conf *ConfigInfo) {
+	if conf.Server.Token.Issuer == "" {
+		conf.Server.Token.Issuer = "chat"
+	}
+	if conf.Server.Token.Audience == "" {
+		conf.Server.Token.Audience = "chat"
+	}
+	if conf.Server.Token.AccessLifetime == 0 {
+		conf.Server.Token.AccessLifetime = 15 * time.Minute
+	}
+	if conf.Server.Token.RefreshLifetime == 0 {
+		conf.Server.Token.RefreshLifetime = 30 * 24 * time.Hour
+	}
+	if conf.Server.Messages.EditWindow == 0 {
+		conf.Server.Messages.EditWindow = 15 * time.Minute
+	}
+	if conf.Server.Accounts.DeletionGracePeriod == 0 {
+		conf.Server.Accounts.DeletionGracePeriod = 30 * 24 * time.Hour
+	}
+	if conf.Server.PublicURL == "" {
+		host := conf.Server.Host
+		if host == "" {
+			host = "localhost"
+		}
+		conf.Server.PublicURL = fmt.Sprintf("http://%s:%d", host, conf.Server.Port)
+	}
+	if conf.Mail.AppURL == "" {
+		conf.Mail.AppURL = conf.Server.PublicURL
+	}
+	if conf.Mail.SMTP.Port == 0 {
+		conf.Mail.SMTP.Port = 587
+	}
+	if conf.Storage.Driver == "" {
+		conf.Storage.Driver = "local"
+	}
+	if conf.Storage.MaxUploadSize == 0 {
+		conf.Storage.MaxUploadSize = 10 << 20
+	}
+	if conf.Storage.URLLifetime == 0 {
+		conf.Storage.URLLifetime = time.Hour
+	}
+	if conf.Storage.ThumbnailWorkers == 0 {
+		conf.Storage.ThumbnailWorkers = 2
+	}
+	if conf.Storage.Local.Dir == "" {
+		conf.Storage.Local.Dir = "data/blobs"
+	}
+
+	setOAuthDefaults(&conf.OAuth.Google, endpoints.Google, "https://openidconnect.googleapis.com/v1/userinfo", []string{"openid", "email", "profile"})
+	setOAuthDefaults(&conf.OAuth.Facebook, endpoints.Facebook, "https://graph.facebook.com/me?fields=id,name,first_name,last_name,email", []string{"email", "public_profile"})
+
+	for i := range conf.OAuth.OIDC {
+		setOIDCDefaults(&conf.OAuth.OIDC[i])
+	}
+}
+
+func setOIDCDefaults(props *OIDCProperties) {
+	if len(props.Scopes) == 0 {
+		props.Scopes = []string{"openid", "email", "profile"}
+	}
+	if props.Claims.Nickname == "" {
+		props.Claims.Nickname = "preferred_username"
+	}
+	if props.Claims.Email == "" {
+		props.Claims.Email = "email"
+	}
+	if props.Claims.Name == "" {
+		props.Claims.Name = "given_name"
+	}
+	if props.Claims.LastName == "" {
+		props.Claims.LastName = "family_name"
+	}
+}
+
+func setOAuthDefaults(props *OAuthProperties, endpoint oauth2.Endpoint, userInfoURL string, scopes []string) {
+	if props.Endpoint.AuthURL == "" {
+		props.Endpoint.AuthURL = endpoint.AuthURL
+	}
+	if props.Endpoint.TokenURL == "" {
+		props.Endpoint.TokenURL = endpoint.TokenURL
+	}
+	if props.UserInfoURL == "" {
+		props.UserInfoURL = userInfoURL
+	}
+	if len(props.Scopes) == 0 {
+		props.Scopes = scopes
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // EnvManagerConfig is the Config implementation for the environment config vars.
@@ -36,12 +37,89 @@ func newConfigWithEnvVars() (conf ConfigInfo, err error) {
 		return
 	}
 
+	hashCost, err := getOptionalEnvInt("SRV_HASH_COST")
+	if err != nil {
+		return
+	}
+
+	accessLifetime, err := getOptionalEnvDuration("SRV_ACCESS_TOKEN_LIFETIME")
+	if err != nil {
+		return
+	}
+
+	refreshLifetime, err := getOptionalEnvDuration("SRV_REFRESH_TOKEN_LIFETIME")
+	if err != nil {
+		return
+	}
+
+	signingKeys, err := getEnvSigningKeys("SRV_TOKEN_KEYS")
+	if err != nil {
+		return
+	}
+
+	verifyKeys, err := getEnvVerifyKeys("SRV_TOKEN_VERIFY_KEYS")
+	if err != nil {
+		return
+	}
+
+	editWindow, err := getOptionalEnvDuration("SRV_MESSAGE_EDIT_WINDOW")
+	if err != nil {
+		return
+	}
+
+	deletionGracePeriod, err := getOptionalEnvDuration("SRV_ACCOUNT_DELETION_GRACE_PERIOD")
+	if err != nil {
+		return
+	}
+
+	maxUploadSize, err := getOptionalEnvInt("STORAGE_MAX_UPLOAD_SIZE")
+	if err != nil {
+		return
+	}
+
+	urlLifetime, err := getOptionalEnvDuration("STORAGE_URL_LIFETIME")
+	if err != nil {
+		return
+	}
+
+	thumbnailWorkers, err := getOptionalEnvInt("STORAGE_THUMBNAIL_WORKERS")
+	if err != nil {
+		return
+	}
+
+	smtpPort, err := getOptionalEnvInt("MAIL_SMTP_PORT")
+	if err != nil {
+		return
+	}
+
 	conf = ConfigInfo{
 		Server: server{
 			Port:           srvPort,
 			Host:           os.Getenv("SRV_HOST"),
 			AllowedOrigins: strings.Split(os.Getenv("SRV_ALLOWED_ORIGINS"), ";"),
 			SecretKey:      os.Getenv("SRV_SECRET_KEY"),
+			HashCost:       hashCost,
+			PublicURL:      os.Getenv("SRV_PUBLIC_URL"),
+			Token: token{
+				Issuer:          os.Getenv("SRV_TOKEN_ISSUER"),
+				Audience:        os.Getenv("SRV_TOKEN_AUDIENCE"),
+				AccessLifetime:  accessLifetime,
+				RefreshLifetime: refreshLifetime,
+				SigningKeyID:    os.Getenv("SRV_TOKEN_SIGNING_KEY_ID"),
+				Keys:            append(signingKeys, verifyKeys...),
+			},
+			Messages: messages{
+				EditWindow: editWindow,
+			},
+			Accounts: accounts{
+				DeletionGracePeriod: deletionGracePeriod,
+			},
+		},
+		OAuth: oauth{
+			Google:            newOAuthPropertiesWithEnvVars("GOOGLE"),
+			Facebook:          newOAuthPropertiesWithEnvVars("FACEBOOK"),
+			OIDC:              newOIDCPropertiesWithEnvVars(),
+			ClientRedirectURL: os.Getenv("OAUTH_CLIENT_REDIRECT_URL"),
 		},
 		PostgreSQLProperties: postgreSQLProperties{
 			User:     os.Getenv("DB_USER"),
@@ -50,10 +128,86 @@ func newConfigWithEnvVars() (conf ConfigInfo, err error) {
 			Host:     os.Getenv("DB_HOST"),
 			Port:     dbPort,
 		},
+		Storage: storage{
+			Driver:           os.Getenv("STORAGE_DRIVER"),
+			MaxUploadSize:    int64(maxUploadSize),
+			URLLifetime:      urlLifetime,
+			ThumbnailWorkers: thumbnailWorkers,
+			Local: localStorage{
+				Dir: os.Getenv("STORAGE_LOCAL_DIR"),
+			},
+			S3: s3Storage{
+				Endpoint:        os.Getenv("STORAGE_S3_ENDPOINT"),
+				Region:          os.Getenv("STORAGE_S3_REGION"),
+				Bucket:          os.Getenv("STORAGE_S3_BUCKET"),
+				AccessKeyID:     os.Getenv("STORAGE_S3_ACCESS_KEY_ID"),
+				SecretAccessKey: os.Getenv("STORAGE_S3_SECRET_ACCESS_KEY"),
+			},
+		},
+		Mail: mail{
+			Driver: os.Getenv("MAIL_DRIVER"),
+			From:   os.Getenv("MAIL_FROM"),
+			AppURL: os.Getenv("MAIL_APP_URL"),
+			SMTP: smtpMail{
+				Host:     os.Getenv("MAIL_SMTP_HOST"),
+				Port:     smtpPort,
+				Username: os.Getenv("MAIL_SMTP_USERNAME"),
+				Password: os.Getenv("MAIL_SMTP_PASSWORD"),
+			},
+		},
+	}
+	setDefaults(&conf)
+	err = validate(conf)
+	return
+}
+
+// newOAuthPropertiesWithEnvVars loads the oauth properties of the env vars with the prefix provided.
+// The list values are separated by ";".
+func newOAuthPropertiesWithEnvVars(prefix string) (props OAuthProperties) {
+	props = OAuthProperties{
+		ClientID:     os.Getenv(prefix + "_CLIENT_ID"),
+		ClientSecret: os.Getenv(prefix + "_CLIENT_SECRET"),
+		UserInfoURL:  os.Getenv(prefix + "_USER_INFO_URL"),
+		RedirectURIS: getEnvList(prefix + "_REDIRECT_URIS"),
+		Scopes:       getEnvList(prefix + "_SCOPES"),
+	}
+	props.Endpoint.AuthURL = os.Getenv(prefix + "_AUTH_URL")
+	props.Endpoint.TokenURL = os.Getenv(prefix + "_TOKEN_URL")
+	return
+}
+
+// newOIDCPropertiesWithEnvVars loads the OpenID Connect platforms of the env vars.
+// The platform names are listed in OIDC_PROVIDERS, and its properties are loaded with the
+// OIDC_<NAME> prefix, like OIDC_<NAME>_ISSUER_URL.
+func newOIDCPropertiesWithEnvVars() (providers []OIDCProperties) {
+	for _, name := range getEnvList("OIDC_PROVIDERS") {
+		prefix := "OIDC_" + strings.ToUpper(name)
+		providers = append(providers, OIDCProperties{
+			Name:                name,
+			IssuerURL:           os.Getenv(prefix + "_ISSUER_URL"),
+			ClientID:            os.Getenv(prefix + "_CLIENT_ID"),
+			ClientSecret:        os.Getenv(prefix + "_CLIENT_SECRET"),
+			RedirectURIS:        getEnvList(prefix + "_REDIRECT_URIS"),
+			Scopes:              getEnvList(prefix + "_SCOPES"),
+			AllowedEmailDomains: getEnvList(prefix + "_ALLOWED_EMAIL_DOMAINS"),
+			Claims: oidcClaims{
+				Nickname: os.Getenv(prefix + "_NICKNAME_CLAIM"),
+				Email:    os.Getenv(prefix + "_EMAIL_CLAIM"),
+				Name:     os.Getenv(prefix + "_NAME_CLAIM"),
+				LastName: os.Getenv(prefix + "_LAST_NAME_CLAIM"),
+			},
+		})
 	}
 	return
 }
 
+func getEnvList(n string) (l []string) {
+	if os.Getenv(n) == "" {
+		return
+	}
+	return strings.Split(os.Getenv(n), ";")
+}
+
 func getEnvInt(n string) (i int, err error) {
 	i, err = strconv.Atoi(os.Getenv(n))
 	if err != nil {
@@ -61,3 +215,85 @@ func getEnvInt(n string) (i int, err error) {
 	}
 	return
 }
+
+func getOptionalEnvInt(n string) (i int, err error) {
+	if os.Getenv(n) == "" {
+		return
+	}
+	return getEnvInt(n)
+}
+
+func getOptionalEnvDuration(n string) (d time.Duration, err error) {
+	if os.Getenv(n) == "" {
+		return
+	}
+	d, err = time.ParseDuration(os.Getenv(n))
+	if err != nil {
+		err = fmt.Errorf("failed to load env var duration %s: %s", n, err)
+	}
+	return
+}
+
+// getEnvSigningKeys loads the signing keys of the env var.
+// The keys are separated by ";" with the format "id,algorithm,private_key_file[,verify_until]".
+// The verify_until value uses the RFC 3339 format.
+func getEnvSigningKeys(n string) (keys []signingKey, err error) {
+	v := os.Getenv(n)
+	if v == "" {
+		return
+	}
+
+	for _, rawKey := range strings.Split(v, ";") {
+		fields := strings.Split(rawKey, ",")
+		if len(fields) < 3 || len(fields) > 4 {
+			err = fmt.Errorf("failed to load env var signing keys %s: invalid key format %s", n, rawKey)
+			return
+		}
+
+		key := signingKey{
+			ID:             fields[0],
+			Algorithm:      fields[1],
+			PrivateKeyFile: fields[2],
+		}
+		if len(fields) == 4 {
+			key.VerifyUntil, err = time.Parse(time.RFC3339, fields[3])
+			if err != nil {
+				err = fmt.Errorf("failed to load env var signing keys %s: %s", n, err)
+				return
+			}
+		}
+		keys = append(keys, key)
+	}
+	return
+}
+
+// getEnvVerifyKeys loads the verification only keys of the env var, the public keys of the retired keys.
+// The keys are separated by ";" with the format "id,algorithm,public_key_file,verify_until".
+// The verify_until value uses the RFC 3339 format.
+func getEnvVerifyKeys(n string) (keys []signingKey, err error) {
+	v := os.Getenv(n)
+	if v == "" {
+		return
+	}
+
+	for _, rawKey := range strings.Split(v, ";") {
+		fields := strings.Split(rawKey, ",")
+		if len(fields) != 4 {
+			err = fmt.Errorf("failed to load env var verify keys %s: invalid key format %s", n, rawKey)
+			return
+		}
+
+		key := signingKey{
+			ID:            fields[0],
+			Algorithm:     fields[1],
+			PublicKeyFile: fields[2],
+		}
+		key.VerifyUntil, err = time.Parse(time.RFC3339, fields[3])
+		if err != nil {
+			err = fmt.Errorf("failed to load env var verify keys %s: %s", n, err)
+			return
+		}
+		keys = append(keys, key)
+	}
+	return
+}
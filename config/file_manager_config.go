@@ -51,6 +51,9 @@ func newConfigWithBytes(b []byte) (c ConfigInfo, err error) {
 	err = yaml.Unmarshal(b, &c)
 	if err != nil {
 		err = fmt.Errorf("invalid config: failed to get config info. Bad structure?")
+		return
 	}
+	setDefaults(&c)
+	err = validate(c)
 	return
 }
@@ -0,0 +1,69 @@
+package contact
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/coffemanfp/chat/account"
+	"github.com/coffemanfp/chat/errors"
+)
+
+// MaxNameLength is the max number of characters of a contact name or last name.
+const MaxNameLength = 64
+
+// Contact is the representation of a account saved in the address book of other account.
+type Contact struct {
+	ID            int    `json:"id,omitempty"`
+	FromAccountID int    `json:"-"`
+	Name          string `json:"name,omitempty"`
+	LastName      string `json:"last_name,omitempty"`
+
+	// Profile is the public profile of the saved account.
+	Profile account.Profile `json:"profile"`
+
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// New initializes a new contact of the account based on the basic data provided from the contact passed as param.
+// The name is optional, the saved account name is used if it's missing.
+//
+//	@param fromAccountID int: id of the address book owner.
+//	@param contactR Contact: Basic data of the contact to build.
+//	@return contact Contact: Contact builded.
+//	@return err error: error in the validation of the based contact.
+func New(fromAccountID int, contactR Contact) (contact Contact, err error) {
+	contact = contactR
+	contact.FromAccountID = fromAccountID
+	contact.Name = strings.TrimSpace(contact.Name)
+	contact.LastName = strings.TrimSpace(contact.LastName)
+	contact.CreatedAt = time.Now()
+
+	if contact.Name == "" && contact.LastName != "" {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid name: last name requires a name")
+		return
+	}
+	err = ValidateNames(contact.Name, contact.LastName)
+	return
+}
+
+// ValidateNames checks the contact name and last name lengths.
+//
+//	@param name string: name to validate.
+//	@param lastName string: last name to validate.
+//	 @return err error: too long name or last name.
+func ValidateNames(name, lastName string) (err error) {
+	if utf8.RuneCountInString(name) > MaxNameLength || utf8.RuneCountInString(lastName) > MaxNameLength {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid name: contact names exceed %d characters", MaxNameLength)
+	}
+	return
+}
+
+// NewNotFoundError initializes the error returned when the contact doesn't exists in the address book.
+//
+//	@param id int: contact id.
+//	@return $1 error: not found ClientError.
+func NewNotFoundError(id int) error {
+	return errors.NewClientError(http.StatusNotFound, "not found: contact %d not found", id)
+}
@@ -0,0 +1,3 @@
+// Package contact handles all the address book logic, like creation, validation and listing options.
+
+package contact
@@ -0,0 +1,78 @@
+package contact
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/coffemanfp/chat/errors"
+)
+
+const (
+	// DefaultLimit is the page size of the listings without limit.
+	DefaultLimit = 50
+
+	// MaxLimit is the max page size of a listing.
+	MaxLimit = 200
+)
+
+// SortField is a field to sort the contacts listing.
+type SortField string
+
+const (
+	// SortByName sorts the contacts by name and last name.
+	SortByName SortField = "name"
+
+	// SortByCreatedAt sorts the contacts by their addition time.
+	SortByCreatedAt SortField = "created_at"
+)
+
+// Query are the listing options of the contacts of a account.
+type Query struct {
+	// Search filters the contacts by name, last name or nickname. Empty means no filter.
+	Search string
+
+	Sort       SortField
+	Descending bool
+
+	Limit  int
+	Offset int
+}
+
+// NewQuery initializes a new Query with the defaults of the missing options.
+//
+//	@param search string: search filter.
+//	@param sort string: sort field, prefixed with "-" for the descending order. Empty means SortByName.
+//	@param limit int: page size. 0 means DefaultLimit.
+//	@param offset int: number of contacts to skip.
+//	@return query Query: Query builded.
+//	@return err error: unknown sort field or invalid page.
+func NewQuery(search, sort string, limit, offset int) (query Query, err error) {
+	query = Query{
+		Search: strings.TrimSpace(search),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	if strings.HasPrefix(sort, "-") {
+		query.Descending = true
+		sort = sort[1:]
+	}
+
+	switch SortField(sort) {
+	case "", SortByName:
+		query.Sort = SortByName
+	case SortByCreatedAt:
+		query.Sort = SortByCreatedAt
+	default:
+		err = errors.NewClientError(http.StatusBadRequest, "invalid sort: unknown sort field %s", sort)
+		return
+	}
+
+	if query.Limit == 0 {
+		query.Limit = DefaultLimit
+	}
+	if query.Limit < 0 || query.Limit > MaxLimit || query.Offset < 0 {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid page: limit must be between 1 and %d and offset can't be negative", MaxLimit)
+	}
+	return
+}
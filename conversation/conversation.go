@@ -0,0 +1,215 @@
+package conversation
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/coffemanfp/chat/account"
+	"github.com/coffemanfp/chat/errors"
+)
+
+const (
+	// DefaultCapacityMembers is the capacity of the conversations created without capacity.
+	DefaultCapacityMembers = 256
+
+	// MaxCapacityMembers is the max capacity of a conversation.
+	MaxCapacityMembers = 1024
+
+	// MaxNameLength is the max number of characters of a conversation name.
+	MaxNameLength = 64
+)
+
+const (
+	// OwnerRole is the name of the role given to the creator of a conversation.
+	OwnerRole = "owner"
+
+	// MemberRole is the name of the role given to the added members.
+	MemberRole = "member"
+)
+
+const (
+	// GroupType is the type of the conversations with a name, a picture and managed members.
+	GroupType = "group"
+
+	// DirectType is the type of the one to one conversations between two accounts.
+	DirectType = "direct"
+)
+
+// Conversation is the representation of a group or direct conversation.
+type Conversation struct {
+	ID              int       `json:"id,omitempty"`
+	Type            string    `json:"type,omitempty"`
+	Name            string    `json:"name,omitempty"`
+	PictureURL      string    `json:"picture_url,omitempty"`
+	CapacityMembers int       `json:"capacity_members,omitempty"`
+	CreatedAt       time.Time `json:"created_at,omitempty"`
+
+	// Peer is the other participant of a direct conversation, from the point of view of the account.
+	Peer *account.Profile `json:"peer,omitempty"`
+}
+
+// Summary is a conversation listed for a member, with its unread messages counter.
+type Summary struct {
+	Conversation
+
+	// UnreadCount is the number of messages sent after the last message read by the member,
+	// without the deleted messages and the messages of the accounts blocked by the member.
+	UnreadCount int64 `json:"unread_count"`
+}
+
+// Member is the representation of a current member of a conversation.
+type Member struct {
+	AccountID int       `json:"account_id,omitempty"`
+	Role      string    `json:"role,omitempty"`
+	JoinedAt  time.Time `json:"joined_at,omitempty"`
+}
+
+// Membership is the current membership of a account in a conversation.
+type Membership struct {
+	Role     Role
+	JoinedAt time.Time
+}
+
+// New initializes a new conversation based on the basic data provided from the conversation passed as param.
+//
+//	@param conversationR Conversation: Basic data of the conversation to build.
+//	@return conversation Conversation: Conversation builded.
+//	@return err error: error in the validation of the based conversation.
+func New(conversationR Conversation) (conversation Conversation, err error) {
+	conversation = conversationR
+	conversation.Type = GroupType
+	conversation.Peer = nil
+	conversation.Name = strings.TrimSpace(conversation.Name)
+	if conversation.CapacityMembers == 0 {
+		conversation.CapacityMembers = DefaultCapacityMembers
+	}
+
+	err = Validate(conversation)
+	if err != nil {
+		return
+	}
+	conversation.CreatedAt = time.Now()
+	return
+}
+
+// Validate validates the details of the conversation.
+//
+//	@param conversation Conversation: conversation to validate.
+//	 @return err error: invalid name, picture url or capacity.
+func Validate(conversation Conversation) (err error) {
+	err = ValidateName(conversation.Name)
+	if err != nil {
+		return
+	}
+	err = ValidatePictureURL(conversation.PictureURL)
+	if err != nil {
+		return
+	}
+	err = ValidateCapacity(conversation.CapacityMembers)
+	return
+}
+
+// ValidateName checks the conversation name length.
+//
+//	@param name string: name to validate.
+//	 @return err error: empty or too long name.
+func ValidateName(name string) (err error) {
+	if name == "" {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid name: empty conversation name")
+		return
+	}
+	if utf8.RuneCountInString(name) > MaxNameLength {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid name: conversation name exceeds %d characters", MaxNameLength)
+	}
+	return
+}
+
+// ValidatePictureURL checks the conversation picture is empty or a absolute http url.
+//
+//	@param pictureURL string: picture url to validate.
+//	 @return err error: invalid url.
+func ValidatePictureURL(pictureURL string) (err error) {
+	if pictureURL == "" {
+		return
+	}
+	u, pErr := url.Parse(pictureURL)
+	if pErr != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid picture url: invalid url format of %s", pictureURL)
+	}
+	return
+}
+
+// ValidateCapacity checks the capacity of members of a conversation.
+//
+//	@param capacity int: capacity to validate.
+//	 @return err error: capacity out of range.
+func ValidateCapacity(capacity int) (err error) {
+	if capacity < 2 || capacity > MaxCapacityMembers {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid capacity members: capacity must be between 2 and %d", MaxCapacityMembers)
+	}
+	return
+}
+
+// ValidateMembers checks the number of members fits in the conversation capacity.
+//
+//	@param conversation Conversation: conversation to join.
+//	@param current int: number of current members.
+//	@param added int: number of members to add.
+//	 @return err error: conversation capacity exceeded.
+func ValidateMembers(conversation Conversation, current, added int) (err error) {
+	if current+added > conversation.CapacityMembers {
+		err = errors.NewClientError(http.StatusConflict, "conversation full: conversation %d allows up to %d members", conversation.ID, conversation.CapacityMembers)
+	}
+	return
+}
+
+// UniqueIDs removes the duplicated and excluded ids of the account ids provided.
+//
+//	@param ids []int: account ids.
+//	@param exclude int: account id to remove.
+//	@return unique []int: unique account ids in the same order.
+func UniqueIDs(ids []int, exclude int) (unique []int) {
+	seen := map[int]bool{exclude: true}
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		unique = append(unique, id)
+	}
+	return
+}
+
+// ExcludeIDs removes the excluded ids of the account ids provided.
+//
+//	@param ids []int: account ids.
+//	@param excluded []int: account ids to remove.
+//	@return filtered []int: remaining account ids in the same order.
+func ExcludeIDs(ids, excluded []int) (filtered []int) {
+	if len(excluded) == 0 {
+		return ids
+	}
+
+	skip := make(map[int]bool, len(excluded))
+	for _, id := range excluded {
+		skip[id] = true
+	}
+	for _, id := range ids {
+		if !skip[id] {
+			filtered = append(filtered, id)
+		}
+	}
+	return
+}
+
+// NewNotFoundError initializes the error returned when the conversation doesn't exists,
+// is deleted or the account is not one of its members.
+//
+//	@param id int: conversation id.
+//	@return $1 error: not found ClientError.
+func NewNotFoundError(id int) error {
+	return errors.NewClientError(http.StatusNotFound, "not found: conversation %d not found", id)
+}
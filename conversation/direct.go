@@ -0,0 +1,69 @@
+package conversation
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coffemanfp/chat/account"
+	"github.com/coffemanfp/chat/errors"
+)
+
+// NewDirect initializes a new direct conversation between two accounts.
+// The direct conversations have no name or picture, they are taken from the peer profile.
+//
+//	@param accountID int: id of the account which starts the conversation.
+//	@param peerID int: id of the other participant.
+//	@return conversation Conversation: Conversation builded.
+//	@return err error: invalid peer.
+func NewDirect(accountID, peerID int) (conversation Conversation, err error) {
+	if peerID <= 0 {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid account id: account_id must be a positive integer")
+		return
+	}
+	if peerID == accountID {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid account id: can't start a direct conversation with yourself")
+		return
+	}
+
+	conversation = Conversation{
+		Type:            DirectType,
+		CapacityMembers: 2,
+		CreatedAt:       time.Now(),
+	}
+	return
+}
+
+// WithPeer sets the peer of a direct conversation, deriving its name and picture from the peer profile.
+//
+//	@param peer account.Profile: profile of the other participant.
+//	@return $1 Conversation: conversation with the peer.
+func (c Conversation) WithPeer(peer account.Profile) Conversation {
+	c.Peer = &peer
+	c.Name = strings.TrimSpace(peer.Name + " " + peer.LastName)
+	if c.Name == "" {
+		c.Name = peer.Nickname
+	}
+	c.PictureURL = peer.PictureURL
+	return c
+}
+
+// CheckGroup checks the conversation is a group conversation, the only one with managed members and details.
+//
+//	@param conversation Conversation: conversation to check.
+//	@return err error: direct conversation ClientError.
+func CheckGroup(conversation Conversation) (err error) {
+	if conversation.Type == DirectType {
+		err = errors.NewClientError(http.StatusConflict, "direct conversation: conversation %d is a direct conversation", conversation.ID)
+	}
+	return
+}
+
+// NewDirectBlockedError initializes the error returned when one of the participants of a direct conversation
+// has blocked the other one.
+//
+//	@param peerID int: id of the other participant.
+//	@return $1 error: forbidden ClientError.
+func NewDirectBlockedError(peerID int) error {
+	return errors.NewClientError(http.StatusForbidden, "forbidden: can't message account %d", peerID)
+}
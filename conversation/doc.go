@@ -0,0 +1,3 @@
+// Package conversation handles all the conversations logic, like creation and validation.
+
+package conversation
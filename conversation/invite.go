@@ -0,0 +1,96 @@
+package conversation
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/coffemanfp/chat/auth"
+	"github.com/coffemanfp/chat/errors"
+)
+
+const (
+	// DefaultInviteLifetime is the lifetime of the invites created without lifetime.
+	DefaultInviteLifetime = 7 * 24 * time.Hour
+
+	// MaxInviteLifetime is the max lifetime of a invite.
+	MaxInviteLifetime = 30 * 24 * time.Hour
+)
+
+// Invite is the representation of a invite link to join a conversation.
+type Invite struct {
+	ID             int `json:"id,omitempty"`
+	ConversationID int `json:"conversation_id,omitempty"`
+	CreatedBy      int `json:"created_by,omitempty"`
+
+	// Token is the secret of the invite link. Is just available when the invite is created,
+	// only its hash is stored.
+	Token string `json:"token,omitempty"`
+
+	// MaxUses is the number of times the invite can be used. 0 means no limit.
+	MaxUses int `json:"max_uses"`
+	Uses    int `json:"uses"`
+
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// NewInvite initializes a new invite to the conversation with a random token.
+//
+//	@param conversationID int: conversation id.
+//	@param createdBy int: id of the member which creates the invite.
+//	@param lifetime time.Duration: time to expire of the invite. 0 means DefaultInviteLifetime.
+//	@param maxUses int: number of times the invite can be used. 0 means no limit.
+//	@return invite Invite: Invite builded.
+//	@return err error: invalid lifetime or max uses, or random source error.
+func NewInvite(conversationID, createdBy int, lifetime time.Duration, maxUses int) (invite Invite, err error) {
+	if lifetime == 0 {
+		lifetime = DefaultInviteLifetime
+	}
+	if lifetime < time.Minute || lifetime > MaxInviteLifetime {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid expires in: invite lifetime must be between 1 minute and %s", MaxInviteLifetime)
+		return
+	}
+	if maxUses < 0 {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid max uses: max uses can't be negative")
+		return
+	}
+
+	token, err := auth.RandomToken(24)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	invite = Invite{
+		ConversationID: conversationID,
+		CreatedBy:      createdBy,
+		Token:          token,
+		MaxUses:        maxUses,
+		ExpiresAt:      now.Add(lifetime),
+		CreatedAt:      now,
+	}
+	return
+}
+
+// CheckUsable checks if the invite is not expired nor exhausted.
+//
+//	@param invite Invite: invite to check.
+//	@param now time.Time: current time.
+//	 @return err error: gone ClientError.
+func CheckUsable(invite Invite, now time.Time) (err error) {
+	if now.After(invite.ExpiresAt) {
+		err = errors.NewClientError(http.StatusGone, "invalid invite: invite expired")
+		return
+	}
+	if invite.MaxUses > 0 && invite.Uses >= invite.MaxUses {
+		err = errors.NewClientError(http.StatusGone, "invalid invite: invite has no uses left")
+	}
+	return
+}
+
+// NewInviteNotFoundError initializes the error returned when the invite doesn't exists or is revoked.
+//
+//	@return $1 error: not found ClientError.
+func NewInviteNotFoundError() error {
+	return errors.NewClientError(http.StatusNotFound, "not found: invite not found")
+}
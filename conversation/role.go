@@ -0,0 +1,213 @@
+package conversation
+
+import (
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/coffemanfp/chat/errors"
+)
+
+const (
+	// MaxRoleNameLength is the max number of characters of a role name.
+	MaxRoleNameLength = 32
+
+	// MaxRoleDescriptionLength is the max number of characters of a role description.
+	MaxRoleDescriptionLength = 256
+)
+
+const (
+	// AdminRole is the name of the role of the members which manage the conversation, except the roles.
+	AdminRole = "admin"
+
+	// ReadOnlyRole is the name of the role of the members which can't write.
+	ReadOnlyRole = "read-only"
+)
+
+// Permission is a action of the members gated by their role.
+type Permission string
+
+const (
+	// WritePermission allows to send messages.
+	WritePermission Permission = "write"
+
+	// KickAccountPermission allows to kick members.
+	KickAccountPermission Permission = "kick_account"
+
+	// AddAccountPermission allows to add members.
+	AddAccountPermission Permission = "add_account"
+
+	// ChangeRolePermission allows to change the role of the members.
+	ChangeRolePermission Permission = "change_role"
+
+	// ChangeConversationDetailPermission allows to change the name and picture of the conversation.
+	ChangeConversationDetailPermission Permission = "change_conversation_detail"
+
+	// DeleteMessagePermission allows to delete the messages of other members.
+	DeleteMessagePermission Permission = "delete_message"
+)
+
+// Permissions are the permissions of a role.
+type Permissions struct {
+	Write                    bool `json:"write"`
+	KickAccount              bool `json:"kick_account"`
+	AddAccount               bool `json:"add_account"`
+	ChangeRole               bool `json:"change_role"`
+	ChangeConversationDetail bool `json:"change_conversation_detail"`
+	DeleteMessage            bool `json:"delete_message"`
+}
+
+// Has checks if the permission is granted.
+func (p Permissions) Has(permission Permission) bool {
+	switch permission {
+	case WritePermission:
+		return p.Write
+	case KickAccountPermission:
+		return p.KickAccount
+	case AddAccountPermission:
+		return p.AddAccount
+	case ChangeRolePermission:
+		return p.ChangeRole
+	case ChangeConversationDetailPermission:
+		return p.ChangeConversationDetail
+	case DeleteMessagePermission:
+		return p.DeleteMessage
+	}
+	return false
+}
+
+// Includes checks if all the permissions granted by other are granted too.
+func (p Permissions) Includes(other Permissions) bool {
+	return (p.Write || !other.Write) &&
+		(p.KickAccount || !other.KickAccount) &&
+		(p.AddAccount || !other.AddAccount) &&
+		(p.ChangeRole || !other.ChangeRole) &&
+		(p.ChangeConversationDetail || !other.ChangeConversationDetail) &&
+		(p.DeleteMessage || !other.DeleteMessage)
+}
+
+// Role is the representation of a conversation role.
+type Role struct {
+	ID int `json:"id,omitempty"`
+
+	// ConversationID is the id of the conversation of a custom role. Is 0 for the default roles.
+	ConversationID int `json:"conversation_id,omitempty"`
+
+	Name        string      `json:"name,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Permissions Permissions `json:"permissions"`
+}
+
+// Default checks if the role is one of the default roles shared by all the conversations.
+func (r Role) Default() bool {
+	return r.ConversationID == 0
+}
+
+// NewRole initializes a new custom role based on the basic data provided from the role passed as param.
+//
+//	@param roleR Role: Basic data of the role to build.
+//	@return role Role: Role builded.
+//	@return err error: error in the validation of the based role.
+func NewRole(roleR Role) (role Role, err error) {
+	role = roleR
+	role.Name = strings.TrimSpace(role.Name)
+	role.Description = strings.TrimSpace(role.Description)
+	err = ValidateRole(role)
+	return
+}
+
+// ValidateRole validates the name and description of a custom role.
+// The names of the default roles are reserved.
+//
+//	@param role Role: role to validate.
+//	 @return err error: invalid name or description.
+func ValidateRole(role Role) (err error) {
+	if role.Name == "" || utf8.RuneCountInString(role.Name) > MaxRoleNameLength {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid name: role name must have between 1 and %d characters", MaxRoleNameLength)
+		return
+	}
+	switch strings.ToLower(role.Name) {
+	case OwnerRole, AdminRole, MemberRole, ReadOnlyRole:
+		err = errors.NewClientError(http.StatusBadRequest, "invalid name: role name %s is reserved", role.Name)
+		return
+	}
+	if utf8.RuneCountInString(role.Description) > MaxRoleDescriptionLength {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid description: role description exceeds %d characters", MaxRoleDescriptionLength)
+	}
+	return
+}
+
+// Authorize checks if the role grants all the permissions provided.
+//
+//	@param role Role: role of the member.
+//	@param permissions ...Permission: required permissions.
+//	 @return err error: forbidden ClientError for the first permission not granted.
+func Authorize(role Role, permissions ...Permission) (err error) {
+	for _, permission := range permissions {
+		if !role.Permissions.Has(permission) {
+			err = errors.NewClientError(http.StatusForbidden, "forbidden: role %s has no %s permission", role.Name, permission)
+			return
+		}
+	}
+	return
+}
+
+// AuthorizeRoleChange checks if a member with the actor role can change a member role from the current role to the new role.
+// A member can't grant or take away the permissions not granted by its own role.
+//
+//	@param actor Role: role of the member which changes the role.
+//	@param current Role: current role of the changed member.
+//	@param role Role: new role of the changed member.
+//	 @return err error: forbidden ClientError.
+func AuthorizeRoleChange(actor, current, role Role) (err error) {
+	err = Authorize(actor, ChangeRolePermission)
+	if err != nil {
+		return
+	}
+	if !actor.Permissions.Includes(current.Permissions) || !actor.Permissions.Includes(role.Permissions) {
+		err = errors.NewClientError(http.StatusForbidden, "forbidden: role %s can't change role %s to %s", actor.Name, current.Name, role.Name)
+	}
+	return
+}
+
+// AuthorizeRoleEdit checks if a member with the actor role can create, edit or delete the custom role.
+// A member can't manage roles with permissions not granted by its own role.
+//
+//	@param actor Role: role of the member which manages the role.
+//	@param role Role: managed role.
+//	 @return err error: forbidden ClientError.
+func AuthorizeRoleEdit(actor, role Role) (err error) {
+	err = Authorize(actor, ChangeRolePermission)
+	if err != nil {
+		return
+	}
+	if !actor.Permissions.Includes(role.Permissions) {
+		err = errors.NewClientError(http.StatusForbidden, "forbidden: role %s can't manage role %s", actor.Name, role.Name)
+	}
+	return
+}
+
+// AuthorizeKick checks if a member with the actor role can kick a member with the target role.
+// A member can't kick members with permissions not granted by its own role.
+//
+//	@param actor Role: role of the member which kicks.
+//	@param target Role: role of the kicked member.
+//	 @return err error: forbidden ClientError.
+func AuthorizeKick(actor, target Role) (err error) {
+	err = Authorize(actor, KickAccountPermission)
+	if err != nil {
+		return
+	}
+	if !actor.Permissions.Includes(target.Permissions) {
+		err = errors.NewClientError(http.StatusForbidden, "forbidden: role %s can't kick role %s", actor.Name, target.Name)
+	}
+	return
+}
+
+// NewRoleNotFoundError initializes the error returned when the role doesn't exists in the conversation.
+//
+//	@param id int: role id.
+//	@return $1 error: not found ClientError.
+func NewRoleNotFoundError(id int) error {
+	return errors.NewClientError(http.StatusNotFound, "not found: role %d not found", id)
+}
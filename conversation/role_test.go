@@ -0,0 +1,201 @@
+package conversation
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	sErrors "github.com/coffemanfp/chat/errors"
+)
+
+// The default roles, with the permissions of the migrations.
+var (
+	owner = Role{ID: 1, Name: OwnerRole, Permissions: Permissions{
+		Write: true, KickAccount: true, AddAccount: true, ChangeRole: true, ChangeConversationDetail: true, DeleteMessage: true,
+	}}
+	member   = Role{ID: 2, Name: MemberRole, Permissions: Permissions{Write: true}}
+	admin    = Role{ID: 3, Name: AdminRole, Permissions: Permissions{Write: true, KickAccount: true, AddAccount: true, ChangeConversationDetail: true, DeleteMessage: true}}
+	readOnly = Role{ID: 4, Name: ReadOnlyRole}
+)
+
+// moderator is a custom role which can change roles without all the permissions.
+var moderator = Role{ID: 5, ConversationID: 1, Name: "moderator", Permissions: Permissions{Write: true, KickAccount: true, ChangeRole: true}}
+
+// checkForbidden checks the error is a forbidden ClientError when it's wanted, or nil otherwise.
+func checkForbidden(t *testing.T, err error, wantErr bool) {
+	t.Helper()
+
+	if !wantErr {
+		if err != nil {
+			t.Errorf("error = %v, want nil", err)
+		}
+		return
+	}
+	var cErr sErrors.ClientError
+	if !errors.As(err, &cErr) || cErr.HTTPCode() != http.StatusForbidden {
+		t.Errorf("error = %v, want forbidden client error", err)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		name        string
+		role        Role
+		permissions []Permission
+		wantErr     bool
+	}{
+		{name: "no permissions required", role: readOnly},
+		{name: "member writes", role: member, permissions: []Permission{WritePermission}},
+		{name: "read-only writes", role: readOnly, permissions: []Permission{WritePermission}, wantErr: true},
+		{name: "member adds", role: member, permissions: []Permission{AddAccountPermission}, wantErr: true},
+		{name: "admin changes detail", role: admin, permissions: []Permission{ChangeConversationDetailPermission}},
+		{name: "admin changes role", role: admin, permissions: []Permission{ChangeRolePermission}, wantErr: true},
+		{name: "owner all permissions", role: owner, permissions: []Permission{
+			WritePermission, KickAccountPermission, AddAccountPermission, ChangeRolePermission, ChangeConversationDetailPermission, DeleteMessagePermission,
+		}},
+		{name: "some permissions missing", role: admin, permissions: []Permission{WritePermission, ChangeRolePermission}, wantErr: true},
+		{name: "unknown permission", role: owner, permissions: []Permission{"unknown"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkForbidden(t, Authorize(tt.role, tt.permissions...), tt.wantErr)
+		})
+	}
+}
+
+func TestPermissionsIncludes(t *testing.T) {
+	tests := []struct {
+		name  string
+		p     Permissions
+		other Permissions
+		want  bool
+	}{
+		{name: "same permissions", p: admin.Permissions, other: admin.Permissions, want: true},
+		{name: "no permissions", p: readOnly.Permissions, other: readOnly.Permissions, want: true},
+		{name: "more permissions", p: owner.Permissions, other: member.Permissions, want: true},
+		{name: "less permissions", p: member.Permissions, other: owner.Permissions},
+		{name: "disjoint permissions", p: moderator.Permissions, other: Permissions{AddAccount: true}},
+		{name: "single missing permission", p: admin.Permissions, other: owner.Permissions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.p.Includes(tt.other); got != tt.want {
+				t.Errorf("Includes = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeRoleChange(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   Role
+		current Role
+		role    Role
+		wantErr bool
+	}{
+		{name: "owner promotes to admin", actor: owner, current: member, role: admin},
+		{name: "owner demotes admin", actor: owner, current: admin, role: readOnly},
+		{name: "owner promotes to owner", actor: owner, current: member, role: owner},
+		{name: "admin without change role", actor: admin, current: member, role: readOnly, wantErr: true},
+		{name: "member without change role", actor: member, current: readOnly, role: member, wantErr: true},
+		{name: "moderator restricts member", actor: moderator, current: member, role: readOnly},
+		{name: "moderator escalates to admin", actor: moderator, current: member, role: admin, wantErr: true},
+		{name: "moderator escalates to owner", actor: moderator, current: member, role: owner, wantErr: true},
+		{name: "moderator demotes admin", actor: moderator, current: admin, role: member, wantErr: true},
+		{name: "moderator demotes owner", actor: moderator, current: owner, role: readOnly, wantErr: true},
+		{name: "moderator gives own role", actor: moderator, current: member, role: moderator},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkForbidden(t, AuthorizeRoleChange(tt.actor, tt.current, tt.role), tt.wantErr)
+		})
+	}
+}
+
+func TestAuthorizeRoleEdit(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   Role
+		role    Role
+		wantErr bool
+	}{
+		{name: "owner edits any role", actor: owner, role: Role{Name: "editor", Permissions: owner.Permissions}},
+		{name: "moderator edits a lower role", actor: moderator, role: Role{Name: "writer", Permissions: Permissions{Write: true}}},
+		{name: "moderator edits a equal role", actor: moderator, role: Role{Name: "copy", Permissions: moderator.Permissions}},
+		{name: "moderator grants add account", actor: moderator, role: Role{Name: "inviter", Permissions: Permissions{AddAccount: true}}, wantErr: true},
+		{name: "moderator grants delete message", actor: moderator, role: Role{Name: "cleaner", Permissions: Permissions{DeleteMessage: true}}, wantErr: true},
+		{name: "admin without change role", actor: admin, role: Role{Name: "writer", Permissions: Permissions{Write: true}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkForbidden(t, AuthorizeRoleEdit(tt.actor, tt.role), tt.wantErr)
+		})
+	}
+}
+
+func TestAuthorizeKick(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   Role
+		target  Role
+		wantErr bool
+	}{
+		{name: "owner kicks admin", actor: owner, target: admin},
+		{name: "owner kicks owner", actor: owner, target: owner},
+		{name: "admin kicks member", actor: admin, target: member},
+		{name: "admin kicks read-only", actor: admin, target: readOnly},
+		{name: "admin kicks admin", actor: admin, target: admin},
+		{name: "admin kicks owner", actor: admin, target: owner, wantErr: true},
+		{name: "admin kicks moderator", actor: admin, target: moderator, wantErr: true},
+		{name: "moderator kicks admin", actor: moderator, target: admin, wantErr: true},
+		{name: "member kicks read-only", actor: member, target: readOnly, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkForbidden(t, AuthorizeKick(tt.actor, tt.target), tt.wantErr)
+		})
+	}
+}
+
+func TestNewRole(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     Role
+		wantName string
+		wantErr  bool
+	}{
+		{name: "valid", role: Role{Name: "  moderator  ", Description: " Moderates "}, wantName: "moderator"},
+		{name: "empty name", role: Role{Name: "   "}, wantErr: true},
+		{name: "long name", role: Role{Name: strings.Repeat("ñ", MaxRoleNameLength+1)}, wantErr: true},
+		{name: "max name", role: Role{Name: strings.Repeat("ñ", MaxRoleNameLength)}, wantName: strings.Repeat("ñ", MaxRoleNameLength)},
+		{name: "reserved name", role: Role{Name: "Owner"}, wantErr: true},
+		{name: "reserved read-only name", role: Role{Name: "READ-ONLY"}, wantErr: true},
+		{name: "long description", role: Role{Name: "moderator", Description: strings.Repeat("a", MaxRoleDescriptionLength+1)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role, err := NewRole(tt.role)
+			if tt.wantErr {
+				var cErr sErrors.ClientError
+				if !errors.As(err, &cErr) || cErr.HTTPCode() != http.StatusBadRequest {
+					t.Errorf("NewRole error = %v, want bad request client error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewRole failed: %s", err)
+			}
+			if role.Name != tt.wantName {
+				t.Errorf("Name = %q, want %q", role.Name, tt.wantName)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"time"
+
+	"github.com/coffemanfp/chat/account"
+)
+
+// ACCOUNT_REPOSITORY is the key to be used when creating the repositories hashmap.
+const ACCOUNT_REPOSITORY RepositoryID = "ACCOUNT"
+
+// GetAccountRepository gets the AccountRepository instance inside the repositories hashmap.
+//
+//	@param repoMap map[RepositoryID]interface{}: repositories hashmap.
+//	@return repo AccountRepository: found AccountRepository instance.
+//	@return err error: missing or invalid repository instance error.
+func GetAccountRepository(repoMap map[RepositoryID]interface{}) (repo AccountRepository, err error) {
+	repoI, err := GetRepository(repoMap, ACCOUNT_REPOSITORY)
+	if err != nil {
+		return
+	}
+	repo, ok := repoI.(AccountRepository)
+	if !ok {
+		err = newInvalidRepositoryError(ACCOUNT_REPOSITORY)
+	}
+	return
+}
+
+// AccountRepository defines the behaviors to be used by a AccountRepository implementation.
+type AccountRepository interface {
+
+	// GetAccount gets a not deleted account, without its password.
+	//	@param id int: id of the account.
+	//	@return $1 account.Account: found account.
+	//	@return $2 error: not found or database error.
+	GetAccount(id int) (account.Account, error)
+
+	// GetAccountByNickname gets a not deleted account by its nickname, without its password.
+	//	@param nickname string: nickname of the account.
+	//	@return $1 account.Account: found account.
+	//	@return $2 error: not found or database error.
+	GetAccountByNickname(nickname string) (account.Account, error)
+
+	// UpdateProfile changes the nickname, name and last name of a not deleted account.
+	//	@param account account.Account: account with the new profile.
+	//	@return $1 account.Account: updated account.
+	//	@return $2 error: not found, taken nickname or database error.
+	UpdateProfile(account account.Account) (account.Account, error)
+
+	// SetPicture changes the picture of a not deleted account.
+	//	@param id int: id of the account.
+	//	@param pictureURL string: new picture url. Empty removes the picture.
+	//	@return $1 error: not found or database error.
+	SetPicture(id int, pictureURL string) error
+
+	// GetPassword gets the password hash of a not deleted account.
+	//	@param id int: id of the account.
+	//	@return $1 string: password hash. Is empty for the accounts without password.
+	//	@return $2 error: not found or database error.
+	GetPassword(id int) (string, error)
+
+	// DeleteAccount marks a account as deleted and closes all its sessions.
+	// The account is anonymized by PurgeAccounts once its grace period ends.
+	//	@param id int: id of the account.
+	//	@return $1 time.Time: deletion time.
+	//	@return $2 error: not found or database error.
+	DeleteAccount(id int) (time.Time, error)
+
+	// PurgeAccounts anonymizes the nickname, email and profile of the accounts deleted before the time provided,
+	// and removes their sessions, identities, contacts and blocks.
+	//	@param deletedBefore time.Time: end of the grace period.
+	//	@param limit int: max number of accounts to purge.
+	//	@return $1 int: number of purged accounts.
+	//	@return $2 error: database error.
+	PurgeAccounts(deletedBefore time.Time, limit int) (int, error)
+}
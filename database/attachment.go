@@ -0,0 +1,69 @@
+package database
+
+import (
+	"github.com/coffemanfp/chat/attachment"
+)
+
+// ATTACHMENT_REPOSITORY is the key to be used when creating the repositories hashmap.
+const ATTACHMENT_REPOSITORY RepositoryID = "ATTACHMENT"
+
+// GetAttachmentRepository gets the AttachmentRepository instance inside the repositories hashmap.
+//
+//	@param repoMap map[RepositoryID]interface{}: repositories hashmap.
+//	@return repo AttachmentRepository: found AttachmentRepository instance.
+//	@return err error: missing or invalid repository instance error.
+func GetAttachmentRepository(repoMap map[RepositoryID]interface{}) (repo AttachmentRepository, err error) {
+	repoI, err := GetRepository(repoMap, ATTACHMENT_REPOSITORY)
+	if err != nil {
+		return
+	}
+	repo, ok := repoI.(AttachmentRepository)
+	if !ok {
+		err = newInvalidRepositoryError(ATTACHMENT_REPOSITORY)
+	}
+	return
+}
+
+// AttachmentRepository defines the behaviors to be used by a AttachmentRepository implementation.
+type AttachmentRepository interface {
+
+	// HasBlob checks if the content of the hash is already stored.
+	//	@param hash string: hex SHA-256 of the content.
+	//	@return $1 bool: the content is stored.
+	//	@return $2 error: database error.
+	HasBlob(hash string) (bool, error)
+
+	// SaveAttachment stores a new attachment, registering its blob if it's new.
+	//	@param attachment attachment.Attachment: attachment to store.
+	//	@return $1 attachment.Attachment: stored attachment with its id.
+	//	@return $2 error: database error.
+	SaveAttachment(attachment attachment.Attachment) (attachment.Attachment, error)
+
+	// GetAttachment gets a attachment with the generated variants of its blob.
+	//	@param id int: attachment id.
+	//	@return $1 attachment.Attachment: found attachment.
+	//	@return $2 error: not found or database error.
+	GetAttachment(id int) (attachment.Attachment, error)
+
+	// GetPendingBlobs gets the blobs of the types provided whose variants are not generated yet, oldest first.
+	//	@param mimeTypes []string: MIME types of the blobs to process.
+	//	@param limit int: max number of blobs.
+	//	@return $1 []attachment.Blob: pending blobs.
+	//	@return $2 error: database error.
+	GetPendingBlobs(mimeTypes []string, limit int) ([]attachment.Blob, error)
+
+	// SaveVariants stores the generated variants of a blob and marks it as processed.
+	//	@param hash string: hex SHA-256 of the blob.
+	//	@param variants []attachment.Variant: generated variants.
+	//	@param processErr string: error of the processing, empty if it succeeded.
+	//	@return $1 error: database error.
+	SaveVariants(hash string, variants []attachment.Variant, processErr string) error
+
+	// CanAccess checks if the account can download the attachment. The attachments are visible for their
+	// owners and for the members of the conversations with a visible message of the attachment.
+	//	@param id int: attachment id.
+	//	@param accountID int: id of the account.
+	//	@return $1 bool: the account can download the attachment.
+	//	@return $2 error: database error.
+	CanAccess(id, accountID int) (bool, error)
+}
@@ -1,7 +1,10 @@
 package database
 
 import (
+	"time"
+
 	"github.com/coffemanfp/chat/account"
+	"github.com/coffemanfp/chat/auth"
 )
 
 // AUTH_REPOSITORY is the key to be used when creating the repositories hashmap.
@@ -13,16 +16,103 @@ const AUTH_REPOSITORY RepositoryID = "AUTH"
 //		@return repo AuthRepository: found AuthRepository instance.
 //	 @return err error: missing or invalid repository instance error.
 func GetAuthRepository(repoMap map[RepositoryID]interface{}) (repo AuthRepository, err error) {
-	return GetRepository(repoMap, AUTH_REPOSITORY)
+	repoI, err := GetRepository(repoMap, AUTH_REPOSITORY)
+	if err != nil {
+		return
+	}
+	repo, ok := repoI.(AuthRepository)
+	if !ok {
+		err = newInvalidRepositoryError(AUTH_REPOSITORY)
+	}
+	return
 }
 
 // AuthRepository defines the behaviors to be used by a AuthRepository implementation.
 type AuthRepository interface {
 
-	// MatchCredentials locate a account by its credentials which check if match.
-	//  Returns the id of the account if match.
-	//	@param account account.Account: account to check the credentials.
-	//	@return $1 int: id of the matched account. Is 0 if it don't match.
-	//	@return $2 error: failed credentials validation process.
-	MatchCredentials(account account.Account) (int, error)
+	// GetCredentials locate a account by its nickname or email.
+	//  Returns the id and the stored password hash of the account.
+	//	@param account account.Account: account to locate by its nickname or email.
+	//	@return $1 int: id of the found account. Is 0 if it don't exists.
+	//	@return $2 string: bcrypt hash of the account password.
+	//	@return $3 error: database error.
+	GetCredentials(account account.Account) (int, string, error)
+
+	// UpdatePassword replaces the stored password hash of the account.
+	//	@param id int: id of the account.
+	//	@param hash string: new bcrypt hash of the account password.
+	//	@return $1 error: database error.
+	UpdatePassword(id int, hash string) error
+
+	// SignUp registers a new account.
+	//  Returns a conflict ClientError if the nickname or email are already registered.
+	//	@param account account.Account: account to register. (Password must be already hashed)
+	//	@return $1 int: id of the new account.
+	//	@return $2 error: duplicated account or database error.
+	SignUp(account account.Account) (int, error)
+
+	// SignWithIdentity gets the account linked to the external platform identity.
+	//  If there is no linked account, the identity is linked to the account with the same email
+	//  when both the platform and the account have verified it, otherwise a new account is created.
+	//  Returns a conflict ClientError if the account with the same email has not verified it,
+	//  the identity must be linked from a session of the account. The nickname of the new account
+	//  is left empty if it's already taken.
+	//	@param account account.Account: account info read from the external platform.
+	//	@param provider string: external platform name.
+	//	@param subject string: account unique identifier in the external platform.
+	//	@param emailVerified bool: true if the external platform has verified the account email.
+	//	@return $1 int: id of the linked account.
+	//	@return $2 error: account with unverified email or database error.
+	SignWithIdentity(account account.Account, provider, subject string, emailVerified bool) (int, error)
+
+	// LinkIdentity uses a identity link token and links the external platform identity to its account.
+	//	@param token auth.ActionToken: verified token of the LinkIdentityPurpose.
+	//	@param provider string: external platform name.
+	//	@param subject string: account unique identifier in the external platform.
+	//	@return $1 error: used or expired token, already linked identity ClientError or database error.
+	LinkIdentity(token auth.ActionToken, provider, subject string) error
+
+	// RestoreAccount cancels the deletion of a account in its grace period.
+	//	@param id int: account id.
+	//	@return $1 bool: true if the account was deleted and has been restored.
+	//	@return $2 error: database error.
+	RestoreAccount(id int) (bool, error)
+
+	// GetAccountEmail gets the email of a not deleted account.
+	//	@param id int: account id.
+	//	@return $1 string: account email. Is empty if the account has no email.
+	//	@return $2 bool: true if the email is verified.
+	//	@return $3 error: not found or database error.
+	GetAccountEmail(id int) (string, bool, error)
+
+	// GetAccountIDByEmail gets the id of the not deleted account of a email.
+	//	@param email string: account email.
+	//	@return $1 int: account id. Is 0 if there is no account with the email.
+	//	@return $2 error: database error.
+	GetAccountIDByEmail(email string) (int, error)
+
+	// SaveActionToken stores a new action token, by the hash of its id.
+	//	@param token auth.ActionToken: token to store.
+	//	@return $1 error: database error.
+	SaveActionToken(token auth.ActionToken) error
+
+	// HasRecentActionToken checks if the account has a not used nor expired action token created after a time.
+	//	@param accountID int: account id.
+	//	@param purpose auth.ActionPurpose: action of the tokens.
+	//	@param since time.Time: oldest creation time of the tokens.
+	//	@return $1 bool: true if there is a recent token.
+	//	@return $2 error: database error.
+	HasRecentActionToken(accountID int, purpose auth.ActionPurpose, since time.Time) (bool, error)
+
+	// VerifyEmail uses a email verification token and marks the account email as verified.
+	//	@param token auth.ActionToken: verified token of the VerifyEmailPurpose.
+	//	@return $1 error: used or expired token ClientError or database error.
+	VerifyEmail(token auth.ActionToken) error
+
+	// ResetPassword uses a password reset token, replaces the account password and deactivates all
+	// the account sessions. The other reset tokens of the account are invalidated too.
+	//	@param token auth.ActionToken: verified token of the ResetPasswordPurpose.
+	//	@param hash string: new bcrypt hash of the account password.
+	//	@return $1 error: used or expired token ClientError or database error.
+	ResetPassword(token auth.ActionToken, hash string) error
 }
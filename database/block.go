@@ -0,0 +1,66 @@
+package database
+
+import (
+	"github.com/coffemanfp/chat/account"
+)
+
+// BLOCK_REPOSITORY is the key to be used when creating the repositories hashmap.
+const BLOCK_REPOSITORY RepositoryID = "BLOCK"
+
+// GetBlockRepository gets the BlockRepository instance inside the repositories hashmap.
+//
+//	@param repoMap map[RepositoryID]interface{}: repositories hashmap.
+//	@return repo BlockRepository: found BlockRepository instance.
+//	@return err error: missing or invalid repository instance error.
+func GetBlockRepository(repoMap map[RepositoryID]interface{}) (repo BlockRepository, err error) {
+	repoI, err := GetRepository(repoMap, BLOCK_REPOSITORY)
+	if err != nil {
+		return
+	}
+	repo, ok := repoI.(BlockRepository)
+	if !ok {
+		err = newInvalidRepositoryError(BLOCK_REPOSITORY)
+	}
+	return
+}
+
+// BlockRepository defines the behaviors to be used by a BlockRepository implementation.
+type BlockRepository interface {
+
+	// Block blocks a account for other account.
+	// Its unread messages are discounted from the unread counters of the blocker.
+	//	@param fromAccountID int: id of the blocker account.
+	//	@param toAccountID int: id of the blocked account.
+	//	@return $1 error: not found account, already blocked or database error.
+	Block(fromAccountID, toAccountID int) error
+
+	// Unblock removes the block of a account.
+	// Its unread messages are counted again on the unread counters of the blocker.
+	//	@param fromAccountID int: id of the blocker account.
+	//	@param toAccountID int: id of the blocked account.
+	//	@return $1 error: not found block or database error.
+	Unblock(fromAccountID, toAccountID int) error
+
+	// GetBlocked gets a page of the accounts blocked by a account, the latest first.
+	//	@param fromAccountID int: id of the blocker account.
+	//	@param limit int: page size.
+	//	@param offset int: number of blocks to skip.
+	//	@return $1 []account.BlockedAccount: blocked accounts of the page.
+	//	@return $2 int: total number of blocked accounts.
+	//	@return $3 error: database error.
+	GetBlocked(fromAccountID, limit, offset int) ([]account.BlockedAccount, int, error)
+
+	// GetBlockerIDs gets which of the accounts provided have blocked the account.
+	//	@param accountID int: id of the blocked account.
+	//	@param amongIDs []int: ids of the accounts to check.
+	//	@return $1 []int: ids of the accounts which have blocked the account.
+	//	@return $2 error: database error.
+	GetBlockerIDs(accountID int, amongIDs []int) ([]int, error)
+
+	// GetBlockedIDs gets which of the accounts provided are blocked by the account.
+	//	@param accountID int: id of the blocker account.
+	//	@param amongIDs []int: ids of the accounts to check.
+	//	@return $1 []int: ids of the accounts blocked by the account.
+	//	@return $2 error: database error.
+	GetBlockedIDs(accountID int, amongIDs []int) ([]int, error)
+}
@@ -21,15 +21,20 @@ type DatabaseConnector interface {
 	Connect() error
 }
 
-func GetRepository(repoMap map[RepositoryID]interface{}, id RepositoryID) (repo AuthRepository, err error) {
-	repoI, ok := repoMap[id]
+// GetRepository gets the repository instance inside the repositories hashmap.
+//
+//	@param repoMap map[RepositoryID]interface{}: repositories hashmap.
+//	@param id RepositoryID: key of the repository.
+//	@return repo interface{}: found repository instance.
+//	@return err error: missing repository instance error.
+func GetRepository(repoMap map[RepositoryID]interface{}, id RepositoryID) (repo interface{}, err error) {
+	repo, ok := repoMap[id]
 	if !ok {
 		err = fmt.Errorf("missing repository: %s not found in repository map", id)
-		return
-	}
-	repo, ok = repoI.(AuthRepository)
-	if !ok {
-		err = fmt.Errorf("invalid repository value: %s has a invalid %s repository handler", id, id)
 	}
 	return
 }
+
+func newInvalidRepositoryError(id RepositoryID) error {
+	return fmt.Errorf("invalid repository value: %s has a invalid %s repository handler", id, id)
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"github.com/coffemanfp/chat/contact"
+)
+
+// CONTACT_REPOSITORY is the key to be used when creating the repositories hashmap.
+const CONTACT_REPOSITORY RepositoryID = "CONTACT"
+
+// GetContactRepository gets the ContactRepository instance inside the repositories hashmap.
+//
+//	@param repoMap map[RepositoryID]interface{}: repositories hashmap.
+//	@return repo ContactRepository: found ContactRepository instance.
+//	@return err error: missing or invalid repository instance error.
+func GetContactRepository(repoMap map[RepositoryID]interface{}) (repo ContactRepository, err error) {
+	repoI, err := GetRepository(repoMap, CONTACT_REPOSITORY)
+	if err != nil {
+		return
+	}
+	repo, ok := repoI.(ContactRepository)
+	if !ok {
+		err = newInvalidRepositoryError(CONTACT_REPOSITORY)
+	}
+	return
+}
+
+// ContactRepository defines the behaviors to be used by a ContactRepository implementation.
+type ContactRepository interface {
+
+	// AddContact stores a new contact in the address book of a account.
+	// The missing contact names are taken from the saved account.
+	//	@param contact contact.Contact: contact to store.
+	//	@param target string: nickname or email of the account to save.
+	//	@return $1 contact.Contact: stored contact with its id and the account public profile.
+	//	@return $2 error: not found account, already exists or database error.
+	AddContact(contact contact.Contact, target string) (contact.Contact, error)
+
+	// GetContact gets a contact of the address book of a account.
+	//	@param fromAccountID int: id of the address book owner.
+	//	@param id int: contact id.
+	//	@return $1 contact.Contact: found contact with the account public profile.
+	//	@return $2 error: not found or database error.
+	GetContact(fromAccountID, id int) (contact.Contact, error)
+
+	// GetContacts gets a page of the address book of a account.
+	//	@param fromAccountID int: id of the address book owner.
+	//	@param query contact.Query: search, sort and page options.
+	//	@return $1 []contact.Contact: contacts of the page.
+	//	@return $2 int: total number of contacts matching the search.
+	//	@return $3 error: database error.
+	GetContacts(fromAccountID int, query contact.Query) ([]contact.Contact, int, error)
+
+	// UpdateContact renames a contact of the address book of a account.
+	//	@param contact contact.Contact: contact with its new names.
+	//	@return $1 error: not found or database error.
+	UpdateContact(contact contact.Contact) error
+
+	// DeleteContact removes a contact of the address book of a account.
+	//	@param fromAccountID int: id of the address book owner.
+	//	@param id int: contact id.
+	//	@return $1 error: not found or database error.
+	DeleteContact(fromAccountID, id int) error
+}
@@ -0,0 +1,184 @@
+package database
+
+import (
+	"github.com/coffemanfp/chat/account"
+	"github.com/coffemanfp/chat/conversation"
+)
+
+// CONVERSATION_REPOSITORY is the key to be used when creating the repositories hashmap.
+const CONVERSATION_REPOSITORY RepositoryID = "CONVERSATION"
+
+// GetConversationRepository gets the ConversationRepository instance inside the repositories hashmap.
+//
+//	@param repoMap map[RepositoryID]interface{}: repositories hashmap.
+//	@return repo ConversationRepository: found ConversationRepository instance.
+//	@return err error: missing or invalid repository instance error.
+func GetConversationRepository(repoMap map[RepositoryID]interface{}) (repo ConversationRepository, err error) {
+	repoI, err := GetRepository(repoMap, CONVERSATION_REPOSITORY)
+	if err != nil {
+		return
+	}
+	repo, ok := repoI.(ConversationRepository)
+	if !ok {
+		err = newInvalidRepositoryError(CONVERSATION_REPOSITORY)
+	}
+	return
+}
+
+// ConversationRepository defines the behaviors to be used by a ConversationRepository implementation.
+type ConversationRepository interface {
+
+	// CreateConversation stores a new conversation with its owner and initial members.
+	//	@param conversation conversation.Conversation: conversation to store.
+	//	@param ownerID int: id of the account which creates the conversation.
+	//	@param memberIDs []int: ids of the initial members, without the owner.
+	//	@return $1 conversation.Conversation: stored conversation with its id.
+	//	@return $2 error: not found account or database error.
+	CreateConversation(conversation conversation.Conversation, ownerID int, memberIDs []int) (conversation.Conversation, error)
+
+	// GetOrCreateDirect gets the direct conversation between two accounts, creating it if it doesn't exists.
+	// There is just one direct conversation per pair of accounts.
+	//	@param conversation conversation.Conversation: direct conversation to store if it doesn't exists.
+	//	@param accountID int: id of the account which starts the conversation.
+	//	@param peerID int: id of the other participant.
+	//	@return $1 conversation.Conversation: found or stored conversation.
+	//	@return $2 bool: the conversation was created.
+	//	@return $3 error: not found peer or database error.
+	GetOrCreateDirect(conversation conversation.Conversation, accountID, peerID int) (conversation.Conversation, bool, error)
+
+	// GetDirectPeer gets the profile of the other participant of a direct conversation.
+	//	@param conversationID int: direct conversation id.
+	//	@param accountID int: id of a participant.
+	//	@return $1 account.Profile: profile of the other participant.
+	//	@return $2 error: not found or database error.
+	GetDirectPeer(conversationID, accountID int) (account.Profile, error)
+
+	// GetConversation gets a not deleted conversation.
+	//	@param id int: conversation id.
+	//	@return $1 conversation.Conversation: found conversation.
+	//	@return $2 error: not found or database error.
+	GetConversation(id int) (conversation.Conversation, error)
+
+	// GetConversations gets the not deleted conversations of the account with their unread messages counters.
+	// The direct conversations include their peer.
+	//	@param accountID int: id of a current member.
+	//	@return $1 []conversation.Summary: conversations of the account.
+	//	@return $2 error: database error.
+	GetConversations(accountID int) ([]conversation.Summary, error)
+
+	// UpdateConversation updates the name and picture of a not deleted conversation.
+	//	@param conversation conversation.Conversation: conversation with the new details.
+	//	@return $1 error: not found or database error.
+	UpdateConversation(conversation conversation.Conversation) error
+
+	// DeleteConversation soft deletes a conversation.
+	//	@param id int: conversation id.
+	//	@return $1 error: not found or database error.
+	DeleteConversation(id int) error
+
+	// GetMembers gets the current members of the conversation.
+	//	@param conversationID int: conversation id.
+	//	@return $1 []conversation.Member: current members.
+	//	@return $2 error: database error.
+	GetMembers(conversationID int) ([]conversation.Member, error)
+
+	// AddMembers adds new members to the conversation, enforcing its capacity.
+	// The accounts which are already current members are ignored.
+	//	@param conversationID int: conversation id.
+	//	@param accountIDs []int: ids of the accounts to add.
+	//	@return $1 error: not found conversation or account, full conversation or database error.
+	AddMembers(conversationID int, accountIDs []int) error
+
+	// RemoveMember ends the membership of a current member, keeping it in the history with its left_at.
+	// The conversation must keep at least a member with the change_role permission.
+	//	@param conversationID int: conversation id.
+	//	@param accountID int: account id of the member.
+	//	@return $1 error: not found member, last change_role holder or database error.
+	RemoveMember(conversationID, accountID int) error
+
+	// CreateInvite stores a new invite link of a conversation.
+	//	@param invite conversation.Invite: invite to store.
+	//	@param tokenHash string: hash of the invite token.
+	//	@return $1 conversation.Invite: stored invite with its id.
+	//	@return $2 error: database error.
+	CreateInvite(invite conversation.Invite, tokenHash string) (conversation.Invite, error)
+
+	// GetInvites gets the usable invites of a conversation.
+	//	@param conversationID int: conversation id.
+	//	@return $1 []conversation.Invite: not revoked, expired nor exhausted invites.
+	//	@return $2 error: database error.
+	GetInvites(conversationID int) ([]conversation.Invite, error)
+
+	// RevokeInvite revokes a invite of a conversation.
+	//	@param conversationID int: conversation id.
+	//	@param inviteID int: invite id.
+	//	@return $1 error: not found or database error.
+	RevokeInvite(conversationID, inviteID int) error
+
+	// JoinWithInvite adds the account to the conversation of the invite, spending one of its uses.
+	// The current members keep their membership without spending the invite.
+	//	@param tokenHash string: hash of the invite token.
+	//	@param accountID int: id of the joining account.
+	//	@return $1 int: id of the joined conversation.
+	//	@return $2 error: not found, expired or exhausted invite, full conversation or database error.
+	JoinWithInvite(tokenHash string, accountID int) (int, error)
+
+	// GetMembership gets the current membership of a account in the not deleted conversation.
+	//	@param conversationID int: conversation id.
+	//	@param accountID int: account id.
+	//	@return $1 conversation.Membership: membership with the member role and its join time.
+	//	@return $2 error: not found if the account is not a member, or database error.
+	GetMembership(conversationID, accountID int) (conversation.Membership, error)
+
+	// GetMemberRole gets the role of a current member of the not deleted conversation.
+	//	@param conversationID int: conversation id.
+	//	@param accountID int: account id.
+	//	@return $1 conversation.Role: role of the member with its permissions.
+	//	@return $2 error: not found if the account is not a member, or database error.
+	GetMemberRole(conversationID, accountID int) (conversation.Role, error)
+
+	// GetRole gets a default role or a custom role of the conversation.
+	//	@param conversationID int: conversation id.
+	//	@param roleID int: role id.
+	//	@return $1 conversation.Role: found role with its permissions.
+	//	@return $2 error: not found or database error.
+	GetRole(conversationID, roleID int) (conversation.Role, error)
+
+	// GetRoles gets the default roles and the custom roles of the conversation.
+	//	@param conversationID int: conversation id.
+	//	@return $1 []conversation.Role: roles with their permissions.
+	//	@return $2 error: database error.
+	GetRoles(conversationID int) ([]conversation.Role, error)
+
+	// CreateRole stores a new custom role of a conversation with its permissions.
+	//	@param role conversation.Role: role to store.
+	//	@return $1 conversation.Role: stored role with its id.
+	//	@return $2 error: already exists or database error.
+	CreateRole(role conversation.Role) (conversation.Role, error)
+
+	// UpdateRole updates the name, description and permissions of a custom role of a conversation.
+	// The conversation must keep at least a member with the change_role permission.
+	//	@param role conversation.Role: role with the new details.
+	//	@return $1 error: not found, already exists, last change_role holder or database error.
+	UpdateRole(role conversation.Role) error
+
+	// DeleteRole deletes a custom role of a conversation which is not assigned to any current member.
+	//	@param conversationID int: conversation id.
+	//	@param roleID int: role id.
+	//	@return $1 error: not found, role in use or database error.
+	DeleteRole(conversationID, roleID int) error
+
+	// SetMemberRole changes the role of a current member of the conversation.
+	// The conversation must keep at least a member with the change_role permission.
+	//	@param conversationID int: conversation id.
+	//	@param accountID int: account id of the member.
+	//	@param roleID int: id of the new role.
+	//	@return $1 error: not found member, last change_role holder or database error.
+	SetMemberRole(conversationID, accountID, roleID int) error
+
+	// GetMemberIDs gets the ids of the current members of the conversation.
+	//	@param conversationID int: conversation id.
+	//	@return $1 []int: account ids of the members. Is empty if the conversation doesn't exists or is deleted.
+	//	@return $2 error: database error.
+	GetMemberIDs(conversationID int) ([]int, error)
+}
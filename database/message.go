@@ -0,0 +1,102 @@
+package database
+
+import (
+	"time"
+
+	"github.com/coffemanfp/chat/message"
+)
+
+// MESSAGE_REPOSITORY is the key to be used when creating the repositories hashmap.
+const MESSAGE_REPOSITORY RepositoryID = "MESSAGE"
+
+// GetMessageRepository gets the MessageRepository instance inside the repositories hashmap.
+//
+//	@param repoMap map[RepositoryID]interface{}: repositories hashmap.
+//	@return repo MessageRepository: found MessageRepository instance.
+//	@return err error: missing or invalid repository instance error.
+func GetMessageRepository(repoMap map[RepositoryID]interface{}) (repo MessageRepository, err error) {
+	repoI, err := GetRepository(repoMap, MESSAGE_REPOSITORY)
+	if err != nil {
+		return
+	}
+	repo, ok := repoI.(MessageRepository)
+	if !ok {
+		err = newInvalidRepositoryError(MESSAGE_REPOSITORY)
+	}
+	return
+}
+
+// MessageRepository defines the behaviors to be used by a MessageRepository implementation.
+type MessageRepository interface {
+
+	// SaveMessage stores a new conversation message with the next seq of its conversation.
+	// The message is marked as read by its author.
+	//	@param message message.Message: message to store.
+	//	@return $1 message.Message: stored message with its id and seq.
+	//	@return $2 error: database error.
+	SaveMessage(message message.Message) (message.Message, error)
+
+	// GetHistory gets the messages of a conversation sent since the current membership of the account,
+	// the latest first. The messages of the accounts blocked by the account are hidden.
+	//	@param conversationID int: conversation id.
+	//	@param accountID int: id of a current member.
+	//	@param before *message.Cursor: cursor of the oldest message already read. Nil means the latest messages.
+	//	@param limit int: max number of messages.
+	//	@return $1 []message.Message: messages older than the cursor.
+	//	@return $2 error: database error.
+	GetHistory(conversationID, accountID int, before *message.Cursor, limit int) ([]message.Message, error)
+
+	// GetMessage gets a message of a conversation visible in the history of a member.
+	//  The deleted messages are got as tombstones.
+	//	@param conversationID int: conversation id.
+	//	@param accountID int: id of a current member.
+	//	@param id int: message id.
+	//	@return $1 message.Message: found message.
+	//	@return $2 error: not found, sent before the member joined or by a account blocked by the member, or database error.
+	GetMessage(conversationID, accountID, id int) (message.Message, error)
+
+	// EditMessage replaces the content of a message, storing its previous content as a revision.
+	//	@param id int: message id.
+	//	@param content string: new content.
+	//	@param editedAt time.Time: time of the edition.
+	//	@return $1 message.Message: edited message.
+	//	@return $2 error: not found, deleted message or database error.
+	EditMessage(id int, content string, editedAt time.Time) (message.Message, error)
+
+	// DeleteMessage turns a message into a tombstone, removing its content and revisions.
+	//	@param id int: message id.
+	//	@param deletedBy int: id of the account which deletes the message.
+	//	@return $1 message.Message: message tombstone.
+	//	@return $2 error: not found, deleted message or database error.
+	DeleteMessage(id, deletedBy int) (message.Message, error)
+
+	// GetRevisions gets the previous contents of a message, the oldest first.
+	//	@param id int: message id.
+	//	@return $1 []message.Revision: message revisions.
+	//	@return $2 error: database error.
+	GetRevisions(id int) ([]message.Revision, error)
+
+	// MarkRead moves the read cursor of a member up to a message of the conversation.
+	// The cursor never moves back.
+	//	@param conversationID int: conversation id.
+	//	@param accountID int: id of a current member.
+	//	@param messageID int: id of the last read message.
+	//	@return $1 bool: true if the cursor moved.
+	//	@return $2 error: not found message or database error.
+	MarkRead(conversationID, accountID, messageID int) (bool, error)
+
+	// GetReceipts gets the read receipts of a message by the current members, except its author.
+	//	@param conversationID int: conversation id.
+	//	@param messageID int: message id.
+	//	@return $1 []message.Receipt: receipts of the members which have read the message.
+	//	@return $2 error: not found message or database error.
+	GetReceipts(conversationID, messageID int) ([]message.Receipt, error)
+
+	// GetAccountMessages gets a page of the not deleted messages sent by a account, oldest first.
+	//	@param accountID int: id of the author.
+	//	@param afterID int: id of the last message of the previous page. 0 gets the first page.
+	//	@param limit int: max number of messages.
+	//	@return $1 []message.Message: messages of the page.
+	//	@return $2 error: database error.
+	GetAccountMessages(accountID, afterID, limit int) ([]message.Message, error)
+}
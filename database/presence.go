@@ -0,0 +1,51 @@
+package database
+
+import (
+	"time"
+
+	"github.com/coffemanfp/chat/account"
+)
+
+// PRESENCE_REPOSITORY is the key to be used when creating the repositories hashmap.
+const PRESENCE_REPOSITORY RepositoryID = "PRESENCE"
+
+// GetPresenceRepository gets the PresenceRepository instance inside the repositories hashmap.
+//
+//	@param repoMap map[RepositoryID]interface{}: repositories hashmap.
+//	@return repo PresenceRepository: found PresenceRepository instance.
+//	@return err error: missing or invalid repository instance error.
+func GetPresenceRepository(repoMap map[RepositoryID]interface{}) (repo PresenceRepository, err error) {
+	repoI, err := GetRepository(repoMap, PRESENCE_REPOSITORY)
+	if err != nil {
+		return
+	}
+	repo, ok := repoI.(PresenceRepository)
+	if !ok {
+		err = newInvalidRepositoryError(PRESENCE_REPOSITORY)
+	}
+	return
+}
+
+// PresenceRepository defines the behaviors to be used by a PresenceRepository implementation.
+type PresenceRepository interface {
+
+	// GetLastSeen gets the last seen of the accounts visible for the viewer account,
+	// according to the last seen privacy setting of each account.
+	//	@param viewerID int: id of the account which views the last seen.
+	//	@param accountIDs []int: ids of the accounts to get.
+	//	@return $1 map[int]time.Time: last seen by account id. The hidden ones are missing.
+	//	@return $2 error: database error.
+	GetLastSeen(viewerID int, accountIDs []int) (map[int]time.Time, error)
+
+	// GetLastSeenVisibility gets the last seen privacy setting of a account.
+	//	@param accountID int: id of the account.
+	//	@return $1 account.LastSeenVisibility: privacy setting of the account.
+	//	@return $2 error: database error.
+	GetLastSeenVisibility(accountID int) (account.LastSeenVisibility, error)
+
+	// SetLastSeenVisibility sets the last seen privacy setting of a account.
+	//	@param accountID int: id of the account.
+	//	@param visibility account.LastSeenVisibility: new privacy setting.
+	//	@return $1 error: database error.
+	SetLastSeenVisibility(accountID int, visibility account.LastSeenVisibility) error
+}
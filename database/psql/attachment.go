@@ -0,0 +1,215 @@
+package psql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/coffemanfp/chat/attachment"
+	"github.com/coffemanfp/chat/database"
+	"github.com/lib/pq"
+)
+
+// AttachmentRepository is the implementation of a attachment repository for the PostgreSQL database.
+type AttachmentRepository struct {
+	db *sql.DB
+}
+
+// NewAttachmentRepository initializes a new attachment repository instance.
+//
+//	@param conn *PostgreSQLConnector: is the PostgreSQLConnector handler.
+//	@return repo database.AttachmentRepository: is the final interface to keep
+//	 the AttachmentRepository implementation.
+//	@return err error: database connection error.
+func NewAttachmentRepository(conn *PostgreSQLConnector) (repo database.AttachmentRepository, err error) {
+	db, err := conn.getConn()
+	if err != nil {
+		return
+	}
+	repo = AttachmentRepository{
+		db: db,
+	}
+	return
+}
+
+func (a AttachmentRepository) HasBlob(hash string) (exists bool, err error) {
+	err = a.db.QueryRow(`select exists (select 1 from attachment_blob where hash = $1)`, hash).Scan(&exists)
+	if err != nil {
+		err = fmt.Errorf("failed to check attachment blob: %s", err)
+	}
+	return
+}
+
+func (a AttachmentRepository) SaveAttachment(attachmentR attachment.Attachment) (att attachment.Attachment, err error) {
+	tx, err := a.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin attachment saving: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	att = attachmentR
+
+	query := `
+		insert into attachment_blob (hash, size, mime_type, created_at)
+		values ($1, $2, $3, $4)
+		on conflict (hash) do nothing
+	`
+
+	_, err = tx.Exec(query, att.Hash, att.Size, att.MimeType, att.CreatedAt)
+	if err != nil {
+		err = fmt.Errorf("failed to save attachment blob: %s", err)
+		return
+	}
+
+	query = `
+		insert into attachment (account_id, hash, name, mime_type, size, created_at)
+		values ($1, $2, $3, $4, $5, $6)
+		returning id
+	`
+
+	err = tx.QueryRow(query, att.AccountID, att.Hash, att.Name, att.MimeType, att.Size, att.CreatedAt).Scan(&att.ID)
+	if err != nil {
+		err = fmt.Errorf("failed to save attachment: %s", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit attachment saving: %s", err)
+	}
+	return
+}
+
+func (a AttachmentRepository) GetAttachment(id int) (att attachment.Attachment, err error) {
+	query := `
+		select id, account_id, hash, name, mime_type, size, created_at from attachment where id = $1
+	`
+
+	err = a.db.QueryRow(query, id).Scan(&att.ID, &att.AccountID, &att.Hash, &att.Name, &att.MimeType, &att.Size, &att.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = attachment.NewNotFoundError(id)
+			return
+		}
+		err = fmt.Errorf("failed to get attachment: %s", err)
+		return
+	}
+
+	query = `
+		select name, mime_type, width, height, size, created_at from attachment_variant
+		where hash = $1 order by width
+	`
+
+	rows, err := a.db.Query(query, att.Hash)
+	if err != nil {
+		err = fmt.Errorf("failed to get attachment variants: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v attachment.Variant
+		err = rows.Scan(&v.Name, &v.MimeType, &v.Width, &v.Height, &v.Size, &v.CreatedAt)
+		if err != nil {
+			err = fmt.Errorf("failed to scan attachment variant: %s", err)
+			return
+		}
+		att.Variants = append(att.Variants, v)
+	}
+	err = rows.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to get attachment variants: %s", err)
+	}
+	return
+}
+
+func (a AttachmentRepository) GetPendingBlobs(mimeTypes []string, limit int) (blobs []attachment.Blob, err error) {
+	query := `
+		select hash, mime_type, size from attachment_blob
+		where processed_at is null and mime_type = any($1)
+		order by created_at limit $2
+	`
+
+	rows, err := a.db.Query(query, pq.Array(mimeTypes), limit)
+	if err != nil {
+		err = fmt.Errorf("failed to get pending attachment blobs: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var blob attachment.Blob
+		err = rows.Scan(&blob.Hash, &blob.MimeType, &blob.Size)
+		if err != nil {
+			err = fmt.Errorf("failed to scan attachment blob: %s", err)
+			return
+		}
+		blobs = append(blobs, blob)
+	}
+	err = rows.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to get pending attachment blobs: %s", err)
+	}
+	return
+}
+
+func (a AttachmentRepository) SaveVariants(hash string, variants []attachment.Variant, processErr string) (err error) {
+	tx, err := a.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin attachment variants saving: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	query := `
+		insert into attachment_variant (hash, name, mime_type, width, height, size, created_at)
+		values ($1, $2, $3, $4, $5, $6, $7)
+		on conflict (hash, name) do nothing
+	`
+
+	for _, v := range variants {
+		_, err = tx.Exec(query, hash, v.Name, v.MimeType, v.Width, v.Height, v.Size, v.CreatedAt)
+		if err != nil {
+			err = fmt.Errorf("failed to save attachment variant: %s", err)
+			return
+		}
+	}
+
+	query = `
+		update attachment_blob set processed_at = now(), process_error = nullif($2, '') where hash = $1
+	`
+
+	_, err = tx.Exec(query, hash, processErr)
+	if err != nil {
+		err = fmt.Errorf("failed to update attachment blob: %s", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit attachment variants saving: %s", err)
+	}
+	return
+}
+
+func (a AttachmentRepository) CanAccess(id, accountID int) (can bool, err error) {
+	// The messages sent before the account joined the conversation are not visible.
+	query := `
+		select exists (
+			select 1 from attachment where id = $1 and account_id = $2
+		) or exists (
+			select 1 from message msg
+			inner join convesation_members m on m.conversation_id = msg.conversation_id
+			inner join conversation c on c.id = msg.conversation_id
+			where msg.attachment_id = $1 and msg.deleted_at is null and c.deleted_at is null
+			and m.account_id = $2 and m.left_at is null and msg.seq > m.joined_seq
+		)
+	`
+
+	err = a.db.QueryRow(query, id, accountID).Scan(&can)
+	if err != nil {
+		err = fmt.Errorf("failed to check attachment access: %s", err)
+	}
+	return
+}
@@ -4,9 +4,13 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/coffemanfp/chat/account"
+	"github.com/coffemanfp/chat/auth"
 	"github.com/coffemanfp/chat/database"
+	sErrors "github.com/coffemanfp/chat/errors"
 )
 
 // AuthRepository is the implementation of a authentication repository for the PostgreSQL database.
@@ -31,18 +35,383 @@ func NewAuthRepository(conn *PostgreSQLConnector) (repo database.AuthRepository,
 	return
 }
 
-func (u AuthRepository) MatchCredentials(account account.Account) (id int, err error) {
+func (u AuthRepository) GetCredentials(account account.Account) (id int, hash string, err error) {
+	// The account is searched only by the identifier provided, the nickname first,
+	// because the nickname of a account can be the email of another one.
+	query := `select id, password from account where nickname = $1`
+	identifier := account.Nickname
+	if identifier == "" {
+		query = `select id, password from account where email = $1`
+		identifier = account.Email
+	}
+	if identifier == "" {
+		return
+	}
+
+	var password sql.NullString
+	err = u.db.QueryRow(query, identifier).Scan(&id, &password)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+			return
+		}
+		err = fmt.Errorf("failed to get account credentials: %s", err)
+		return
+	}
+	hash = password.String
+	return
+}
+
+func (u AuthRepository) UpdatePassword(id int, hash string) (err error) {
 	query := `
-		select id from account where (nickname = $1 and password = $3) or (email = $2 and password = $3)
+		update account set password = $1, updated_at = now() where id = $2
 	`
 
-	err = u.db.QueryRow(query, account.Nickname, account.Email, account.Password).Scan(&id)
+	_, err = u.db.Exec(query, hash, id)
+	if err != nil {
+		err = fmt.Errorf("failed to update account password: %s", err)
+	}
+	return
+}
+
+func (u AuthRepository) SignUp(account account.Account) (id int, err error) {
+	query := `
+		insert into account (name, nickname, email, password, created_at)
+		values ($1, $2, $3, $4, now())
+		returning id
+	`
+
+	err = u.db.QueryRow(query, account.Name, account.Nickname, account.Email, account.Password).Scan(&id)
+	if err != nil {
+		if pqErr, ok := newPQError(err); ok {
+			if match, aErr := pqErr.asAlreadyExists(); match {
+				err = aErr
+				return
+			}
+		}
+		err = fmt.Errorf("failed to sign up account: %s", err)
+	}
+	return
+}
+
+func (u AuthRepository) SignWithIdentity(account account.Account, provider, subject string, emailVerified bool) (id int, err error) {
+	tx, err := u.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin identity sign: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	query := `
+		select account_id from account_identity where provider = $1 and subject = $2
+	`
+
+	err = tx.QueryRow(query, provider, subject).Scan(&id)
+	if err == nil {
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		err = fmt.Errorf("failed to get account identity: %s", err)
+		return
+	}
+	err = nil
+
+	if emailVerified && account.Email != "" {
+		var verified bool
+		query = `select id, email_verified_at is not null from account where email = $1`
+
+		err = tx.QueryRow(query, account.Email).Scan(&id, &verified)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			err = fmt.Errorf("failed to get account by email: %s", err)
+			return
+		}
+		err = nil
+
+		// A account with a unverified email could be registered by anyone, so it's just linked explicitly
+		// from a session of the account.
+		if id != 0 && !verified {
+			id = 0
+			err = sErrors.NewClientError(http.StatusConflict, "already exists: sign in to the account of the email %s and link the %s identity from it", account.Email, provider)
+			return
+		}
+	}
+
+	if id == 0 {
+		// The platform nickname is left empty if it's already taken, the account can choose another one later.
+		query = `
+			insert into account (name, last_name, nickname, email, email_verified_at, created_at)
+			values (
+				$1, nullif($2, ''),
+				(select nullif($3, '') where not exists (select 1 from account where nickname = $3)),
+				nullif($4, ''), case when $4 != '' then now() end, now()
+			)
+			returning id
+		`
+
+		// Unverified emails are not stored to avoid taking the email of another account.
+		email := account.Email
+		if !emailVerified {
+			email = ""
+		}
+
+		err = tx.QueryRow(query, account.Name, account.LastName, account.Nickname, email).Scan(&id)
+		if err != nil {
+			if pqErr, ok := newPQError(err); ok {
+				if match, aErr := pqErr.asAlreadyExists(); match {
+					err = aErr
+					return
+				}
+			}
+			err = fmt.Errorf("failed to create identity account: %s", err)
+			return
+		}
+	}
+
+	query = `
+		insert into account_identity (account_id, provider, subject, created_at)
+		values ($1, $2, $3, now())
+	`
+
+	_, err = tx.Exec(query, id, provider, subject)
+	if err != nil {
+		err = fmt.Errorf("failed to link account identity: %s", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit identity sign: %s", err)
+	}
+	return
+}
+
+func (u AuthRepository) LinkIdentity(token auth.ActionToken, provider, subject string) (err error) {
+	tx, err := u.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin identity link: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	err = useActionToken(tx, token)
+	if err != nil {
+		return
+	}
+
+	query := `
+		insert into account_identity (account_id, provider, subject, created_at)
+		select id, $2, $3, now() from account where id = $1 and deleted_at is null
+	`
+
+	res, err := tx.Exec(query, token.AccountID, provider, subject)
+	if err != nil {
+		if pqErr, ok := newPQError(err); ok && pqErr.violates("account_identity_provider_subject_key") {
+			err = sErrors.NewClientError(http.StatusConflict, "already exists: %s identity is already linked to a account", provider)
+			return
+		}
+		err = fmt.Errorf("failed to link account identity: %s", err)
+		return
+	}
+	err = checkActionAffected(res)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit identity link: %s", err)
+	}
+	return
+}
+
+func (u AuthRepository) RestoreAccount(id int) (restored bool, err error) {
+	query := `
+		update account set deleted_at = null, updated_at = now()
+		where id = $1 and deleted_at is not null and anonymized_at is null
+	`
+
+	res, err := u.db.Exec(query, id)
+	if err != nil {
+		err = fmt.Errorf("failed to restore account: %s", err)
+		return
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("failed to restore account: %s", err)
+		return
+	}
+	restored = n > 0
+	return
+}
+
+func (u AuthRepository) GetAccountEmail(id int) (email string, verified bool, err error) {
+	query := `
+		select coalesce(email, ''), email_verified_at is not null from account where id = $1 and deleted_at is null
+	`
+
+	err = u.db.QueryRow(query, id).Scan(&email, &verified)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = account.NewNotFoundError(id)
+			return
+		}
+		err = fmt.Errorf("failed to get account email: %s", err)
+	}
+	return
+}
+
+func (u AuthRepository) GetAccountIDByEmail(email string) (id int, err error) {
+	err = u.db.QueryRow(`select id from account where email = $1 and deleted_at is null`, email).Scan(&id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			err = nil
 			return
 		}
-		err = fmt.Errorf("failed to get account credentials: %s", err)
+		err = fmt.Errorf("failed to get account by email: %s", err)
+	}
+	return
+}
+
+func (u AuthRepository) SaveActionToken(token auth.ActionToken) (err error) {
+	query := `
+		insert into account_token (id, account_id, purpose, created_at, expires_at)
+		values ($1, $2, $3, now(), $4)
+	`
+
+	_, err = u.db.Exec(query, auth.HashToken(token.ID), token.AccountID, token.Purpose, token.ExpiresAt)
+	if err != nil {
+		err = fmt.Errorf("failed to save action token: %s", err)
+	}
+	return
+}
+
+func (u AuthRepository) HasRecentActionToken(accountID int, purpose auth.ActionPurpose, since time.Time) (exists bool, err error) {
+	query := `
+		select exists (
+			select 1 from account_token
+			where account_id = $1 and purpose = $2 and created_at > $3 and used_at is null and expires_at > now()
+		)
+	`
+
+	err = u.db.QueryRow(query, accountID, purpose, since).Scan(&exists)
+	if err != nil {
+		err = fmt.Errorf("failed to check recent action tokens: %s", err)
+	}
+	return
+}
+
+func (u AuthRepository) VerifyEmail(token auth.ActionToken) (err error) {
+	tx, err := u.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin email verification: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	err = useActionToken(tx, token)
+	if err != nil {
+		return
+	}
+
+	query := `
+		update account set email_verified_at = coalesce(email_verified_at, now())
+		where id = $1 and deleted_at is null and email is not null
+	`
+
+	res, err := tx.Exec(query, token.AccountID)
+	if err != nil {
+		err = fmt.Errorf("failed to verify account email: %s", err)
+		return
+	}
+	err = checkActionAffected(res)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit email verification: %s", err)
+	}
+	return
+}
+
+func (u AuthRepository) ResetPassword(token auth.ActionToken, hash string) (err error) {
+	tx, err := u.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin password reset: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	err = useActionToken(tx, token)
+	if err != nil {
+		return
+	}
+
+	query := `
+		update account set password = $2, updated_at = now() where id = $1 and deleted_at is null
+	`
+
+	res, err := tx.Exec(query, token.AccountID, hash)
+	if err != nil {
+		err = fmt.Errorf("failed to reset account password: %s", err)
+		return
+	}
+	err = checkActionAffected(res)
+	if err != nil {
+		return
+	}
+
+	query = `
+		update account_token set used_at = now()
+		where account_id = $1 and purpose = $2 and used_at is null
+	`
+
+	_, err = tx.Exec(query, token.AccountID, token.Purpose)
+	if err != nil {
+		err = fmt.Errorf("failed to invalidate reset tokens: %s", err)
+		return
+	}
+
+	_, err = tx.Exec(`update account_session set actived = false where account_id = $1 and actived`, token.AccountID)
+	if err != nil {
+		err = fmt.Errorf("failed to close account sessions: %s", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit password reset: %s", err)
+	}
+	return
+}
+
+// useActionToken marks a not used nor expired action token as used.
+func useActionToken(tx *sql.Tx, token auth.ActionToken) (err error) {
+	query := `
+		update account_token set used_at = now()
+		where id = $1 and account_id = $2 and purpose = $3 and used_at is null and expires_at > now()
+	`
+
+	res, err := tx.Exec(query, auth.HashToken(token.ID), token.AccountID, token.Purpose)
+	if err != nil {
+		err = fmt.Errorf("failed to use action token: %s", err)
+		return
+	}
+	err = checkActionAffected(res)
+	return
+}
+
+// checkActionAffected checks the action token update changed a row, otherwise the token is not valid anymore.
+func checkActionAffected(res sql.Result) (err error) {
+	n, err := res.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("failed to use action token: %s", err)
+		return
+	}
+	if n == 0 {
+		err = auth.NewInvalidActionTokenError()
 	}
 	return
 }
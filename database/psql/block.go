@@ -0,0 +1,232 @@
+package psql
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/coffemanfp/chat/account"
+	"github.com/coffemanfp/chat/database"
+	sErrors "github.com/coffemanfp/chat/errors"
+	"github.com/lib/pq"
+)
+
+// BlockRepository is the implementation of a block repository for the PostgreSQL database.
+type BlockRepository struct {
+	db *sql.DB
+}
+
+// NewBlockRepository initializes a new block repository instance.
+//
+//	@param conn *PostgreSQLConnector: is the PostgreSQLConnector handler.
+//	@return repo database.BlockRepository: is the final interface to keep
+//	 the BlockRepository implementation.
+//	@return err error: database connection error.
+func NewBlockRepository(conn *PostgreSQLConnector) (repo database.BlockRepository, err error) {
+	db, err := conn.getConn()
+	if err != nil {
+		return
+	}
+	repo = BlockRepository{
+		db: db,
+	}
+	return
+}
+
+func (b BlockRepository) Block(fromAccountID, toAccountID int) (err error) {
+	tx, err := b.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin account blocking: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	query := `
+		insert into blocked_account (from_account_id, to_account_id, created_at)
+		select $1, a.id, now() from account a
+		where a.id = $2 and a.deleted_at is null
+	`
+
+	res, err := tx.Exec(query, fromAccountID, toAccountID)
+	if err != nil {
+		if pqErr, ok := newPQError(err); ok {
+			switch {
+			case pqErr.violates("idx_blocked_account_from_to"):
+				err = sErrors.NewClientError(http.StatusConflict, "already exists: account %d is already blocked", toAccountID)
+				return
+			case pqErr.violates("blocked_account_not_self"):
+				err = sErrors.NewClientError(http.StatusBadRequest, "invalid block: accounts can't block themselves")
+				return
+			}
+		}
+		err = fmt.Errorf("failed to block account: %s", err)
+		return
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("failed to block account: %s", err)
+		return
+	}
+	if n == 0 {
+		err = sErrors.NewClientError(http.StatusNotFound, "not found: account %d not found", toAccountID)
+		return
+	}
+
+	err = moveUnreadCounts(tx, fromAccountID, toAccountID, -1)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit account blocking: %s", err)
+	}
+	return
+}
+
+func (b BlockRepository) Unblock(fromAccountID, toAccountID int) (err error) {
+	tx, err := b.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin account unblocking: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	query := `
+		delete from blocked_account where from_account_id = $1 and to_account_id = $2
+	`
+
+	res, err := tx.Exec(query, fromAccountID, toAccountID)
+	if err != nil {
+		err = fmt.Errorf("failed to unblock account: %s", err)
+		return
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("failed to unblock account: %s", err)
+		return
+	}
+	if n == 0 {
+		err = sErrors.NewClientError(http.StatusNotFound, "not found: account %d is not blocked", toAccountID)
+		return
+	}
+
+	err = moveUnreadCounts(tx, fromAccountID, toAccountID, 1)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit account unblocking: %s", err)
+	}
+	return
+}
+
+// moveUnreadCounts adds or discounts the unread messages of the blocked account to the unread counters of the blocker,
+// in the conversations shared by both.
+func moveUnreadCounts(tx *sql.Tx, fromAccountID, toAccountID, sign int) (err error) {
+	query := `
+		update convesation_members m set unread_count = greatest(m.unread_count + $3 * (
+			select count(*) from message msg
+			where msg.conversation_id = m.conversation_id and msg.account_id = $2
+			and msg.seq > m.last_read_seq and msg.deleted_at is null
+		), 0)
+		where m.account_id = $1 and m.left_at is null
+		and m.conversation_id in (select conversation_id from convesation_members where account_id = $2)
+	`
+
+	_, err = tx.Exec(query, fromAccountID, toAccountID, sign)
+	if err != nil {
+		err = fmt.Errorf("failed to update unread counters: %s", err)
+	}
+	return
+}
+
+func (b BlockRepository) GetBlocked(fromAccountID, limit, offset int) (blocked []account.BlockedAccount, total int, err error) {
+	err = b.db.QueryRow(`select count(*) from blocked_account where from_account_id = $1`, fromAccountID).Scan(&total)
+	if err != nil {
+		err = fmt.Errorf("failed to count blocked accounts: %s", err)
+		return
+	}
+
+	query := `
+		select a.id, coalesce(a.nickname, ''), a.name, coalesce(a.last_name, ''), coalesce(a.picture_url, ''), b.created_at
+		from blocked_account b
+		inner join account a on a.id = b.to_account_id
+		where b.from_account_id = $1
+		order by b.created_at desc, b.id desc
+		limit $2 offset $3
+	`
+
+	rows, err := b.db.Query(query, fromAccountID, limit, offset)
+	if err != nil {
+		err = fmt.Errorf("failed to get blocked accounts: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	blocked = []account.BlockedAccount{}
+	for rows.Next() {
+		var ba account.BlockedAccount
+		err = rows.Scan(&ba.Profile.ID, &ba.Profile.Nickname, &ba.Profile.Name, &ba.Profile.LastName, &ba.Profile.PictureURL, &ba.CreatedAt)
+		if err != nil {
+			err = fmt.Errorf("failed to scan blocked account: %s", err)
+			return
+		}
+		blocked = append(blocked, ba)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to get blocked accounts: %s", err)
+	}
+	return
+}
+
+func (b BlockRepository) GetBlockerIDs(accountID int, amongIDs []int) (ids []int, err error) {
+	query := `
+		select from_account_id from blocked_account
+		where to_account_id = $1 and from_account_id = any($2)
+	`
+	return b.getIDs(query, accountID, amongIDs)
+}
+
+func (b BlockRepository) GetBlockedIDs(accountID int, amongIDs []int) (ids []int, err error) {
+	query := `
+		select to_account_id from blocked_account
+		where from_account_id = $1 and to_account_id = any($2)
+	`
+	return b.getIDs(query, accountID, amongIDs)
+}
+
+func (b BlockRepository) getIDs(query string, accountID int, amongIDs []int) (ids []int, err error) {
+	if len(amongIDs) == 0 {
+		return
+	}
+
+	rows, err := b.db.Query(query, accountID, pq.Array(amongIDs))
+	if err != nil {
+		err = fmt.Errorf("failed to get blocks: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			err = fmt.Errorf("failed to scan block: %s", err)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to get blocks: %s", err)
+	}
+	return
+}
@@ -0,0 +1,217 @@
+package psql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/coffemanfp/chat/contact"
+	"github.com/coffemanfp/chat/database"
+	sErrors "github.com/coffemanfp/chat/errors"
+)
+
+// ContactRepository is the implementation of a contact repository for the PostgreSQL database.
+type ContactRepository struct {
+	db *sql.DB
+}
+
+// NewContactRepository initializes a new contact repository instance.
+//
+//	@param conn *PostgreSQLConnector: is the PostgreSQLConnector handler.
+//	@return repo database.ContactRepository: is the final interface to keep
+//	 the ContactRepository implementation.
+//	@return err error: database connection error.
+func NewContactRepository(conn *PostgreSQLConnector) (repo database.ContactRepository, err error) {
+	db, err := conn.getConn()
+	if err != nil {
+		return
+	}
+	repo = ContactRepository{
+		db: db,
+	}
+	return
+}
+
+// contactColumns are the selected columns of a contact joined with its account, in the scanContact order.
+const contactColumns = `
+	c.id, c.from_account_id, c.name, coalesce(c.last_name, ''), c.created_at,
+	a.id, coalesce(a.nickname, ''), a.name, coalesce(a.last_name, ''), coalesce(a.picture_url, '')
+`
+
+func (c ContactRepository) AddContact(contactR contact.Contact, target string) (ct contact.Contact, err error) {
+	// Just one account is saved. The nickname of a account can be the email of another one,
+	// so the account with the target as nickname is preferred.
+	query := `
+		insert into contact (from_account_id, to_account_id, name, last_name, created_at)
+		select $1, a.id, coalesce(nullif($3, ''), a.name), coalesce(nullif($4, ''), a.last_name), $5 from (
+			select id, name, last_name from account
+			where (nickname = $2 or email = $2) and deleted_at is null
+			order by case when nickname = $2 then 0 else 1 end
+			limit 1
+		) a
+		returning id
+	`
+
+	var id int
+	err = c.db.QueryRow(query, contactR.FromAccountID, strings.TrimSpace(target), contactR.Name, contactR.LastName, contactR.CreatedAt).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = sErrors.NewClientError(http.StatusNotFound, "not found: account %s not found", target)
+			return
+		}
+		if pqErr, ok := newPQError(err); ok {
+			switch {
+			case pqErr.violates("idx_contact_from_to"):
+				err = sErrors.NewClientError(http.StatusConflict, "already exists: account %s is already a contact", target)
+				return
+			case pqErr.violates("contact_not_self"):
+				err = sErrors.NewClientError(http.StatusBadRequest, "invalid contact: accounts can't save themselves")
+				return
+			}
+		}
+		err = fmt.Errorf("failed to add contact: %s", err)
+		return
+	}
+
+	return c.GetContact(contactR.FromAccountID, id)
+}
+
+func (c ContactRepository) GetContact(fromAccountID, id int) (ct contact.Contact, err error) {
+	query := `
+		select ` + contactColumns + ` from contact c
+		inner join account a on a.id = c.to_account_id
+		where c.from_account_id = $1 and c.id = $2 and a.deleted_at is null
+	`
+
+	err = scanContact(c.db.QueryRow(query, fromAccountID, id), &ct)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = contact.NewNotFoundError(id)
+			return
+		}
+		err = fmt.Errorf("failed to get contact: %s", err)
+	}
+	return
+}
+
+func (c ContactRepository) GetContacts(fromAccountID int, q contact.Query) (contacts []contact.Contact, total int, err error) {
+	where := `c.from_account_id = $1 and a.deleted_at is null`
+	args := []interface{}{fromAccountID}
+	if q.Search != "" {
+		args = append(args, "%"+escapeLike(q.Search)+"%")
+		where += ` and (c.name ilike $2 or c.last_name ilike $2 or a.nickname ilike $2)`
+	}
+
+	err = c.db.QueryRow(`
+		select count(*) from contact c
+		inner join account a on a.id = c.to_account_id
+		where `+where, args...).Scan(&total)
+	if err != nil {
+		err = fmt.Errorf("failed to count contacts: %s", err)
+		return
+	}
+
+	// The order is built from known values, never from the raw client input.
+	direction := "asc"
+	if q.Descending {
+		direction = "desc"
+	}
+	order := fmt.Sprintf("lower(c.name) %[1]s, lower(c.last_name) %[1]s, c.id %[1]s", direction)
+	if q.Sort == contact.SortByCreatedAt {
+		order = fmt.Sprintf("c.created_at %[1]s, c.id %[1]s", direction)
+	}
+
+	args = append(args, q.Limit, q.Offset)
+	query := fmt.Sprintf(`
+		select `+contactColumns+` from contact c
+		inner join account a on a.id = c.to_account_id
+		where %s
+		order by %s
+		limit $%d offset $%d
+	`, where, order, len(args)-1, len(args))
+
+	rows, err := c.db.Query(query, args...)
+	if err != nil {
+		err = fmt.Errorf("failed to get contacts: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	contacts = []contact.Contact{}
+	for rows.Next() {
+		var ct contact.Contact
+		err = scanContact(rows, &ct)
+		if err != nil {
+			err = fmt.Errorf("failed to scan contact: %s", err)
+			return
+		}
+		contacts = append(contacts, ct)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to get contacts: %s", err)
+	}
+	return
+}
+
+func (c ContactRepository) UpdateContact(ct contact.Contact) (err error) {
+	query := `
+		update contact set name = $1, last_name = $2
+		where from_account_id = $3 and id = $4
+	`
+
+	res, err := c.db.Exec(query, ct.Name, ct.LastName, ct.FromAccountID, ct.ID)
+	if err != nil {
+		err = fmt.Errorf("failed to update contact: %s", err)
+		return
+	}
+	return checkContactAffected(res, ct.ID)
+}
+
+func (c ContactRepository) DeleteContact(fromAccountID, id int) (err error) {
+	query := `
+		delete from contact where from_account_id = $1 and id = $2
+	`
+
+	res, err := c.db.Exec(query, fromAccountID, id)
+	if err != nil {
+		err = fmt.Errorf("failed to delete contact: %s", err)
+		return
+	}
+	return checkContactAffected(res, id)
+}
+
+func scanContact(row rowScanner, ct *contact.Contact) error {
+	return row.Scan(
+		&ct.ID,
+		&ct.FromAccountID,
+		&ct.Name,
+		&ct.LastName,
+		&ct.CreatedAt,
+		&ct.Profile.ID,
+		&ct.Profile.Nickname,
+		&ct.Profile.Name,
+		&ct.Profile.LastName,
+		&ct.Profile.PictureURL,
+	)
+}
+
+func checkContactAffected(res sql.Result, id int) (err error) {
+	n, err := res.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("failed to check contact changes: %s", err)
+		return
+	}
+	if n == 0 {
+		err = contact.NewNotFoundError(id)
+	}
+	return
+}
+
+// escapeLike escapes the wildcards of a like pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
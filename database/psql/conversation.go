@@ -0,0 +1,1023 @@
+package psql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/coffemanfp/chat/account"
+	"github.com/coffemanfp/chat/conversation"
+	"github.com/coffemanfp/chat/database"
+	sErrors "github.com/coffemanfp/chat/errors"
+)
+
+// ConversationRepository is the implementation of a conversation repository for the PostgreSQL database.
+type ConversationRepository struct {
+	db *sql.DB
+}
+
+// NewConversationRepository initializes a new conversation repository instance.
+//
+//	@param conn *PostgreSQLConnector: is the PostgreSQLConnector handler.
+//	@return repo database.ConversationRepository: is the final interface to keep
+//	 the ConversationRepository implementation.
+//	@return err error: database connection error.
+func NewConversationRepository(conn *PostgreSQLConnector) (repo database.ConversationRepository, err error) {
+	db, err := conn.getConn()
+	if err != nil {
+		return
+	}
+	repo = ConversationRepository{
+		db: db,
+	}
+	return
+}
+
+func (c ConversationRepository) CreateConversation(conversationR conversation.Conversation, ownerID int, memberIDs []int) (conv conversation.Conversation, err error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin conversation creation: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	query := `
+		insert into conversation (capacity_members, name, picture_url, created_at)
+		values ($1, $2, $3, $4)
+		returning id
+	`
+
+	conv = conversationR
+	err = tx.QueryRow(query, conv.CapacityMembers, conv.Name, conv.PictureURL, conv.CreatedAt).Scan(&conv.ID)
+	if err != nil {
+		err = fmt.Errorf("failed to create conversation: %s", err)
+		return
+	}
+
+	err = insertMembers(tx, conv.ID, []int{ownerID}, conversation.OwnerRole)
+	if err != nil {
+		return
+	}
+
+	err = insertMembers(tx, conv.ID, memberIDs, conversation.MemberRole)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit conversation creation: %s", err)
+	}
+	return
+}
+
+func (c ConversationRepository) GetOrCreateDirect(conversationR conversation.Conversation, accountID, peerID int) (conv conversation.Conversation, created bool, err error) {
+	// The pair is stored sorted, so the unique index covers both directions.
+	low, high := accountID, peerID
+	if low > high {
+		low, high = high, low
+	}
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin direct conversation creation: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRow(`select exists (select 1 from account where id = $1 and deleted_at is null)`, peerID).Scan(&exists)
+	if err != nil {
+		err = fmt.Errorf("failed to check direct conversation peer: %s", err)
+		return
+	}
+	if !exists {
+		err = sErrors.NewClientError(http.StatusNotFound, "not found: account %d not found", peerID)
+		return
+	}
+
+	// The concurrent creations of the same pair wait on the unique index, and then find the created one.
+	// The deleted conversations are out of the index, so a new conversation replaces them.
+	query := `
+		insert into conversation (capacity_members, name, picture_url, created_at, type, direct_low_account_id, direct_high_account_id)
+		values ($1, '', '', $2, $3, $4, $5)
+		on conflict (direct_low_account_id, direct_high_account_id) where type = 'direct' and deleted_at is null do nothing
+		returning id
+	`
+
+	conv = conversationR
+	err = tx.QueryRow(query, conv.CapacityMembers, conv.CreatedAt, conversation.DirectType, low, high).Scan(&conv.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		query = `
+			select ` + conversationColumns + ` from conversation c
+			where c.type = $1 and c.direct_low_account_id = $2 and c.direct_high_account_id = $3 and c.deleted_at is null
+		`
+
+		err = scanConversation(tx.QueryRow(query, conversation.DirectType, low, high), &conv)
+		if err != nil {
+			err = fmt.Errorf("failed to get direct conversation: %s", err)
+		}
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to create direct conversation: %s", err)
+		return
+	}
+
+	err = insertMembers(tx, conv.ID, []int{accountID, peerID}, conversation.MemberRole)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit direct conversation creation: %s", err)
+		return
+	}
+	created = true
+	return
+}
+
+func (c ConversationRepository) GetDirectPeer(conversationID, accountID int) (peer account.Profile, err error) {
+	query := `
+		select a.id, coalesce(a.nickname, ''), a.name, coalesce(a.last_name, ''), coalesce(a.picture_url, '')
+		from conversation c
+		inner join account a on a.id = ` + directPeerColumn + `
+		where c.id = $1 and c.type = $3 and c.deleted_at is null
+		and $2 in (c.direct_low_account_id, c.direct_high_account_id)
+	`
+
+	err = c.db.QueryRow(query, conversationID, accountID, conversation.DirectType).Scan(&peer.ID, &peer.Nickname, &peer.Name, &peer.LastName, &peer.PictureURL)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = conversation.NewNotFoundError(conversationID)
+			return
+		}
+		err = fmt.Errorf("failed to get direct conversation peer: %s", err)
+	}
+	return
+}
+
+// conversationColumns are the selected columns of a conversation, in the scanConversation order.
+const conversationColumns = `c.id, c.type, c.name, c.picture_url, c.capacity_members, c.created_at`
+
+// directPeerColumn is the id of the other participant of a direct conversation of the account $2.
+const directPeerColumn = `case when c.direct_low_account_id = $2 then c.direct_high_account_id else c.direct_low_account_id end`
+
+func scanConversation(row rowScanner, conv *conversation.Conversation) error {
+	return row.Scan(&conv.ID, &conv.Type, &conv.Name, &conv.PictureURL, &conv.CapacityMembers, &conv.CreatedAt)
+}
+
+func (c ConversationRepository) GetConversation(id int) (conv conversation.Conversation, err error) {
+	query := `
+		select ` + conversationColumns + ` from conversation c
+		where c.id = $1 and c.deleted_at is null
+	`
+
+	err = scanConversation(c.db.QueryRow(query, id), &conv)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = conversation.NewNotFoundError(id)
+			return
+		}
+		err = fmt.Errorf("failed to get conversation: %s", err)
+	}
+	return
+}
+
+func (c ConversationRepository) GetConversations(accountID int) (convs []conversation.Summary, err error) {
+	// The unread counter is kept by member on the messages saving, so the messages are not counted here.
+	query := `
+		select ` + conversationColumns + `, m.unread_count,
+			p.id, coalesce(p.nickname, ''), coalesce(p.name, ''), coalesce(p.last_name, ''), coalesce(p.picture_url, '')
+		from conversation c
+		inner join convesation_members m on m.conversation_id = c.id
+		left join account p on c.type = $3 and p.id = ` + directPeerColumn + `
+		where m.account_id = $1 and m.left_at is null and c.deleted_at is null
+		order by c.created_at desc, c.id desc
+	`
+
+	rows, err := c.db.Query(query, accountID, accountID, conversation.DirectType)
+	if err != nil {
+		err = fmt.Errorf("failed to get conversations: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	convs = []conversation.Summary{}
+	for rows.Next() {
+		var (
+			conv   conversation.Summary
+			peerID sql.NullInt64
+			peer   account.Profile
+		)
+		err = rows.Scan(
+			&conv.ID, &conv.Type, &conv.Name, &conv.PictureURL, &conv.CapacityMembers, &conv.CreatedAt, &conv.UnreadCount,
+			&peerID, &peer.Nickname, &peer.Name, &peer.LastName, &peer.PictureURL,
+		)
+		if err != nil {
+			err = fmt.Errorf("failed to scan conversation: %s", err)
+			return
+		}
+		if peerID.Valid {
+			peer.ID = int(peerID.Int64)
+			conv.Conversation = conv.Conversation.WithPeer(peer)
+		}
+		convs = append(convs, conv)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to get conversations: %s", err)
+	}
+	return
+}
+
+func (c ConversationRepository) UpdateConversation(conv conversation.Conversation) (err error) {
+	query := `
+		update conversation set name = $1, picture_url = $2
+		where id = $3 and deleted_at is null
+	`
+
+	res, err := c.db.Exec(query, conv.Name, conv.PictureURL, conv.ID)
+	if err != nil {
+		err = fmt.Errorf("failed to update conversation: %s", err)
+		return
+	}
+	return checkConversationAffected(res, conv.ID)
+}
+
+func (c ConversationRepository) DeleteConversation(id int) (err error) {
+	query := `
+		update conversation set deleted_at = now()
+		where id = $1 and deleted_at is null
+	`
+
+	res, err := c.db.Exec(query, id)
+	if err != nil {
+		err = fmt.Errorf("failed to delete conversation: %s", err)
+		return
+	}
+	return checkConversationAffected(res, id)
+}
+
+func (c ConversationRepository) GetMemberIDs(conversationID int) (ids []int, err error) {
+	query := `
+		select m.account_id from convesation_members m
+		inner join conversation c on c.id = m.conversation_id
+		where m.conversation_id = $1 and m.left_at is null and c.deleted_at is null
+	`
+
+	rows, err := c.db.Query(query, conversationID)
+	if err != nil {
+		err = fmt.Errorf("failed to get conversation members: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			err = fmt.Errorf("failed to scan conversation member: %s", err)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to get conversation members: %s", err)
+	}
+	return
+}
+
+func (c ConversationRepository) GetMembers(conversationID int) (members []conversation.Member, err error) {
+	query := `
+		select m.account_id, r.name, m.joined_at from convesation_members m
+		inner join conversation_role r on r.id = m.role_id
+		where m.conversation_id = $1 and m.left_at is null
+		order by m.joined_at, m.id
+	`
+
+	rows, err := c.db.Query(query, conversationID)
+	if err != nil {
+		err = fmt.Errorf("failed to get conversation members: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	members = []conversation.Member{}
+	for rows.Next() {
+		var member conversation.Member
+		err = rows.Scan(&member.AccountID, &member.Role, &member.JoinedAt)
+		if err != nil {
+			err = fmt.Errorf("failed to scan conversation member: %s", err)
+			return
+		}
+		members = append(members, member)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to get conversation members: %s", err)
+	}
+	return
+}
+
+func (c ConversationRepository) AddMembers(conversationID int, accountIDs []int) (err error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin members addition: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = addMembers(tx, conversationID, accountIDs)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit members addition: %s", err)
+	}
+	return
+}
+
+func (c ConversationRepository) RemoveMember(conversationID, accountID int) (err error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin member removal: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	err = lockConversation(tx, conversationID)
+	if err != nil {
+		return
+	}
+
+	query := `
+		update convesation_members set left_at = now()
+		where conversation_id = $1 and account_id = $2 and left_at is null
+	`
+
+	res, err := tx.Exec(query, conversationID, accountID)
+	if err != nil {
+		err = fmt.Errorf("failed to remove member: %s", err)
+		return
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("failed to remove member: %s", err)
+		return
+	}
+	if n == 0 {
+		err = sErrors.NewClientError(http.StatusNotFound, "not found: account %d is not a member of conversation %d", accountID, conversationID)
+		return
+	}
+
+	err = checkRoleManagers(tx, conversationID)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit member removal: %s", err)
+	}
+	return
+}
+
+func (c ConversationRepository) CreateInvite(inviteR conversation.Invite, tokenHash string) (invite conversation.Invite, err error) {
+	query := `
+		insert into conversation_invite (conversation_id, created_by, token_hash, max_uses, expires_at, created_at)
+		values ($1, $2, $3, $4, $5, $6)
+		returning id
+	`
+
+	invite = inviteR
+	err = c.db.QueryRow(query, invite.ConversationID, invite.CreatedBy, tokenHash, invite.MaxUses, invite.ExpiresAt, invite.CreatedAt).Scan(&invite.ID)
+	if err != nil {
+		err = fmt.Errorf("failed to create invite: %s", err)
+	}
+	return
+}
+
+func (c ConversationRepository) GetInvites(conversationID int) (invites []conversation.Invite, err error) {
+	query := `
+		select id, conversation_id, created_by, max_uses, uses, expires_at, created_at from conversation_invite
+		where conversation_id = $1 and revoked_at is null and expires_at > now() and (max_uses = 0 or uses < max_uses)
+		order by created_at desc, id desc
+	`
+
+	rows, err := c.db.Query(query, conversationID)
+	if err != nil {
+		err = fmt.Errorf("failed to get invites: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	invites = []conversation.Invite{}
+	for rows.Next() {
+		var invite conversation.Invite
+		err = scanInvite(rows, &invite)
+		if err != nil {
+			err = fmt.Errorf("failed to scan invite: %s", err)
+			return
+		}
+		invites = append(invites, invite)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to get invites: %s", err)
+	}
+	return
+}
+
+func (c ConversationRepository) RevokeInvite(conversationID, inviteID int) (err error) {
+	query := `
+		update conversation_invite set revoked_at = now()
+		where id = $1 and conversation_id = $2 and revoked_at is null
+	`
+
+	res, err := c.db.Exec(query, inviteID, conversationID)
+	if err != nil {
+		err = fmt.Errorf("failed to revoke invite: %s", err)
+		return
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("failed to revoke invite: %s", err)
+		return
+	}
+	if n == 0 {
+		err = conversation.NewInviteNotFoundError()
+	}
+	return
+}
+
+func (c ConversationRepository) JoinWithInvite(tokenHash string, accountID int) (conversationID int, err error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin invite join: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	// The invite row is locked to count its uses exactly.
+	query := `
+		select id, conversation_id, created_by, max_uses, uses, expires_at, created_at from conversation_invite
+		where token_hash = $1 and revoked_at is null
+		for update
+	`
+
+	var invite conversation.Invite
+	err = scanInvite(tx.QueryRow(query, tokenHash), &invite)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = conversation.NewInviteNotFoundError()
+			return
+		}
+		err = fmt.Errorf("failed to get invite: %s", err)
+		return
+	}
+
+	err = conversation.CheckUsable(invite, time.Now())
+	if err != nil {
+		return
+	}
+	conversationID = invite.ConversationID
+
+	added, err := addMembers(tx, conversationID, []int{accountID})
+	if err != nil {
+		return
+	}
+
+	// The current members don't spend the invite uses.
+	if len(added) != 0 {
+		_, err = tx.Exec(`update conversation_invite set uses = uses + 1 where id = $1`, invite.ID)
+		if err != nil {
+			err = fmt.Errorf("failed to use invite: %s", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit invite join: %s", err)
+	}
+	return
+}
+
+func scanInvite(row rowScanner, invite *conversation.Invite) error {
+	return row.Scan(
+		&invite.ID,
+		&invite.ConversationID,
+		&invite.CreatedBy,
+		&invite.MaxUses,
+		&invite.Uses,
+		&invite.ExpiresAt,
+		&invite.CreatedAt,
+	)
+}
+
+// addMembers adds the accounts which are not current members to the conversation, enforcing its capacity.
+// The former members join again with a new membership, keeping their history.
+func addMembers(tx *sql.Tx, conversationID int, accountIDs []int) (added []int, err error) {
+	// The conversation row is locked to serialize the capacity checks of concurrent additions.
+	conv := conversation.Conversation{ID: conversationID}
+	query := `
+		select capacity_members from conversation where id = $1 and deleted_at is null for update
+	`
+
+	err = tx.QueryRow(query, conversationID).Scan(&conv.CapacityMembers)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = conversation.NewNotFoundError(conversationID)
+			return
+		}
+		err = fmt.Errorf("failed to get conversation capacity: %s", err)
+		return
+	}
+
+	current, err := getActiveMemberIDs(tx, conversationID)
+	if err != nil {
+		return
+	}
+
+	for _, id := range accountIDs {
+		if !current[id] {
+			added = append(added, id)
+		}
+	}
+
+	err = conversation.ValidateMembers(conv, len(current), len(added))
+	if err != nil {
+		return
+	}
+
+	err = insertMembers(tx, conversationID, added, conversation.MemberRole)
+	return
+}
+
+// roleColumns are the selected columns of a role joined with its permissions, in the scanRole order.
+const roleColumns = `
+	r.id, coalesce(r.conversation_id, 0), r.name, coalesce(r.description, ''),
+	p.write, p.kick_account, p.add_account, p.change_role, p.change_conversation_detail, p.delete_message
+`
+
+func (c ConversationRepository) GetMembership(conversationID, accountID int) (membership conversation.Membership, err error) {
+	query := `
+		select m.joined_at, ` + roleColumns + ` from convesation_members m
+		inner join conversation c on c.id = m.conversation_id
+		inner join conversation_role r on r.id = m.role_id
+		inner join conversation_role_permissions p on p.id = r.permissions_id
+		where m.conversation_id = $1 and m.account_id = $2 and m.left_at is null and c.deleted_at is null
+	`
+
+	row := c.db.QueryRow(query, conversationID, accountID)
+	err = scanRole(prefixScanner{row, &membership.JoinedAt}, &membership.Role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = conversation.NewNotFoundError(conversationID)
+			return
+		}
+		err = fmt.Errorf("failed to get membership: %s", err)
+	}
+	return
+}
+
+func (c ConversationRepository) GetMemberRole(conversationID, accountID int) (role conversation.Role, err error) {
+	membership, err := c.GetMembership(conversationID, accountID)
+	role = membership.Role
+	return
+}
+
+func (c ConversationRepository) GetRole(conversationID, roleID int) (role conversation.Role, err error) {
+	query := `
+		select ` + roleColumns + ` from conversation_role r
+		inner join conversation_role_permissions p on p.id = r.permissions_id
+		where r.id = $2 and (r.conversation_id is null or r.conversation_id = $1)
+	`
+
+	err = scanRole(c.db.QueryRow(query, conversationID, roleID), &role)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = conversation.NewRoleNotFoundError(roleID)
+			return
+		}
+		err = fmt.Errorf("failed to get role: %s", err)
+	}
+	return
+}
+
+func (c ConversationRepository) GetRoles(conversationID int) (roles []conversation.Role, err error) {
+	query := `
+		select ` + roleColumns + ` from conversation_role r
+		inner join conversation_role_permissions p on p.id = r.permissions_id
+		where r.conversation_id is null or r.conversation_id = $1
+		order by r.conversation_id nulls first, r.id
+	`
+
+	rows, err := c.db.Query(query, conversationID)
+	if err != nil {
+		err = fmt.Errorf("failed to get roles: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	roles = []conversation.Role{}
+	for rows.Next() {
+		var role conversation.Role
+		err = scanRole(rows, &role)
+		if err != nil {
+			err = fmt.Errorf("failed to scan role: %s", err)
+			return
+		}
+		roles = append(roles, role)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to get roles: %s", err)
+	}
+	return
+}
+
+func (c ConversationRepository) CreateRole(roleR conversation.Role) (role conversation.Role, err error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin role creation: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	query := `
+		insert into conversation_role_permissions (write, kick_account, add_account, change_role, change_conversation_detail, delete_message)
+		values ($1, $2, $3, $4, $5, $6)
+		returning id
+	`
+
+	role = roleR
+	p := role.Permissions
+	var permissionsID int
+	err = tx.QueryRow(query, p.Write, p.KickAccount, p.AddAccount, p.ChangeRole, p.ChangeConversationDetail, p.DeleteMessage).Scan(&permissionsID)
+	if err != nil {
+		err = fmt.Errorf("failed to create role permissions: %s", err)
+		return
+	}
+
+	query = `
+		insert into conversation_role (permissions_id, conversation_id, name, description)
+		values ($1, $2, $3, $4)
+		returning id
+	`
+
+	err = tx.QueryRow(query, permissionsID, role.ConversationID, role.Name, role.Description).Scan(&role.ID)
+	if err != nil {
+		err = asRoleAlreadyExists(err, "failed to create role")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit role creation: %s", err)
+	}
+	return
+}
+
+func (c ConversationRepository) UpdateRole(role conversation.Role) (err error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin role update: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	err = lockConversation(tx, role.ConversationID)
+	if err != nil {
+		return
+	}
+
+	query := `
+		update conversation_role set name = $1, description = $2
+		where id = $3 and conversation_id = $4
+		returning permissions_id
+	`
+
+	var permissionsID int
+	err = tx.QueryRow(query, role.Name, role.Description, role.ID, role.ConversationID).Scan(&permissionsID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = conversation.NewRoleNotFoundError(role.ID)
+			return
+		}
+		err = asRoleAlreadyExists(err, "failed to update role")
+		return
+	}
+
+	query = `
+		update conversation_role_permissions
+		set write = $1, kick_account = $2, add_account = $3, change_role = $4, change_conversation_detail = $5, delete_message = $6
+		where id = $7
+	`
+
+	p := role.Permissions
+	_, err = tx.Exec(query, p.Write, p.KickAccount, p.AddAccount, p.ChangeRole, p.ChangeConversationDetail, p.DeleteMessage, permissionsID)
+	if err != nil {
+		err = fmt.Errorf("failed to update role permissions: %s", err)
+		return
+	}
+
+	err = checkRoleManagers(tx, role.ConversationID)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit role update: %s", err)
+	}
+	return
+}
+
+func (c ConversationRepository) DeleteRole(conversationID, roleID int) (err error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin role deletion: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	err = lockConversation(tx, conversationID)
+	if err != nil {
+		return
+	}
+
+	query := `
+		select exists (select 1 from convesation_members where role_id = $1 and left_at is null)
+	`
+
+	var inUse bool
+	err = tx.QueryRow(query, roleID).Scan(&inUse)
+	if err != nil {
+		err = fmt.Errorf("failed to check role members: %s", err)
+		return
+	}
+	if inUse {
+		err = sErrors.NewClientError(http.StatusConflict, "role in use: role %d is assigned to current members", roleID)
+		return
+	}
+
+	// The memberships history can't reference the deleted role, so its former members are moved to the default member role.
+	query = `
+		update convesation_members set role_id = (
+			select id from conversation_role where name = $2 and conversation_id is null
+		)
+		where role_id = $1
+	`
+
+	_, err = tx.Exec(query, roleID, conversation.MemberRole)
+	if err != nil {
+		err = fmt.Errorf("failed to unlink role members: %s", err)
+		return
+	}
+
+	query = `
+		delete from conversation_role where id = $1 and conversation_id = $2
+		returning permissions_id
+	`
+
+	var permissionsID int
+	err = tx.QueryRow(query, roleID, conversationID).Scan(&permissionsID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = conversation.NewRoleNotFoundError(roleID)
+			return
+		}
+		err = fmt.Errorf("failed to delete role: %s", err)
+		return
+	}
+
+	_, err = tx.Exec(`delete from conversation_role_permissions where id = $1`, permissionsID)
+	if err != nil {
+		err = fmt.Errorf("failed to delete role permissions: %s", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit role deletion: %s", err)
+	}
+	return
+}
+
+func (c ConversationRepository) SetMemberRole(conversationID, accountID, roleID int) (err error) {
+	tx, err := c.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin member role change: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	err = lockConversation(tx, conversationID)
+	if err != nil {
+		return
+	}
+
+	query := `
+		update convesation_members set role_id = $1
+		where conversation_id = $2 and account_id = $3 and left_at is null
+	`
+
+	res, err := tx.Exec(query, roleID, conversationID, accountID)
+	if err != nil {
+		err = fmt.Errorf("failed to set member role: %s", err)
+		return
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("failed to set member role: %s", err)
+		return
+	}
+	if n == 0 {
+		err = sErrors.NewClientError(http.StatusNotFound, "not found: account %d is not a member of conversation %d", accountID, conversationID)
+		return
+	}
+
+	err = checkRoleManagers(tx, conversationID)
+	if err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit member role change: %s", err)
+	}
+	return
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// prefixScanner scans the first column of a row into the prefix destination, and the rest into the provided ones.
+type prefixScanner struct {
+	row    rowScanner
+	prefix interface{}
+}
+
+func (p prefixScanner) Scan(dest ...interface{}) error {
+	return p.row.Scan(append([]interface{}{p.prefix}, dest...)...)
+}
+
+func scanRole(row rowScanner, role *conversation.Role) error {
+	return row.Scan(
+		&role.ID,
+		&role.ConversationID,
+		&role.Name,
+		&role.Description,
+		&role.Permissions.Write,
+		&role.Permissions.KickAccount,
+		&role.Permissions.AddAccount,
+		&role.Permissions.ChangeRole,
+		&role.Permissions.ChangeConversationDetail,
+		&role.Permissions.DeleteMessage,
+	)
+}
+
+func asRoleAlreadyExists(err error, msg string) error {
+	if pqErr, ok := newPQError(err); ok {
+		if match, aErr := pqErr.asAlreadyExists(); match {
+			return aErr
+		}
+	}
+	return fmt.Errorf("%s: %s", msg, err)
+}
+
+// lockConversation locks the row of a not deleted conversation until the end of the transaction,
+// serializing the changes of its members and roles.
+func lockConversation(tx *sql.Tx, conversationID int) (err error) {
+	query := `
+		select id from conversation where id = $1 and deleted_at is null for update
+	`
+
+	err = tx.QueryRow(query, conversationID).Scan(&conversationID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = conversation.NewNotFoundError(conversationID)
+			return
+		}
+		err = fmt.Errorf("failed to lock conversation: %s", err)
+	}
+	return
+}
+
+// checkRoleManagers checks the conversation keeps at least a current member with the change_role permission,
+// otherwise nobody could manage its roles anymore. A conversation without members passes the check.
+func checkRoleManagers(tx *sql.Tx, conversationID int) (err error) {
+	query := `
+		select not exists (
+			select 1 from convesation_members where conversation_id = $1 and left_at is null
+		) or exists (
+			select 1 from convesation_members m
+			inner join conversation_role r on r.id = m.role_id
+			inner join conversation_role_permissions p on p.id = r.permissions_id
+			where m.conversation_id = $1 and m.left_at is null and p.change_role
+		)
+	`
+
+	var ok bool
+	err = tx.QueryRow(query, conversationID).Scan(&ok)
+	if err != nil {
+		err = fmt.Errorf("failed to check conversation role managers: %s", err)
+		return
+	}
+	if !ok {
+		err = sErrors.NewClientError(http.StatusConflict, "last role manager: conversation %d must keep a member with the %s permission", conversationID, conversation.ChangeRolePermission)
+	}
+	return
+}
+
+func getActiveMemberIDs(tx *sql.Tx, conversationID int) (ids map[int]bool, err error) {
+	query := `
+		select account_id from convesation_members where conversation_id = $1 and left_at is null
+	`
+
+	rows, err := tx.Query(query, conversationID)
+	if err != nil {
+		err = fmt.Errorf("failed to get conversation members: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	ids = make(map[int]bool)
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			err = fmt.Errorf("failed to scan conversation member: %s", err)
+			return
+		}
+		ids[id] = true
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to get conversation members: %s", err)
+	}
+	return
+}
+
+// insertMembers adds the accounts to the conversation with the role provided.
+// The messages sent before joining are not visible, so the join position and the read cursor start at the last message.
+// Fails with a not found error if one of the accounts doesn't exists or is deleted.
+func insertMembers(tx *sql.Tx, conversationID int, accountIDs []int, role string) (err error) {
+	query := `
+		insert into convesation_members (account_id, conversation_id, joined_at, role_id, joined_seq, last_read_seq)
+		select a.id, c.id, now(), r.id, c.last_message_seq, c.last_message_seq from account a, conversation_role r, conversation c
+		where a.id = $1 and a.deleted_at is null and r.name = $3 and r.conversation_id is null and c.id = $2
+	`
+
+	for _, id := range accountIDs {
+		var res sql.Result
+		res, err = tx.Exec(query, id, conversationID, role)
+		if err != nil {
+			err = fmt.Errorf("failed to add conversation member: %s", err)
+			return
+		}
+
+		var n int64
+		n, err = res.RowsAffected()
+		if err != nil {
+			err = fmt.Errorf("failed to add conversation member: %s", err)
+			return
+		}
+		if n == 0 {
+			err = sErrors.NewClientError(http.StatusNotFound, "not found: account %d not found", id)
+			return
+		}
+	}
+	return
+}
+
+func checkConversationAffected(res sql.Result, id int) (err error) {
+	n, err := res.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("failed to check conversation changes: %s", err)
+		return
+	}
+	if n == 0 {
+		err = conversation.NewNotFoundError(id)
+	}
+	return
+}
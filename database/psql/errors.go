@@ -1,14 +1,16 @@
 package psql
 
 import (
-	"fmt"
+	"errors"
+	"net/http"
 	"strings"
 
+	sErrors "github.com/coffemanfp/chat/errors"
 	"github.com/lib/pq"
 )
 
 const (
-	foreign_key_violation = "23505"
+	unique_violation = "23505"
 )
 
 type pqErrHandler struct {
@@ -16,21 +18,30 @@ type pqErrHandler struct {
 }
 
 func (p pqErrHandler) asAlreadyExists() (match bool, err error) {
-	match = p.pqErr.Code == foreign_key_violation
+	match = p.pqErr.Code == unique_violation
 	if match {
-		err = fmt.Errorf("already exists %s", getFieldFromDetail(p.pqErr))
+		err = sErrors.NewClientError(http.StatusConflict, "already exists: %s already registered", getFieldFromDetail(p.pqErr))
 	} else {
 		err = p.pqErr
 	}
 	return
 }
 
+// violates checks if the error was raised by the constraint or unique index provided.
+func (p pqErrHandler) violates(constraint string) bool {
+	return p.pqErr.Constraint == constraint
+}
+
 func getFieldFromDetail(pqErr *pq.Error) string {
 	return pqErr.Detail[strings.Index(pqErr.Detail, "(")+1 : strings.Index(pqErr.Detail, ")")]
 }
 
-func newPQError(pqErr error) pqErrHandler {
-	return pqErrHandler{
-		pqErr: pqErr.(*pq.Error),
-	}
+// newPQError wraps the error provided in a pqErrHandler.
+//
+//	@param err error: error returned by the database driver.
+//	@return p pqErrHandler: handler for the *pq.Error found.
+//	@return ok bool: false if the error is not a *pq.Error.
+func newPQError(err error) (p pqErrHandler, ok bool) {
+	ok = errors.As(err, &p.pqErr)
+	return
 }
@@ -0,0 +1,423 @@
+package psql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/coffemanfp/chat/database"
+	"github.com/coffemanfp/chat/message"
+)
+
+// MessageRepository is the implementation of a message repository for the PostgreSQL database.
+type MessageRepository struct {
+	db *sql.DB
+}
+
+// NewMessageRepository initializes a new message repository instance.
+//
+//	@param conn *PostgreSQLConnector: is the PostgreSQLConnector handler.
+//	@return repo database.MessageRepository: is the final interface to keep
+//	 the MessageRepository implementation.
+//	@return err error: database connection error.
+func NewMessageRepository(conn *PostgreSQLConnector) (repo database.MessageRepository, err error) {
+	db, err := conn.getConn()
+	if err != nil {
+		return
+	}
+	repo = MessageRepository{
+		db: db,
+	}
+	return
+}
+
+func (m MessageRepository) SaveMessage(messageR message.Message) (msg message.Message, err error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin message saving: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	msg = messageR
+
+	// The conversation row lock gives the messages seqs without gaps in the commit order.
+	query := `
+		update conversation set last_message_seq = last_message_seq + 1
+		where id = $1
+		returning last_message_seq
+	`
+
+	err = tx.QueryRow(query, msg.ConversationID).Scan(&msg.Seq)
+	if err != nil {
+		err = fmt.Errorf("failed to get message seq: %s", err)
+		return
+	}
+
+	query = `
+		insert into message (conversation_id, account_id, seq, content, attachment_id, created_at)
+		values ($1, $2, $3, $4, nullif($5, 0), $6)
+		returning id
+	`
+
+	err = tx.QueryRow(query, msg.ConversationID, msg.AccountID, msg.Seq, msg.Content, msg.AttachmentID, msg.CreatedAt).Scan(&msg.ID)
+	if err != nil {
+		err = fmt.Errorf("failed to save message: %s", err)
+		return
+	}
+
+	query = `
+		update convesation_members set last_read_seq = $3, last_read_at = $4, unread_count = 0
+		where conversation_id = $1 and account_id = $2 and left_at is null
+	`
+
+	_, err = tx.Exec(query, msg.ConversationID, msg.AccountID, msg.Seq, msg.CreatedAt)
+	if err != nil {
+		err = fmt.Errorf("failed to mark message as read: %s", err)
+		return
+	}
+
+	// The members who blocked the author don't get the message as unread.
+	query = `
+		update convesation_members m set unread_count = m.unread_count + 1
+		where m.conversation_id = $1 and m.account_id != $2 and m.left_at is null
+		and not exists (
+			select 1 from blocked_account b where b.from_account_id = m.account_id and b.to_account_id = $2
+		)
+	`
+
+	_, err = tx.Exec(query, msg.ConversationID, msg.AccountID)
+	if err != nil {
+		err = fmt.Errorf("failed to count unread message: %s", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit message saving: %s", err)
+	}
+	return
+}
+
+// messageColumns are the selected columns of a message, in the scanMessage order.
+const messageColumns = `
+	msg.id, msg.conversation_id, msg.account_id, msg.seq, msg.content, coalesce(msg.attachment_id, 0),
+	msg.created_at, msg.edited_at, msg.deleted_at is not null
+`
+
+func (m MessageRepository) GetHistory(conversationID, accountID int, before *message.Cursor, limit int) (messages []message.Message, err error) {
+	args := []interface{}{conversationID, accountID, limit}
+	cursor := ""
+	if before != nil {
+		args = append(args, before.CreatedAt, before.ID)
+		cursor = `and (msg.created_at, msg.id) < ($4, $5)`
+	}
+
+	// The messages of the accounts blocked by the member are hidden.
+	query := `
+		select ` + messageColumns + ` from message msg
+		inner join convesation_members m on m.conversation_id = msg.conversation_id
+		where msg.conversation_id = $1 and m.account_id = $2 and m.left_at is null
+		and msg.seq > m.joined_seq ` + cursor + `
+		and not exists (
+			select 1 from blocked_account b where b.from_account_id = m.account_id and b.to_account_id = msg.account_id
+		)
+		order by msg.created_at desc, msg.id desc
+		limit $3
+	`
+
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		err = fmt.Errorf("failed to get messages: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	messages = []message.Message{}
+	for rows.Next() {
+		var msg message.Message
+		err = scanMessage(rows, &msg)
+		if err != nil {
+			err = fmt.Errorf("failed to scan message: %s", err)
+			return
+		}
+		messages = append(messages, msg)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to get messages: %s", err)
+	}
+	return
+}
+
+func (m MessageRepository) GetMessage(conversationID, accountID, id int) (msg message.Message, err error) {
+	// The messages hidden in the history of the member are not found.
+	query := `
+		select ` + messageColumns + ` from message msg
+		inner join convesation_members m on m.conversation_id = msg.conversation_id
+		where msg.conversation_id = $1 and m.account_id = $2 and m.left_at is null and msg.id = $3
+		and msg.seq > m.joined_seq
+		and not exists (
+			select 1 from blocked_account b where b.from_account_id = m.account_id and b.to_account_id = msg.account_id
+		)
+	`
+
+	err = scanMessage(m.db.QueryRow(query, conversationID, accountID, id), &msg)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = message.NewNotFoundError(id)
+			return
+		}
+		err = fmt.Errorf("failed to get message: %s", err)
+	}
+	return
+}
+
+func (m MessageRepository) EditMessage(id int, content string, editedAt time.Time) (msg message.Message, err error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin message edition: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	// The message row is locked to keep the revisions in the editions order.
+	query := `
+		select ` + messageColumns + ` from message msg where msg.id = $1 for update
+	`
+
+	err = scanMessage(tx.QueryRow(query, id), &msg)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = message.NewNotFoundError(id)
+			return
+		}
+		err = fmt.Errorf("failed to get message: %s", err)
+		return
+	}
+	if msg.Deleted {
+		err = message.NewDeletedError(id)
+		return
+	}
+
+	_, err = tx.Exec(`insert into message_revision (message_id, content, created_at) values ($1, $2, $3)`, id, msg.Content, editedAt)
+	if err != nil {
+		err = fmt.Errorf("failed to save message revision: %s", err)
+		return
+	}
+
+	_, err = tx.Exec(`update message set content = $1, edited_at = $2 where id = $3`, content, editedAt, id)
+	if err != nil {
+		err = fmt.Errorf("failed to edit message: %s", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit message edition: %s", err)
+		return
+	}
+
+	msg.Content = content
+	msg.EditedAt = &editedAt
+	return
+}
+
+func (m MessageRepository) DeleteMessage(id, deletedBy int) (msg message.Message, err error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin message deletion: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	query := `
+		update message msg set content = '', attachment_id = null, deleted_at = now(), deleted_by = $2
+		where msg.id = $1 and msg.deleted_at is null
+		returning ` + messageColumns
+
+	err = scanMessage(tx.QueryRow(query, id, deletedBy), &msg)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = message.NewDeletedError(id)
+			return
+		}
+		err = fmt.Errorf("failed to delete message: %s", err)
+		return
+	}
+
+	_, err = tx.Exec(`delete from message_revision where message_id = $1`, id)
+	if err != nil {
+		err = fmt.Errorf("failed to delete message revisions: %s", err)
+		return
+	}
+
+	// The tombstone is not unread anymore for the members who had it counted.
+	query = `
+		update convesation_members m set unread_count = greatest(m.unread_count - 1, 0)
+		where m.conversation_id = $1 and m.account_id != $2 and m.left_at is null and m.last_read_seq < $3
+		and not exists (
+			select 1 from blocked_account b where b.from_account_id = m.account_id and b.to_account_id = $2
+		)
+	`
+
+	_, err = tx.Exec(query, msg.ConversationID, msg.AccountID, msg.Seq)
+	if err != nil {
+		err = fmt.Errorf("failed to discount deleted message: %s", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit message deletion: %s", err)
+	}
+	return
+}
+
+func (m MessageRepository) GetRevisions(id int) (revisions []message.Revision, err error) {
+	query := `
+		select content, created_at from message_revision where message_id = $1 order by created_at, id
+	`
+
+	rows, err := m.db.Query(query, id)
+	if err != nil {
+		err = fmt.Errorf("failed to get message revisions: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	revisions = []message.Revision{}
+	for rows.Next() {
+		var revision message.Revision
+		err = rows.Scan(&revision.Content, &revision.CreatedAt)
+		if err != nil {
+			err = fmt.Errorf("failed to scan message revision: %s", err)
+			return
+		}
+		revisions = append(revisions, revision)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to get message revisions: %s", err)
+	}
+	return
+}
+
+func (m MessageRepository) MarkRead(conversationID, accountID, messageID int) (moved bool, err error) {
+	var seq int64
+	err = m.db.QueryRow(`select seq from message where conversation_id = $1 and id = $2`, conversationID, messageID).Scan(&seq)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = message.NewNotFoundError(messageID)
+			return
+		}
+		err = fmt.Errorf("failed to get message seq: %s", err)
+		return
+	}
+
+	// The messages read are discounted from the unread counter with the same rules they were counted.
+	query := `
+		update convesation_members m set last_read_seq = $3, last_read_at = now(), unread_count = greatest(m.unread_count - (
+			select count(*) from message msg
+			where msg.conversation_id = m.conversation_id and msg.seq > m.last_read_seq and msg.seq <= $3
+			and msg.deleted_at is null and msg.account_id != m.account_id
+			and not exists (
+				select 1 from blocked_account b where b.from_account_id = m.account_id and b.to_account_id = msg.account_id
+			)
+		), 0)
+		where m.conversation_id = $1 and m.account_id = $2 and m.left_at is null and m.last_read_seq < $3
+	`
+
+	res, err := m.db.Exec(query, conversationID, accountID, seq)
+	if err != nil {
+		err = fmt.Errorf("failed to mark messages as read: %s", err)
+		return
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("failed to mark messages as read: %s", err)
+		return
+	}
+	moved = n != 0
+	return
+}
+
+func (m MessageRepository) GetReceipts(conversationID, messageID int) (receipts []message.Receipt, err error) {
+	query := `
+		select m.account_id, m.last_read_at from convesation_members m
+		inner join message msg on msg.conversation_id = m.conversation_id
+		where msg.conversation_id = $1 and msg.id = $2 and m.left_at is null
+		and m.account_id != msg.account_id and m.last_read_seq >= msg.seq and m.last_read_at is not null
+		order by m.last_read_at, m.account_id
+	`
+
+	rows, err := m.db.Query(query, conversationID, messageID)
+	if err != nil {
+		err = fmt.Errorf("failed to get read receipts: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	receipts = []message.Receipt{}
+	for rows.Next() {
+		var receipt message.Receipt
+		err = rows.Scan(&receipt.AccountID, &receipt.ReadAt)
+		if err != nil {
+			err = fmt.Errorf("failed to scan read receipt: %s", err)
+			return
+		}
+		receipts = append(receipts, receipt)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to get read receipts: %s", err)
+	}
+	return
+}
+
+func scanMessage(row rowScanner, msg *message.Message) (err error) {
+	var editedAt sql.NullTime
+	err = row.Scan(&msg.ID, &msg.ConversationID, &msg.AccountID, &msg.Seq, &msg.Content, &msg.AttachmentID, &msg.CreatedAt, &editedAt, &msg.Deleted)
+	if err == nil && editedAt.Valid {
+		msg.EditedAt = &editedAt.Time
+	}
+	return
+}
+
+func (m MessageRepository) GetAccountMessages(accountID, afterID, limit int) (messages []message.Message, err error) {
+	query := `
+		select ` + messageColumns + ` from message msg
+		where msg.account_id = $1 and msg.id > $2 and msg.deleted_at is null
+		order by msg.id
+		limit $3
+	`
+
+	rows, err := m.db.Query(query, accountID, afterID, limit)
+	if err != nil {
+		err = fmt.Errorf("failed to get account messages: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	messages = []message.Message{}
+	for rows.Next() {
+		var msg message.Message
+		err = scanMessage(rows, &msg)
+		if err != nil {
+			err = fmt.Errorf("failed to scan message: %s", err)
+			return
+		}
+		messages = append(messages, msg)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to get account messages: %s", err)
+	}
+	return
+}
@@ -0,0 +1,120 @@
+package psql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/coffemanfp/chat/account"
+	"github.com/coffemanfp/chat/database"
+	sErrors "github.com/coffemanfp/chat/errors"
+	"github.com/lib/pq"
+)
+
+// PresenceRepository is the implementation of a presence repository for the PostgreSQL database.
+type PresenceRepository struct {
+	db *sql.DB
+}
+
+// NewPresenceRepository initializes a new presence repository instance.
+//
+//	@param conn *PostgreSQLConnector: is the PostgreSQLConnector handler.
+//	@return repo database.PresenceRepository: is the final interface to keep
+//	 the PresenceRepository implementation.
+//	@return err error: database connection error.
+func NewPresenceRepository(conn *PostgreSQLConnector) (repo database.PresenceRepository, err error) {
+	db, err := conn.getConn()
+	if err != nil {
+		return
+	}
+	repo = PresenceRepository{
+		db: db,
+	}
+	return
+}
+
+func (p PresenceRepository) GetLastSeen(viewerID int, accountIDs []int) (lastSeen map[int]time.Time, err error) {
+	lastSeen = make(map[int]time.Time)
+	if len(accountIDs) == 0 {
+		return
+	}
+
+	query := `
+		select a.id, max(s.last_seen_at) from account a
+		inner join account_session s on s.account_id = a.id
+		where a.id = any($2) and a.deleted_at is null and (
+			a.id = $1 or a.last_seen_visibility = 'everyone' or (
+				a.last_seen_visibility = 'contacts' and exists (
+					select 1 from contact c where c.from_account_id = a.id and c.to_account_id = $1
+				)
+			)
+		)
+		group by a.id
+	`
+
+	rows, err := p.db.Query(query, viewerID, pq.Array(accountIDs))
+	if err != nil {
+		err = fmt.Errorf("failed to get last seen: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id   int
+			seen time.Time
+		)
+		err = rows.Scan(&id, &seen)
+		if err != nil {
+			err = fmt.Errorf("failed to scan last seen: %s", err)
+			return
+		}
+		lastSeen[id] = seen
+	}
+
+	err = rows.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to get last seen: %s", err)
+	}
+	return
+}
+
+func (p PresenceRepository) GetLastSeenVisibility(accountID int) (visibility account.LastSeenVisibility, err error) {
+	query := `
+		select last_seen_visibility from account where id = $1 and deleted_at is null
+	`
+
+	err = p.db.QueryRow(query, accountID).Scan(&visibility)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = sErrors.NewClientError(http.StatusNotFound, "not found: account %d not found", accountID)
+			return
+		}
+		err = fmt.Errorf("failed to get last seen visibility: %s", err)
+	}
+	return
+}
+
+func (p PresenceRepository) SetLastSeenVisibility(accountID int, visibility account.LastSeenVisibility) (err error) {
+	query := `
+		update account set last_seen_visibility = $2 where id = $1 and deleted_at is null
+	`
+
+	res, err := p.db.Exec(query, accountID, visibility)
+	if err != nil {
+		err = fmt.Errorf("failed to set last seen visibility: %s", err)
+		return
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("failed to set last seen visibility: %s", err)
+		return
+	}
+	if n == 0 {
+		err = sErrors.NewClientError(http.StatusNotFound, "not found: account %d not found", accountID)
+	}
+	return
+}
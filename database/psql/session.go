@@ -0,0 +1,207 @@
+package psql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/coffemanfp/chat/auth"
+	"github.com/coffemanfp/chat/database"
+)
+
+// SessionRepository is the implementation of a session repository for the PostgreSQL database.
+type SessionRepository struct {
+	db *sql.DB
+}
+
+// NewSessionRepository initializes a new session repository instance.
+//
+//	@param conn *PostgreSQLConnector: is the PostgreSQLConnector handler.
+//	@return repo database.SessionRepository: is the final interface to keep
+//	 the SessionRepository implementation.
+//	@return err error: database connection error.
+func NewSessionRepository(conn *PostgreSQLConnector) (repo database.SessionRepository, err error) {
+	db, err := conn.getConn()
+	if err != nil {
+		return
+	}
+	repo = SessionRepository{
+		db: db,
+	}
+	return
+}
+
+func (s SessionRepository) SaveSession(session auth.Session) (err error) {
+	query := `
+		insert into account_session (id, tmp_id, tmp_id_expires_at, account_id, logged_at, last_seen_at, logged_with, actived)
+		values ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	// The TmpID is stored by its hash, like the other tokens sent to the clients.
+	_, err = s.db.Exec(query, session.ID, auth.HashToken(session.TmpID), session.TmpIDExpiresAt, session.AccountID, session.LoggedAt, session.LastSeenAt, session.LoggedWith, session.Actived)
+	if err != nil {
+		err = fmt.Errorf("failed to save session: %s", err)
+	}
+	return
+}
+
+func (s SessionRepository) ExchangeTmpID(tmpID string) (session auth.Session, err error) {
+	query := `
+		update account_session set tmp_id = null, tmp_id_expires_at = null, last_seen_at = now()
+		where tmp_id = $1 and tmp_id_expires_at > now() and actived
+		returning id, account_id, logged_at, last_seen_at, logged_with, actived
+	`
+
+	var loggedWith sql.NullString
+	err = s.db.QueryRow(query, auth.HashToken(tmpID)).Scan(&session.ID, &session.AccountID, &session.LoggedAt, &session.LastSeenAt, &loggedWith, &session.Actived)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+			return
+		}
+		err = fmt.Errorf("failed to exchange session tmp id: %s", err)
+		return
+	}
+	session.LoggedWith = loggedWith.String
+	return
+}
+
+func (s SessionRepository) UpdateLastSeen(id string) (actived bool, err error) {
+	query := `
+		update account_session set last_seen_at = now() where id = $1 and actived
+	`
+
+	res, err := s.db.Exec(query, id)
+	if err != nil {
+		err = fmt.Errorf("failed to update session last seen: %s", err)
+		return
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("failed to update session last seen: %s", err)
+		return
+	}
+	actived = n > 0
+	return
+}
+
+func (s SessionRepository) DeactivateSession(id string) (err error) {
+	query := `
+		update account_session set actived = false where id = $1
+	`
+
+	_, err = s.db.Exec(query, id)
+	if err != nil {
+		err = fmt.Errorf("failed to deactivate session: %s", err)
+	}
+	return
+}
+
+func (s SessionRepository) SaveRefreshToken(sessionID, tokenHash string, expiresAt time.Time) (err error) {
+	query := `
+		insert into refresh_token (id, session_id, created_at, expires_at)
+		values ($1, $2, now(), $3)
+	`
+
+	_, err = s.db.Exec(query, tokenHash, sessionID, expiresAt)
+	if err != nil {
+		err = fmt.Errorf("failed to save refresh token: %s", err)
+	}
+	return
+}
+
+func (s SessionRepository) RotateRefreshToken(tokenHash, newTokenHash string, expiresAt time.Time) (session auth.Session, reused bool, err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin refresh token rotation: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	query := `
+		select s.id, s.account_id, s.logged_at, s.last_seen_at, s.logged_with, s.actived, r.used_at, r.expires_at
+		from refresh_token r
+		inner join account_session s on s.id = r.session_id
+		where r.id = $1
+		for update of r, s
+	`
+
+	var (
+		found      auth.Session
+		loggedWith sql.NullString
+		usedAt     sql.NullTime
+		expiresAtR time.Time
+	)
+	err = tx.QueryRow(query, tokenHash).Scan(&found.ID, &found.AccountID, &found.LoggedAt, &found.LastSeenAt, &loggedWith, &found.Actived, &usedAt, &expiresAtR)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+			return
+		}
+		err = fmt.Errorf("failed to get refresh token: %s", err)
+		return
+	}
+	found.LoggedWith = loggedWith.String
+
+	if usedAt.Valid {
+		reused = true
+		err = revokeSessionFamily(tx, found.ID)
+		if err != nil {
+			return
+		}
+		err = tx.Commit()
+		if err != nil {
+			err = fmt.Errorf("failed to commit refresh token reuse: %s", err)
+			return
+		}
+
+		// The revoked session is returned, so the caller knows its account.
+		found.Actived = false
+		session = found
+		return
+	}
+
+	if !found.Actived || time.Now().After(expiresAtR) {
+		return
+	}
+
+	_, err = tx.Exec(`update refresh_token set used_at = now() where id = $1`, tokenHash)
+	if err != nil {
+		err = fmt.Errorf("failed to use refresh token: %s", err)
+		return
+	}
+
+	_, err = tx.Exec(`
+		insert into refresh_token (id, session_id, parent_id, created_at, expires_at)
+		values ($1, $2, $3, now(), $4)
+	`, newTokenHash, found.ID, tokenHash, expiresAt)
+	if err != nil {
+		err = fmt.Errorf("failed to save refresh token: %s", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit refresh token rotation: %s", err)
+		return
+	}
+	session = found
+	return
+}
+
+// revokeSessionFamily deactivates the session and invalidates all its refresh tokens.
+func revokeSessionFamily(tx *sql.Tx, sessionID string) (err error) {
+	_, err = tx.Exec(`update account_session set actived = false where id = $1`, sessionID)
+	if err != nil {
+		err = fmt.Errorf("failed to revoke session: %s", err)
+		return
+	}
+
+	_, err = tx.Exec(`update refresh_token set used_at = now() where session_id = $1 and used_at is null`, sessionID)
+	if err != nil {
+		err = fmt.Errorf("failed to revoke session refresh tokens: %s", err)
+	}
+	return
+}
@@ -2,25 +2,266 @@ package psql
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/coffemanfp/chat/account"
+	"github.com/coffemanfp/chat/database"
 )
 
+// accountColumns are the columns scanned by scanAccount.
+const accountColumns = `
+	a.id, coalesce(a.nickname, ''), a.name, coalesce(a.last_name, ''), coalesce(a.email, ''),
+	coalesce(a.picture_url, ''), a.created_at, a.updated_at, a.email_verified_at is not null
+`
+
 // AccountRepository is the implementation of a account repository for the PostgreSQL database.
 type AccountRepository struct {
 	db *sql.DB
 }
 
-// NewAuthRepository initializes a new AccountRepository instance.
+// NewAccountRepository initializes a new account repository instance.
 //
 //	@param conn *PostgreSQLConnector: is the PostgreSQLConnector handler.
-//	@return accountRepo AccountRepository: new AccountRepository instance.
+//	@return repo database.AccountRepository: is the final interface to keep
+//	 the AccountRepository implementation.
 //	@return err error: database connection error.
-func NewAccountRepository(conn *PostgreSQLConnector) (accountRepo AccountRepository, err error) {
+func NewAccountRepository(conn *PostgreSQLConnector) (repo database.AccountRepository, err error) {
 	db, err := conn.getConn()
 	if err != nil {
 		return
 	}
-	accountRepo = AccountRepository{
+	repo = AccountRepository{
 		db: db,
 	}
 	return
 }
+
+func (a AccountRepository) SetPicture(id int, pictureURL string) (err error) {
+	query := `
+		update account set picture_url = nullif($2, ''), updated_at = now()
+		where id = $1 and deleted_at is null
+	`
+
+	res, err := a.db.Exec(query, id, pictureURL)
+	if err != nil {
+		err = fmt.Errorf("failed to set account picture: %s", err)
+		return
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("failed to set account picture: %s", err)
+		return
+	}
+	if n == 0 {
+		err = account.NewNotFoundError(id)
+	}
+	return
+}
+
+func (a AccountRepository) GetAccount(id int) (acc account.Account, err error) {
+	query := `select ` + accountColumns + ` from account a where a.id = $1 and a.deleted_at is null`
+
+	acc, err = scanAccount(a.db.QueryRow(query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = account.NewNotFoundError(id)
+			return
+		}
+		err = fmt.Errorf("failed to get account: %s", err)
+	}
+	return
+}
+
+func (a AccountRepository) GetAccountByNickname(nickname string) (acc account.Account, err error) {
+	query := `select ` + accountColumns + ` from account a where a.nickname = $1 and a.deleted_at is null`
+
+	acc, err = scanAccount(a.db.QueryRow(query, nickname))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = account.NewNicknameNotFoundError(nickname)
+			return
+		}
+		err = fmt.Errorf("failed to get account by nickname: %s", err)
+	}
+	return
+}
+
+func (a AccountRepository) UpdateProfile(accountR account.Account) (acc account.Account, err error) {
+	query := `
+		update account a set nickname = $2, name = $3, last_name = nullif($4, ''), updated_at = now()
+		where a.id = $1 and a.deleted_at is null
+		returning ` + accountColumns
+
+	acc, err = scanAccount(a.db.QueryRow(query, accountR.ID, accountR.Nickname, accountR.Name, accountR.LastName))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = account.NewNotFoundError(accountR.ID)
+			return
+		}
+		if pqErr, ok := newPQError(err); ok {
+			if match, aErr := pqErr.asAlreadyExists(); match {
+				err = aErr
+				return
+			}
+		}
+		err = fmt.Errorf("failed to update account profile: %s", err)
+	}
+	return
+}
+
+func (a AccountRepository) GetPassword(id int) (hash string, err error) {
+	query := `select coalesce(password, '') from account where id = $1 and deleted_at is null`
+
+	err = a.db.QueryRow(query, id).Scan(&hash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = account.NewNotFoundError(id)
+			return
+		}
+		err = fmt.Errorf("failed to get account password: %s", err)
+	}
+	return
+}
+
+func (a AccountRepository) DeleteAccount(id int) (deletedAt time.Time, err error) {
+	tx, err := a.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin account deletion: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	query := `
+		update account set deleted_at = now() where id = $1 and deleted_at is null
+		returning deleted_at
+	`
+
+	err = tx.QueryRow(query, id).Scan(&deletedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = account.NewNotFoundError(id)
+			return
+		}
+		err = fmt.Errorf("failed to delete account: %s", err)
+		return
+	}
+
+	_, err = tx.Exec(`update account_session set actived = false where account_id = $1 and actived`, id)
+	if err != nil {
+		err = fmt.Errorf("failed to close account sessions: %s", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit account deletion: %s", err)
+	}
+	return
+}
+
+func (a AccountRepository) PurgeAccounts(deletedBefore time.Time, limit int) (n int, err error) {
+	query := `
+		select id from account
+		where deleted_at < $1 and anonymized_at is null
+		order by deleted_at limit $2
+	`
+
+	rows, err := a.db.Query(query, deletedBefore, limit)
+	if err != nil {
+		err = fmt.Errorf("failed to get deleted accounts: %s", err)
+		return
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			err = fmt.Errorf("failed to scan deleted account: %s", err)
+			return
+		}
+		ids = append(ids, id)
+	}
+	err = rows.Err()
+	if err != nil {
+		err = fmt.Errorf("failed to get deleted accounts: %s", err)
+		return
+	}
+
+	for _, id := range ids {
+		err = a.purgeAccount(id, deletedBefore)
+		if err != nil {
+			return
+		}
+		n++
+	}
+	return
+}
+
+// purgeAccount anonymizes a deleted account and removes its personal data in a single transaction.
+// The account row is kept, so its messages keep their author.
+func (a AccountRepository) purgeAccount(id int, deletedBefore time.Time) (err error) {
+	tx, err := a.db.Begin()
+	if err != nil {
+		err = fmt.Errorf("failed to begin account purge: %s", err)
+		return
+	}
+	defer tx.Rollback()
+
+	// The account is checked again, it could be restored after it was selected.
+	query := `
+		update account set
+			nickname = null, email = null, password = null, name = $3, last_name = null,
+			picture_url = null, last_seen_visibility = 'nobody', anonymized_at = now(), updated_at = now()
+		where id = $1 and deleted_at < $2 and anonymized_at is null
+	`
+
+	res, err := tx.Exec(query, id, deletedBefore, account.AnonymizedName)
+	if err != nil {
+		err = fmt.Errorf("failed to anonymize account: %s", err)
+		return
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		err = fmt.Errorf("failed to anonymize account: %s", err)
+		return
+	}
+	if n == 0 {
+		return
+	}
+
+	queries := []string{
+		`delete from refresh_token where session_id in (select id from account_session where account_id = $1)`,
+		`delete from account_session where account_id = $1`,
+		`delete from account_identity where account_id = $1`,
+		`delete from contact where from_account_id = $1 or to_account_id = $1`,
+		`delete from blocked_account where from_account_id = $1 or to_account_id = $1`,
+	}
+	for _, q := range queries {
+		_, err = tx.Exec(q, id)
+		if err != nil {
+			err = fmt.Errorf("failed to remove account data: %s", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		err = fmt.Errorf("failed to commit account purge: %s", err)
+	}
+	return
+}
+
+// scanAccount scans a row of the accountColumns.
+func scanAccount(row rowScanner) (acc account.Account, err error) {
+	var updatedAt sql.NullTime
+	err = row.Scan(&acc.ID, &acc.Nickname, &acc.Name, &acc.LastName, &acc.Email, &acc.PictureURL, &acc.CreatedAt, &updatedAt, &acc.EmailVerified)
+	if updatedAt.Valid {
+		acc.UpdatedAt = &updatedAt.Time
+	}
+	return
+}
@@ -0,0 +1,72 @@
+package database
+
+import (
+	"time"
+
+	"github.com/coffemanfp/chat/auth"
+)
+
+// SESSION_REPOSITORY is the key to be used when creating the repositories hashmap.
+const SESSION_REPOSITORY RepositoryID = "SESSION"
+
+// GetSessionRepository gets the SessionRepository instance inside the repositories hashmap.
+//
+//	@param repoMap map[RepositoryID]interface{}: repositories hashmap.
+//	@return repo SessionRepository: found SessionRepository instance.
+//	@return err error: missing or invalid repository instance error.
+func GetSessionRepository(repoMap map[RepositoryID]interface{}) (repo SessionRepository, err error) {
+	repoI, err := GetRepository(repoMap, SESSION_REPOSITORY)
+	if err != nil {
+		return
+	}
+	repo, ok := repoI.(SessionRepository)
+	if !ok {
+		err = newInvalidRepositoryError(SESSION_REPOSITORY)
+	}
+	return
+}
+
+// SessionRepository defines the behaviors to be used by a SessionRepository implementation.
+type SessionRepository interface {
+
+	// SaveSession stores a new account session.
+	//	@param session auth.Session: session to store.
+	//	@return $1 error: database error.
+	SaveSession(session auth.Session) error
+
+	// ExchangeTmpID invalidates the one use TmpID of an active session.
+	//  The invalidation is atomic, so just one call gets the session for a given TmpID.
+	//	@param tmpID string: one use TmpID of the session.
+	//	@return $1 auth.Session: session of the TmpID. Is a empty session if it was not found, expired or already used.
+	//	@return $2 error: database error.
+	ExchangeTmpID(tmpID string) (auth.Session, error)
+
+	// UpdateLastSeen sets the last seen time of an active session to the current time.
+	//	@param id string: session id.
+	//	@return $1 bool: false if the session doesn't exists or is deactivated.
+	//	@return $2 error: database error.
+	UpdateLastSeen(id string) (bool, error)
+
+	// DeactivateSession deactivates the session, rejecting the forward calls made with it.
+	//	@param id string: session id.
+	//	@return $1 error: database error.
+	DeactivateSession(id string) error
+
+	// SaveRefreshToken stores a new refresh token of the session.
+	//	@param sessionID string: session id.
+	//	@param tokenHash string: hash of the refresh token.
+	//	@param expiresAt time.Time: expiration time of the refresh token.
+	//	@return $1 error: database error.
+	SaveRefreshToken(sessionID, tokenHash string, expiresAt time.Time) error
+
+	// RotateRefreshToken marks the refresh token as used and stores its replacement in the same session.
+	//  If the refresh token was already used, the whole session is deactivated as the token was reused.
+	//	@param tokenHash string: hash of the refresh token to use.
+	//	@param newTokenHash string: hash of the replacement refresh token.
+	//	@param expiresAt time.Time: expiration time of the replacement refresh token.
+	//	@return $1 auth.Session: session of the refresh token. Is a empty session if the token was not found,
+	//	 is expired or its session is deactivated. If the token was reused, is the revoked session.
+	//	@return $2 bool: true if the refresh token was reused.
+	//	@return $3 error: database error.
+	RotateRefreshToken(tokenHash, newTokenHash string, expiresAt time.Time) (auth.Session, bool, error)
+}
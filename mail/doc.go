@@ -0,0 +1,3 @@
+// Package mail defines the interfaces to be implemented for the different mail senders.
+
+package mail
@@ -0,0 +1,17 @@
+package mail
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer defines the behaviors to be used by a Mailer implementation.
+type Mailer interface {
+
+	// Send sends a email.
+	//	@param message Message: email to send.
+	//	@return $1 error: delivery error.
+	Send(message Message) error
+}
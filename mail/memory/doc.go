@@ -0,0 +1,3 @@
+// Package memory implements a mailer which keeps the sent emails in memory, for the tests and the development.
+
+package memory
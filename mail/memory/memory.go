@@ -0,0 +1,66 @@
+package memory
+
+import (
+	"log"
+	"sync"
+
+	"github.com/coffemanfp/chat/mail"
+)
+
+// Mailer is the implementation of a mailer which keeps the sent emails in memory.
+type Mailer struct {
+	mu       sync.Mutex
+	messages []mail.Message
+}
+
+// NewMailer initializes a new in-memory mailer.
+//
+//	@return m *Mailer: new Mailer instance.
+func NewMailer() (m *Mailer) {
+	return &Mailer{}
+}
+
+func (m *Mailer) Send(message mail.Message) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	log.Printf("Mail to %s: %s", message.To, message.Subject)
+	m.messages = append(m.messages, message)
+	return
+}
+
+// Messages gets a copy of the sent emails, oldest first.
+//
+//	@return messages []mail.Message: sent emails.
+func (m *Mailer) Messages() (messages []mail.Message) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]mail.Message(nil), m.messages...)
+}
+
+// Last gets the last email sent to the address provided.
+//
+//	@param to string: recipient address.
+//	@return message mail.Message: last sent email.
+//	@return ok bool: a email was sent to the address.
+func (m *Mailer) Last(to string) (message mail.Message, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == to {
+			message, ok = m.messages[i], true
+			return
+		}
+	}
+	return
+}
+
+// Reset removes the sent emails.
+func (m *Mailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = nil
+}
@@ -0,0 +1,3 @@
+// Package smtp implements the mailer which sends the emails to a SMTP server.
+
+package smtp
@@ -0,0 +1,76 @@
+package smtp
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coffemanfp/chat/mail"
+)
+
+// Properties keeps the properties of the SMTP server.
+type Properties struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+
+	// From is the sender address of the emails.
+	From string
+}
+
+// Mailer is the implementation of a mailer which sends the emails to a SMTP server.
+// The connection is upgraded with STARTTLS when the server supports it.
+type Mailer struct {
+	props Properties
+	auth  smtp.Auth
+}
+
+// NewMailer initializes a new SMTP mailer.
+//
+//	@param props Properties: properties of the SMTP server.
+//	@return m Mailer: new Mailer instance.
+//	@return err error: missing properties error.
+func NewMailer(props Properties) (m Mailer, err error) {
+	if props.Host == "" || props.Port == 0 || props.From == "" {
+		err = fmt.Errorf("failed to initialize smtp mailer: host, port and from are required")
+		return
+	}
+
+	m = Mailer{
+		props: props,
+	}
+	if props.Username != "" {
+		m.auth = smtp.PlainAuth("", props.Username, props.Password, props.Host)
+	}
+	return
+}
+
+func (m Mailer) Send(message mail.Message) (err error) {
+	if strings.ContainsAny(message.To, "\r\n") || strings.ContainsAny(message.Subject, "\r\n") {
+		err = fmt.Errorf("failed to send mail: invalid header value")
+		return
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", m.props.From)
+	fmt.Fprintf(&b, "To: %s\r\n", message.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", message.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	addr := net.JoinHostPort(m.props.Host, strconv.Itoa(m.props.Port))
+	err = smtp.SendMail(addr, m.auth, m.props.From, []string{message.To}, b.Bytes())
+	if err != nil {
+		err = fmt.Errorf("failed to send mail to %s: %s", message.To, err)
+	}
+	return
+}
@@ -49,8 +49,56 @@ func setUpDatabase(conf config.ConfigInfo) (db database.Database, err error) {
 		return
 	}
 
+	sessionRepo, err := psql.NewSessionRepository(db.Conn.(*psql.PostgreSQLConnector))
+	if err != nil {
+		return
+	}
+
+	messageRepo, err := psql.NewMessageRepository(db.Conn.(*psql.PostgreSQLConnector))
+	if err != nil {
+		return
+	}
+
+	conversationRepo, err := psql.NewConversationRepository(db.Conn.(*psql.PostgreSQLConnector))
+	if err != nil {
+		return
+	}
+
+	contactRepo, err := psql.NewContactRepository(db.Conn.(*psql.PostgreSQLConnector))
+	if err != nil {
+		return
+	}
+
+	blockRepo, err := psql.NewBlockRepository(db.Conn.(*psql.PostgreSQLConnector))
+	if err != nil {
+		return
+	}
+
+	presenceRepo, err := psql.NewPresenceRepository(db.Conn.(*psql.PostgreSQLConnector))
+	if err != nil {
+		return
+	}
+
+	attachmentRepo, err := psql.NewAttachmentRepository(db.Conn.(*psql.PostgreSQLConnector))
+	if err != nil {
+		return
+	}
+
+	accountRepo, err := psql.NewAccountRepository(db.Conn.(*psql.PostgreSQLConnector))
+	if err != nil {
+		return
+	}
+
 	db.Repositories = map[database.RepositoryID]interface{}{
-		database.AUTH_REPOSITORY: authRepo,
+		database.AUTH_REPOSITORY:         authRepo,
+		database.SESSION_REPOSITORY:      sessionRepo,
+		database.MESSAGE_REPOSITORY:      messageRepo,
+		database.CONVERSATION_REPOSITORY: conversationRepo,
+		database.CONTACT_REPOSITORY:      contactRepo,
+		database.BLOCK_REPOSITORY:        blockRepo,
+		database.PRESENCE_REPOSITORY:     presenceRepo,
+		database.ATTACHMENT_REPOSITORY:   attachmentRepo,
+		database.ACCOUNT_REPOSITORY:      accountRepo,
 	}
 	return
 }
@@ -0,0 +1,108 @@
+package message
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coffemanfp/chat/errors"
+)
+
+const (
+	// DefaultPageSize is the page size of the history requests without limit.
+	DefaultPageSize = 50
+
+	// MaxPageSize is the max page size of a history request.
+	MaxPageSize = 100
+)
+
+// Cursor is the position of a message in the history of a conversation,
+// ordered by its creation time and id.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+// CursorOf gets the cursor of the message.
+func CursorOf(message Message) Cursor {
+	return Cursor{
+		CreatedAt: message.CreatedAt,
+		ID:        message.ID,
+	}
+}
+
+// Encode encodes the cursor as a opaque URL safe string.
+func (c Cursor) Encode() string {
+	raw := fmt.Sprintf("%d:%d", c.CreatedAt.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor decodes a cursor encoded by Cursor.Encode.
+//
+//	@param s string: encoded cursor.
+//	@return cursor Cursor: decoded cursor.
+//	@return err error: invalid cursor ClientError.
+func DecodeCursor(s string) (cursor Cursor, err error) {
+	err = errors.NewClientError(http.StatusBadRequest, "invalid cursor: malformed cursor %s", s)
+
+	raw, dErr := base64.RawURLEncoding.DecodeString(s)
+	if dErr != nil {
+		return
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return
+	}
+	nanos, pErr := strconv.ParseInt(parts[0], 10, 64)
+	if pErr != nil {
+		return
+	}
+	id, pErr := strconv.Atoi(parts[1])
+	if pErr != nil {
+		return
+	}
+
+	cursor = Cursor{
+		CreatedAt: time.Unix(0, nanos),
+		ID:        id,
+	}
+	err = nil
+	return
+}
+
+// Page is a page of the history of a conversation, the latest messages first.
+type Page struct {
+	Messages []Message `json:"messages"`
+
+	// NextCursor is the cursor to request the previous messages. Is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// NewPage builds the page of the messages provided. The messages must be one more than the page size
+// if there are older messages, the extra message is dropped and its previous message used as next cursor.
+//
+//	@param messages []Message: messages of the page, the latest first.
+//	@param size int: page size.
+//	@return page Page: Page builded.
+func NewPage(messages []Message, size int) (page Page) {
+	page.Messages = messages
+	if len(messages) > size {
+		page.Messages = messages[:size]
+		page.NextCursor = CursorOf(page.Messages[size-1]).Encode()
+	}
+	return
+}
+
+// ValidatePageSize checks the page size of a history request.
+//
+//	@param size int: page size.
+//	 @return err error: page size out of range.
+func ValidatePageSize(size int) (err error) {
+	if size < 1 || size > MaxPageSize {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid limit: limit must be between 1 and %d", MaxPageSize)
+	}
+	return
+}
@@ -0,0 +1,113 @@
+package message
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	sErrors "github.com/coffemanfp/chat/errors"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		cursor Cursor
+	}{
+		{name: "now", cursor: Cursor{CreatedAt: time.Now(), ID: 42}},
+		{name: "nanoseconds", cursor: Cursor{CreatedAt: time.Date(2024, 2, 29, 23, 59, 59, 999999999, time.UTC), ID: 1}},
+		{name: "unix epoch", cursor: Cursor{CreatedAt: time.Unix(0, 0), ID: 7}},
+		{name: "before unix epoch", cursor: Cursor{CreatedAt: time.Date(1969, 7, 20, 20, 17, 0, 0, time.UTC), ID: 3}},
+		{name: "max id", cursor: Cursor{CreatedAt: time.Unix(1700000000, 0), ID: 1<<31 - 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := DecodeCursor(tt.cursor.Encode())
+			if err != nil {
+				t.Fatalf("DecodeCursor failed: %s", err)
+			}
+			if !decoded.CreatedAt.Equal(tt.cursor.CreatedAt) || decoded.ID != tt.cursor.ID {
+				t.Errorf("DecodeCursor = %v, want %v", decoded, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorMalformed(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{name: "empty", encoded: ""},
+		{name: "not base64", encoded: "not a cursor!"},
+		{name: "padded base64", encoded: base64.URLEncoding.EncodeToString([]byte("1700000000:12"))},
+		{name: "missing separator", encoded: encode("1700000000")},
+		{name: "empty parts", encoded: encode(":")},
+		{name: "invalid time", encoded: encode("yesterday:1")},
+		{name: "invalid id", encoded: encode("1700000000:one")},
+		{name: "extra part", encoded: encode("1700000000:1:2")},
+		{name: "time overflow", encoded: encode("99999999999999999999:1")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, err := DecodeCursor(tt.encoded)
+			var cErr sErrors.ClientError
+			if !errors.As(err, &cErr) || cErr.HTTPCode() != http.StatusBadRequest {
+				t.Fatalf("DecodeCursor error = %v, want bad request client error", err)
+			}
+			if cursor != (Cursor{}) {
+				t.Errorf("DecodeCursor = %v, want zero cursor", cursor)
+			}
+		})
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	now := time.Now()
+	messages := make([]Message, 4)
+	for i := range messages {
+		messages[i] = Message{ID: 10 - i, CreatedAt: now.Add(-time.Duration(i) * time.Second)}
+	}
+
+	tests := []struct {
+		name       string
+		messages   []Message
+		size       int
+		wantLen    int
+		wantCursor *Cursor
+	}{
+		{name: "last page", messages: messages[:3], size: 3, wantLen: 3},
+		{name: "older messages", messages: messages, size: 3, wantLen: 3, wantCursor: &Cursor{CreatedAt: messages[2].CreatedAt, ID: messages[2].ID}},
+		{name: "empty", messages: []Message{}, size: 3, wantLen: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page := NewPage(tt.messages, tt.size)
+			if len(page.Messages) != tt.wantLen {
+				t.Fatalf("len(Messages) = %d, want %d", len(page.Messages), tt.wantLen)
+			}
+			if tt.wantCursor == nil {
+				if page.NextCursor != "" {
+					t.Errorf("NextCursor = %q, want empty", page.NextCursor)
+				}
+				return
+			}
+
+			cursor, err := DecodeCursor(page.NextCursor)
+			if err != nil {
+				t.Fatalf("DecodeCursor failed: %s", err)
+			}
+			if !cursor.CreatedAt.Equal(tt.wantCursor.CreatedAt) || cursor.ID != tt.wantCursor.ID {
+				t.Errorf("NextCursor = %v, want %v", cursor, *tt.wantCursor)
+			}
+		})
+	}
+}
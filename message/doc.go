@@ -0,0 +1,3 @@
+// Package message handles all the conversation messages logic, like creation and validation.
+
+package message
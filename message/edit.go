@@ -0,0 +1,73 @@
+package message
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/coffemanfp/chat/errors"
+)
+
+// Revision is a previous content of a edited message.
+type Revision struct {
+	Content string `json:"content"`
+
+	// CreatedAt is the time when the content was replaced.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuthorizeEdit checks if the account can edit the message.
+// Just the author can edit its messages, while the edit window is open.
+//
+//	@param message Message: message to edit.
+//	@param accountID int: id of the account which edits.
+//	@param window time.Duration: time since the message is sent while it can be edited.
+//	@param now time.Time: current time.
+//	 @return err error: deleted message, forbidden or closed window ClientError.
+func AuthorizeEdit(message Message, accountID int, window time.Duration, now time.Time) (err error) {
+	if message.Deleted {
+		err = NewDeletedError(message.ID)
+		return
+	}
+	if message.AccountID != accountID {
+		err = errors.NewClientError(http.StatusForbidden, "forbidden: just the author can edit the message %d", message.ID)
+		return
+	}
+	if now.Sub(message.CreatedAt) > window {
+		err = errors.NewClientError(http.StatusForbidden, "forbidden: the message %d can be edited up to %s after it's sent", message.ID, window)
+	}
+	return
+}
+
+// AuthorizeDelete checks if the account can delete the message.
+// The authors can delete their messages, and the moderators the messages of any member.
+//
+//	@param message Message: message to delete.
+//	@param accountID int: id of the account which deletes.
+//	@param moderator bool: true if the account can delete the messages of other members.
+//	 @return err error: deleted message or forbidden ClientError.
+func AuthorizeDelete(message Message, accountID int, moderator bool) (err error) {
+	if message.Deleted {
+		err = NewDeletedError(message.ID)
+		return
+	}
+	if message.AccountID != accountID && !moderator {
+		err = errors.NewClientError(http.StatusForbidden, "forbidden: just the author or a moderator can delete the message %d", message.ID)
+	}
+	return
+}
+
+// NewNotFoundError initializes the error returned when the message doesn't exists in the conversation.
+//
+//	@param id int: message id.
+//	@return $1 error: not found ClientError.
+func NewNotFoundError(id int) error {
+	return errors.NewClientError(http.StatusNotFound, "not found: message %d not found", id)
+}
+
+// NewDeletedError initializes the error returned when a deleted message is changed.
+//
+//	@param id int: message id.
+//	@return $1 error: gone ClientError.
+func NewDeletedError(id int) error {
+	return errors.NewClientError(http.StatusGone, "message deleted: message %d was deleted", id)
+}
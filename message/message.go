@@ -0,0 +1,79 @@
+package message
+
+import (
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/coffemanfp/chat/errors"
+)
+
+// MaxContentLength is the max number of characters of a message content.
+const MaxContentLength = 4096
+
+// Message is the representation of a conversation message.
+type Message struct {
+	ID             int `json:"id,omitempty"`
+	ConversationID int `json:"conversation_id,omitempty"`
+	AccountID      int `json:"account_id,omitempty"`
+
+	// Seq is the position of the message in its conversation, starting at 1.
+	Seq int64 `json:"seq,omitempty"`
+
+	Content string `json:"content,omitempty"`
+
+	// AttachmentID is the id of the file attached to the message. Is 0 for the text messages.
+	AttachmentID int       `json:"attachment_id,omitempty"`
+	CreatedAt    time.Time `json:"created_at,omitempty"`
+
+	// EditedAt is the time of the last edition. Is nil if the message was never edited.
+	EditedAt *time.Time `json:"edited_at,omitempty"`
+
+	// Deleted marks the tombstones of the deleted messages, which keep no content.
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// New initializes a new message of the account in the conversation.
+// The messages with a attachment can have no content.
+//
+//	@param conversationID int: conversation id.
+//	@param accountID int: id of the account which sends the message.
+//	@param content string: message content.
+//	@param attachmentID int: id of the attached file, 0 for the text messages.
+//	@return message Message: Message builded.
+//	@return err error: error in the validation of the content.
+func New(conversationID, accountID int, content string, attachmentID int) (message Message, err error) {
+	content = strings.TrimSpace(content)
+	if content != "" || attachmentID == 0 {
+		err = ValidateContent(content)
+		if err != nil {
+			return
+		}
+	}
+
+	message = Message{
+		ConversationID: conversationID,
+		AccountID:      accountID,
+		Content:        content,
+		AttachmentID:   attachmentID,
+		// The database keeps microseconds, so the time is truncated to keep the same cursor.
+		CreatedAt: time.Now().Truncate(time.Microsecond),
+	}
+	return
+}
+
+// ValidateContent checks the message content length.
+//
+//	@param content string: content to validate.
+//	 @return err error: empty or too long content.
+func ValidateContent(content string) (err error) {
+	if content == "" {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid content: empty message content")
+		return
+	}
+	if utf8.RuneCountInString(content) > MaxContentLength {
+		err = errors.NewClientError(http.StatusBadRequest, "invalid content: message content exceeds %d characters", MaxContentLength)
+	}
+	return
+}
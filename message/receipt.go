@@ -0,0 +1,30 @@
+package message
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/coffemanfp/chat/errors"
+)
+
+// MaxReceiptsMembers is the max number of members of the conversations with read receipts per message.
+const MaxReceiptsMembers = 32
+
+// Receipt is the read receipt of a message by a member.
+type Receipt struct {
+	AccountID int `json:"account_id"`
+
+	// ReadAt is the last time the member read the conversation up to the message or a later one.
+	ReadAt time.Time `json:"read_at"`
+}
+
+// ValidateReceiptsMembers checks the conversation is small enough to list the read receipts of its messages.
+//
+//	@param members int: number of current members of the conversation.
+//	 @return err error: too many members ClientError.
+func ValidateReceiptsMembers(members int) (err error) {
+	if members > MaxReceiptsMembers {
+		err = errors.NewClientError(http.StatusBadRequest, "read receipts unavailable: read receipts are only available in conversations up to %d members", MaxReceiptsMembers)
+	}
+	return
+}
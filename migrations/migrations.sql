@@ -94,4 +94,332 @@ create table if not exists account_session (
     foreign key (account_id) references account(id)
 );
 
-create unique index idx_account_id_actived on account_session(account_id, actived);
\ No newline at end of file
+alter table account_session add column if not exists tmp_id varchar unique;
+
+drop index if exists idx_account_id_actived;
+
+create index if not exists idx_account_session_account_id on account_session(account_id);
+
+create table if not exists refresh_token (
+    id varchar unique not null,
+    session_id varchar not null,
+    parent_id varchar,
+    created_at timestamptz not null,
+    expires_at timestamptz not null,
+    used_at timestamptz,
+
+    primary key (id),
+    foreign key (session_id) references account_session(id)
+);
+
+create index if not exists idx_refresh_token_session_id on refresh_token(session_id);
+
+create table if not exists account_identity (
+    id serial unique not null,
+    account_id integer not null,
+    provider varchar not null,
+    subject varchar not null,
+    created_at timestamptz not null,
+
+    primary key (id),
+    unique (provider, subject),
+    foreign key (account_id) references account(id)
+);
+
+create table if not exists message (
+    id serial unique not null,
+    conversation_id integer not null,
+    account_id integer not null,
+    content varchar not null,
+    created_at timestamptz not null,
+
+    primary key (id),
+    foreign key (conversation_id) references conversation(id),
+    foreign key (account_id) references account(id)
+);
+
+create unique index if not exists idx_conversation_role_name on conversation_role(name);
+
+with permissions as (
+    insert into conversation_role_permissions (write, kick_account, add_account, change_role, change_conversation_detail)
+    select true, true, true, true, true
+    where not exists (select 1 from conversation_role where name = 'owner')
+    returning id
+)
+insert into conversation_role (permissions_id, name, description)
+select id, 'owner', 'Creator of the conversation' from permissions;
+
+with permissions as (
+    insert into conversation_role_permissions (write, kick_account, add_account, change_role, change_conversation_detail)
+    select true, false, false, false, false
+    where not exists (select 1 from conversation_role where name = 'member')
+    returning id
+)
+insert into conversation_role (permissions_id, name, description)
+select id, 'member', 'Member of the conversation' from permissions;
+
+with permissions as (
+    insert into conversation_role_permissions (write, kick_account, add_account, change_role, change_conversation_detail)
+    select true, true, true, false, true
+    where not exists (select 1 from conversation_role where name = 'admin')
+    returning id
+)
+insert into conversation_role (permissions_id, name, description)
+select id, 'admin', 'Manager of the conversation members and details' from permissions;
+
+with permissions as (
+    insert into conversation_role_permissions (write, kick_account, add_account, change_role, change_conversation_detail)
+    select false, false, false, false, false
+    where not exists (select 1 from conversation_role where name = 'read-only')
+    returning id
+)
+insert into conversation_role (permissions_id, name, description)
+select id, 'read-only', 'Reader of the conversation' from permissions;
+
+alter table conversation_role add column if not exists conversation_id integer references conversation(id);
+
+drop index if exists idx_conversation_role_name;
+
+create unique index if not exists idx_conversation_role_default_name on conversation_role(name) where conversation_id is null;
+
+create unique index if not exists idx_conversation_role_conversation_name on conversation_role(conversation_id, name) where conversation_id is not null;
+
+create unique index if not exists idx_convesation_members_current on convesation_members(conversation_id, account_id) where left_at is null;
+
+create index if not exists idx_convesation_members_account_id on convesation_members(account_id) where left_at is null;
+
+create table if not exists conversation_invite (
+    id serial unique not null,
+    conversation_id integer not null,
+    created_by integer not null,
+    token_hash varchar unique not null,
+    max_uses integer not null,
+    uses integer not null default 0,
+    expires_at timestamptz not null,
+    created_at timestamptz not null,
+    revoked_at timestamptz,
+
+    primary key (id),
+    foreign key (conversation_id) references conversation(id),
+    foreign key (created_by) references account(id)
+);
+
+create unique index if not exists idx_contact_from_to on contact(from_account_id, to_account_id);
+
+do $$
+begin
+    alter table contact add constraint contact_not_self check (from_account_id != to_account_id);
+exception
+    when duplicate_object then null;
+end $$;
+
+alter table blocked_account drop constraint if exists blocked_account_from_account_id_key;
+
+alter table blocked_account drop constraint if exists blocked_account_to_account_id_key;
+
+create unique index if not exists idx_blocked_account_from_to on blocked_account(from_account_id, to_account_id);
+
+create index if not exists idx_blocked_account_to_account_id on blocked_account(to_account_id);
+
+do $$
+begin
+    alter table blocked_account add constraint blocked_account_not_self check (from_account_id != to_account_id);
+exception
+    when duplicate_object then null;
+end $$;
+
+create index if not exists idx_message_conversation_created_at on message(conversation_id, created_at desc, id desc);
+
+alter table conversation_role_permissions add column if not exists delete_message boolean not null default false;
+
+update conversation_role_permissions p set delete_message = true
+from conversation_role r
+where r.permissions_id = p.id and r.conversation_id is null and r.name in ('owner', 'admin') and not p.delete_message;
+
+alter table message add column if not exists edited_at timestamptz;
+
+alter table message add column if not exists deleted_at timestamptz;
+
+alter table message add column if not exists deleted_by integer references account(id);
+
+create table if not exists message_revision (
+    id serial unique not null,
+    message_id integer not null,
+    content varchar not null,
+    created_at timestamptz not null,
+
+    primary key (id),
+    foreign key (message_id) references message(id)
+);
+
+create index if not exists idx_message_revision_message_id on message_revision(message_id, created_at);
+
+alter table conversation add column if not exists last_message_seq bigint not null default 0;
+
+alter table message add column if not exists seq bigint;
+
+update message msg set seq = numbered.seq
+from (
+    select id, row_number() over (partition by conversation_id order by created_at, id) as seq from message
+) numbered
+where msg.id = numbered.id and msg.seq is null;
+
+update conversation c set last_message_seq = (
+    select coalesce(max(seq), 0) from message where conversation_id = c.id
+)
+where c.last_message_seq = 0;
+
+alter table message alter column seq set not null;
+
+create unique index if not exists idx_message_conversation_seq on message(conversation_id, seq);
+
+-- The members existing before the read receipts have read all the messages already sent.
+do $$
+begin
+    alter table convesation_members add column last_read_seq bigint not null default 0;
+
+    update convesation_members m set last_read_seq = c.last_message_seq
+    from conversation c
+    where c.id = m.conversation_id;
+exception
+    when duplicate_column then null;
+end $$;
+
+alter table convesation_members add column if not exists last_read_at timestamptz;
+
+alter table account add column if not exists last_seen_visibility varchar not null default 'everyone';
+
+do $$
+begin
+    alter table account add constraint account_last_seen_visibility check (last_seen_visibility in ('everyone', 'contacts', 'nobody'));
+exception
+    when duplicate_object then null;
+end $$;
+
+alter table conversation add column if not exists type varchar not null default 'group';
+
+alter table conversation add column if not exists direct_low_account_id integer references account(id);
+
+alter table conversation add column if not exists direct_high_account_id integer references account(id);
+
+do $$
+begin
+    alter table conversation add constraint conversation_direct_pair check (
+        (type = 'group' and direct_low_account_id is null and direct_high_account_id is null) or
+        (type = 'direct' and direct_low_account_id < direct_high_account_id)
+    );
+exception
+    when duplicate_object then null;
+end $$;
+
+create unique index if not exists idx_conversation_direct_pair on conversation(direct_low_account_id, direct_high_account_id) where type = 'direct';
+
+create table if not exists attachment_blob (
+    hash varchar not null,
+    size bigint not null,
+    mime_type varchar not null,
+    created_at timestamptz not null,
+
+    primary key (hash)
+);
+
+create table if not exists attachment (
+    id serial unique not null,
+    account_id integer not null,
+    hash varchar not null,
+    name varchar not null,
+    mime_type varchar not null,
+    size bigint not null,
+    created_at timestamptz not null,
+
+    primary key (id),
+    foreign key (account_id) references account(id),
+    foreign key (hash) references attachment_blob(hash)
+);
+
+create index if not exists idx_attachment_account_id on attachment(account_id);
+
+alter table message add column if not exists attachment_id integer references attachment(id);
+
+create index if not exists idx_message_attachment_id on message(attachment_id) where attachment_id is not null;
+
+alter table attachment_blob add column if not exists processed_at timestamptz;
+alter table attachment_blob add column if not exists process_error varchar;
+
+create table if not exists attachment_variant (
+    hash varchar not null,
+    name varchar not null,
+    mime_type varchar not null,
+    width integer not null,
+    height integer not null,
+    size bigint not null,
+    created_at timestamptz not null,
+
+    primary key (hash, name),
+    foreign key (hash) references attachment_blob(hash)
+);
+
+create index if not exists idx_attachment_blob_pending on attachment_blob(created_at) where processed_at is null;
+
+alter table account add column if not exists anonymized_at timestamptz;
+
+create index if not exists idx_account_deleted_at on account(deleted_at) where deleted_at is not null and anonymized_at is null;
+
+alter table account add column if not exists email_verified_at timestamptz;
+
+create table if not exists account_token (
+    id varchar not null,
+    account_id integer not null,
+    purpose varchar not null,
+    created_at timestamptz not null,
+    expires_at timestamptz not null,
+    used_at timestamptz,
+
+    primary key (id),
+    foreign key (account_id) references account(id)
+);
+
+create index if not exists idx_account_token_account_id on account_token(account_id);
+
+alter table account_session add column if not exists tmp_id_expires_at timestamptz;
+
+-- The TmpIDs stored before they were hashed never expire, so they are invalidated.
+update account_session set tmp_id = null where tmp_id is not null and tmp_id_expires_at is null;
+
+-- The deleted direct conversations don't keep their pair, so a new one can be opened.
+drop index if exists idx_conversation_direct_pair;
+
+create unique index if not exists idx_conversation_direct_pair_active on conversation(direct_low_account_id, direct_high_account_id) where type = 'direct' and deleted_at is null;
+
+-- The members see the messages sent after they joined by its seq, not comparing the clocks of the server and the database.
+-- The direct conversations are opened with the first message, so their members see all the messages.
+do $$
+begin
+    alter table convesation_members add column joined_seq bigint not null default 0;
+
+    update convesation_members m set joined_seq = coalesce((
+        select max(msg.seq) from message msg where msg.conversation_id = m.conversation_id and msg.created_at < m.joined_at
+    ), 0)
+    from conversation c
+    where c.id = m.conversation_id and c.type != 'direct';
+exception
+    when duplicate_column then null;
+end $$;
+
+-- The unread messages are counted by member on the messages saving, not on every conversations listing.
+do $$
+begin
+    alter table convesation_members add column unread_count bigint not null default 0;
+
+    update convesation_members m set unread_count = (
+        select count(*) from message msg
+        where msg.conversation_id = m.conversation_id and msg.seq > m.last_read_seq
+        and msg.deleted_at is null and msg.account_id != m.account_id
+        and not exists (
+            select 1 from blocked_account b where b.from_account_id = m.account_id and b.to_account_id = msg.account_id
+        )
+    )
+    where m.left_at is null;
+exception
+    when duplicate_column then null;
+end $$;
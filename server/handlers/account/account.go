@@ -0,0 +1,266 @@
+package account
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coffemanfp/chat/account"
+	"github.com/coffemanfp/chat/attachment"
+	"github.com/coffemanfp/chat/auth"
+	"github.com/coffemanfp/chat/config"
+	"github.com/coffemanfp/chat/database"
+	sErrors "github.com/coffemanfp/chat/errors"
+	"github.com/coffemanfp/chat/server/handlers"
+	"github.com/coffemanfp/chat/server/handlers/chat"
+	"github.com/gorilla/mux"
+)
+
+// AccountHandler handles the account management requests.
+type AccountHandler struct {
+	repository    database.AccountRepository
+	blocks        database.BlockRepository
+	contacts      database.ContactRepository
+	conversations database.ConversationRepository
+	messages      database.MessageRepository
+	attachments   database.AttachmentRepository
+	signer        attachment.Signer
+	hub           *chat.Hub
+	writer        handlers.ResponseWriter
+	reader        handlers.RequestReader
+
+	// gracePeriod is the time since a account is deleted while it can be restored.
+	gracePeriod time.Duration
+}
+
+// NewAccountHandler initializes a new AccountHandler instance.
+//
+//	@param repo database.AccountRepository: AccountRepository interface for the accounts handling.
+//	@param blocks database.BlockRepository: BlockRepository interface to hide the profiles of the blockers.
+//	@param contacts database.ContactRepository: ContactRepository interface for the data exports.
+//	@param conversations database.ConversationRepository: ConversationRepository interface for the data exports.
+//	@param messages database.MessageRepository: MessageRepository interface for the data exports.
+//	@param attachments database.AttachmentRepository: AttachmentRepository interface for the pictures.
+//	@param signer attachment.Signer: signs the pictures URLs.
+//	@param hub *chat.Hub: keeps the online connections to close them on the account deletion.
+//	@param r handlers.RequestReader: RequestReader interface for reading request body operations.
+//	@param w handlers.ResponseWriter: ResponseWriter interface for writing request response operations.
+//	@param conf config.ConfigInfo: keeps the config information of the service.
+//	@return a AccountHandler: new AccountHandler instance.
+func NewAccountHandler(repo database.AccountRepository, blocks database.BlockRepository, contacts database.ContactRepository, conversations database.ConversationRepository, messages database.MessageRepository, attachments database.AttachmentRepository, signer attachment.Signer, hub *chat.Hub, r handlers.RequestReader, w handlers.ResponseWriter, conf config.ConfigInfo) (a AccountHandler) {
+	return AccountHandler{
+		repository:    repo,
+		blocks:        blocks,
+		contacts:      contacts,
+		conversations: conversations,
+		messages:      messages,
+		attachments:   attachments,
+		signer:        signer,
+		hub:           hub,
+		writer:        w,
+		reader:        r,
+		gracePeriod:   conf.Server.Accounts.DeletionGracePeriod,
+	}
+}
+
+// updateRequest is the body of a profile update. The missing fields are not updated.
+type updateRequest struct {
+	Nickname *string `json:"nickname,omitempty"`
+	Name     *string `json:"name,omitempty"`
+	LastName *string `json:"last_name,omitempty"`
+}
+
+// deleteRequest is the body of a account deletion.
+type deleteRequest struct {
+	// Password confirms the deletion. Is not required for the accounts without password.
+	Password string `json:"password,omitempty"`
+}
+
+// pictureRequest is the body of a picture change.
+type pictureRequest struct {
+	// AttachmentID is the id of a image uploaded by the account. 0 removes the picture.
+	AttachmentID int `json:"attachment_id"`
+}
+
+// HandleGetMe gets the details of the authenticated account.
+func (a AccountHandler) HandleGetMe(w http.ResponseWriter, r *http.Request) {
+	acc, err := a.repository.GetAccount(handlers.GetAccountID(r))
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	a.writer.JSON(w, http.StatusOK, acc.Details())
+}
+
+// HandleUpdateMe edits the nickname, name or last name of the authenticated account.
+func (a AccountHandler) HandleUpdateMe(w http.ResponseWriter, r *http.Request) {
+	var req updateRequest
+	err := a.readJSON(r, &req)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	acc, err := a.repository.GetAccount(handlers.GetAccountID(r))
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	if req.Nickname != nil {
+		acc.Nickname = strings.TrimSpace(*req.Nickname)
+	}
+	if req.Name != nil {
+		acc.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.LastName != nil {
+		acc.LastName = strings.TrimSpace(*req.LastName)
+	}
+
+	err = account.ValidateProfile(acc)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	acc, err = a.repository.UpdateProfile(acc)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	a.writer.JSON(w, http.StatusOK, acc.Details())
+}
+
+// HandleDeleteMe deletes the authenticated account and closes all its sessions.
+// The account can be restored signing in again until the grace period ends,
+// then its personal data is removed.
+func (a AccountHandler) HandleDeleteMe(w http.ResponseWriter, r *http.Request) {
+	accountID := handlers.GetAccountID(r)
+
+	var req deleteRequest
+	err := a.readJSON(r, &req)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	hash, err := a.repository.GetPassword(accountID)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+	if hash != "" {
+		var match bool
+		match, err = auth.ComparePassword(hash, req.Password)
+		if err != nil {
+			a.handleError(w, err)
+			return
+		}
+		if !match {
+			a.handleError(w, sErrors.NewClientError(http.StatusForbidden, "forbidden: invalid password"))
+			return
+		}
+	}
+
+	deletedAt, err := a.repository.DeleteAccount(accountID)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+	a.hub.CloseAccount(accountID)
+
+	a.writer.JSON(w, http.StatusOK, handlers.Hash{
+		"deleted_at": deletedAt,
+		"purge_at":   deletedAt.Add(a.gracePeriod),
+	})
+}
+
+// HandleGetProfile gets the public profile of a account by its nickname.
+// The accounts which blocked the authenticated account are not found.
+func (a AccountHandler) HandleGetProfile(w http.ResponseWriter, r *http.Request) {
+	nickname := mux.Vars(r)["nickname"]
+
+	acc, err := a.repository.GetAccountByNickname(nickname)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	blockers, err := a.blocks.GetBlockerIDs(handlers.GetAccountID(r), []int{acc.ID})
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+	if len(blockers) != 0 {
+		a.handleError(w, account.NewNicknameNotFoundError(nickname))
+		return
+	}
+
+	a.writer.JSON(w, http.StatusOK, acc.Profile())
+}
+
+// HandleSetPicture changes the picture of the authenticated account to one of its uploaded images.
+func (a AccountHandler) HandleSetPicture(w http.ResponseWriter, r *http.Request) {
+	accountID := handlers.GetAccountID(r)
+
+	var req pictureRequest
+	err := a.readJSON(r, &req)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	var pictureURL string
+	if req.AttachmentID != 0 {
+		var att attachment.Attachment
+		att, err = a.attachments.GetAttachment(req.AttachmentID)
+		if err != nil {
+			a.handleError(w, err)
+			return
+		}
+
+		err = attachment.CheckPicture(att, accountID)
+		if err != nil {
+			a.handleError(w, err)
+			return
+		}
+		pictureURL = a.signer.PictureURL(att)
+	}
+
+	err = a.repository.SetPicture(accountID, pictureURL)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	a.writer.JSON(w, http.StatusOK, handlers.Hash{
+		"picture_url": pictureURL,
+	})
+}
+
+func (a AccountHandler) readJSON(r *http.Request, v interface{}) (err error) {
+	err = a.reader.JSON(r, v)
+	if err != nil {
+		if _, ok := err.(sErrors.ClientError); !ok {
+			err = sErrors.NewClientError(http.StatusBadRequest, "invalid body: %s", err)
+		}
+	}
+	return
+}
+
+func (a AccountHandler) handleError(w http.ResponseWriter, err error) {
+	hErr, ok := err.(sErrors.ClientError)
+	if !ok {
+		log.Println(err)
+		a.writer.JSON(w, http.StatusInternalServerError, handlers.Hash{
+			"message": sErrors.SERVER_ERROR_MESSAGE,
+		})
+		return
+	}
+	a.writer.JSON(w, hErr.HTTPCode(), handlers.Hash{
+		"message": hErr.Error(),
+	})
+}
@@ -0,0 +1,3 @@
+// Package account implements the account management requests of the authenticated account.
+
+package account
@@ -0,0 +1,181 @@
+package account
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/coffemanfp/chat/contact"
+	"github.com/coffemanfp/chat/server/handlers"
+)
+
+// exportMessagesPage is the number of messages read by each query of a data export.
+const exportMessagesPage = 500
+
+// HandleExport sends a ZIP archive with the data of the authenticated account: its profile, contacts,
+// conversations and sent messages, each one as a JSON file.
+// The archive is built in a temporary file before the response is started, so the errors are still sent.
+func (a AccountHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	accountID := handlers.GetAccountID(r)
+
+	tmp, err := os.CreateTemp("", "export-*")
+	if err != nil {
+		a.handleError(w, fmt.Errorf("failed to create export file: %s", err))
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	err = a.writeExport(tmp, accountID)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		a.handleError(w, fmt.Errorf("failed to read export file: %s", err))
+		return
+	}
+
+	name := fmt.Sprintf("account-%d-%s.zip", accountID, time.Now().UTC().Format("20060102"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = io.Copy(w, io.NewSectionReader(tmp, 0, size))
+	if err != nil {
+		log.Printf("failed to send export of account %d: %s", accountID, err)
+	}
+}
+
+// writeExport writes the ZIP archive of the data of the account.
+func (a AccountHandler) writeExport(out io.Writer, accountID int) (err error) {
+	acc, err := a.repository.GetAccount(accountID)
+	if err != nil {
+		return
+	}
+
+	contacts, err := a.getAllContacts(accountID)
+	if err != nil {
+		return
+	}
+
+	conversations, err := a.conversations.GetConversations(accountID)
+	if err != nil {
+		return
+	}
+
+	zw := zip.NewWriter(out)
+	err = writeJSONFile(zw, "profile.json", acc.Details())
+	if err != nil {
+		return
+	}
+	err = writeJSONFile(zw, "contacts.json", contacts)
+	if err != nil {
+		return
+	}
+	err = writeJSONFile(zw, "conversations.json", conversations)
+	if err != nil {
+		return
+	}
+	err = a.writeMessages(zw, accountID)
+	if err != nil {
+		return
+	}
+
+	err = zw.Close()
+	if err != nil {
+		err = fmt.Errorf("failed to write export of account %d: %s", accountID, err)
+	}
+	return
+}
+
+// getAllContacts gets all the pages of the address book of the account.
+func (a AccountHandler) getAllContacts(accountID int) (contacts []contact.Contact, err error) {
+	contacts = []contact.Contact{}
+	query := contact.Query{
+		Sort:  contact.SortByCreatedAt,
+		Limit: contact.MaxLimit,
+	}
+
+	for {
+		var page []contact.Contact
+		page, _, err = a.contacts.GetContacts(accountID, query)
+		if err != nil {
+			return
+		}
+		contacts = append(contacts, page...)
+		if len(page) < query.Limit {
+			return
+		}
+		query.Offset += len(page)
+	}
+}
+
+// writeMessages writes the messages sent by the account as a JSON array, reading them by pages.
+func (a AccountHandler) writeMessages(zw *zip.Writer, accountID int) (err error) {
+	f, err := zw.Create("messages.json")
+	if err != nil {
+		return
+	}
+
+	_, err = io.WriteString(f, "[")
+	if err != nil {
+		return
+	}
+
+	var afterID int
+	first := true
+	enc := json.NewEncoder(f)
+	for {
+		page, mErr := a.messages.GetAccountMessages(accountID, afterID, exportMessagesPage)
+		if mErr != nil {
+			err = mErr
+			return
+		}
+
+		for _, msg := range page {
+			if !first {
+				_, err = io.WriteString(f, ",")
+				if err != nil {
+					return
+				}
+			}
+			first = false
+
+			err = enc.Encode(msg)
+			if err != nil {
+				return
+			}
+			afterID = msg.ID
+		}
+		if len(page) < exportMessagesPage {
+			break
+		}
+	}
+
+	_, err = io.WriteString(f, "]")
+	return
+}
+
+// writeJSONFile writes the value provided as a indented JSON file of the archive.
+func writeJSONFile(zw *zip.Writer, name string, v interface{}) (err error) {
+	f, err := zw.Create(name)
+	if err != nil {
+		return
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(v)
+	return
+}
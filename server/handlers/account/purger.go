@@ -0,0 +1,64 @@
+package account
+
+import (
+	"log"
+	"time"
+
+	"github.com/coffemanfp/chat/database"
+)
+
+const (
+	// purgeInterval is the time between the purges of the deleted accounts.
+	purgeInterval = time.Hour
+
+	// purgeBatchSize is the max number of accounts anonymized by each purge query.
+	purgeBatchSize = 100
+)
+
+// Purger removes in background the personal data of the deleted accounts whose grace period ended.
+type Purger struct {
+	repository  database.AccountRepository
+	gracePeriod time.Duration
+}
+
+// NewPurger initializes a new Purger instance.
+//
+//	@param repo database.AccountRepository: AccountRepository interface to purge the accounts.
+//	@param gracePeriod time.Duration: time since the deletion while a account can be restored.
+//	@return p Purger: new Purger instance.
+func NewPurger(repo database.AccountRepository, gracePeriod time.Duration) (p Purger) {
+	return Purger{
+		repository:  repo,
+		gracePeriod: gracePeriod,
+	}
+}
+
+// Start starts the periodic purge of the deleted accounts.
+func (p Purger) Start() {
+	go func() {
+		ticker := time.NewTicker(purgeInterval)
+		defer ticker.Stop()
+
+		for {
+			p.purge()
+			<-ticker.C
+		}
+	}()
+}
+
+// purge anonymizes all the accounts whose grace period ended, by batches.
+func (p Purger) purge() {
+	for {
+		n, err := p.repository.PurgeAccounts(time.Now().Add(-p.gracePeriod), purgeBatchSize)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		if n > 0 {
+			log.Printf("Purged %d deleted accounts", n)
+		}
+		if n < purgeBatchSize {
+			return
+		}
+	}
+}
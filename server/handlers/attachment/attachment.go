@@ -0,0 +1,273 @@
+package attachment
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coffemanfp/chat/attachment"
+	"github.com/coffemanfp/chat/config"
+	"github.com/coffemanfp/chat/database"
+	sErrors "github.com/coffemanfp/chat/errors"
+	"github.com/coffemanfp/chat/server/handlers"
+	"github.com/coffemanfp/chat/storage"
+)
+
+// fileField is the multipart form field of the uploaded file.
+const fileField = "file"
+
+// AttachmentHandler handles the attachments requests.
+type AttachmentHandler struct {
+	repository    database.AttachmentRepository
+	store         storage.BlobStore
+	signer        attachment.Signer
+	processor     *Processor
+	writer        handlers.ResponseWriter
+	maxUploadSize int64
+	urlLifetime   time.Duration
+}
+
+// NewAttachmentHandler initializes a new AttachmentHandler instance.
+//
+//	@param repo database.AttachmentRepository: AttachmentRepository interface for the attachments handling.
+//	@param store storage.BlobStore: BlobStore interface to keep the files content.
+//	@param signer attachment.Signer: signs the download URLs.
+//	@param processor *Processor: generates the variants of the uploaded images.
+//	@param w handlers.ResponseWriter: ResponseWriter interface for writing request response operations.
+//	@param conf config.ConfigInfo: keeps the config information of the service.
+//	@return a AttachmentHandler: new AttachmentHandler instance.
+func NewAttachmentHandler(repo database.AttachmentRepository, store storage.BlobStore, signer attachment.Signer, processor *Processor, w handlers.ResponseWriter, conf config.ConfigInfo) (a AttachmentHandler) {
+	return AttachmentHandler{
+		repository:    repo,
+		store:         store,
+		signer:        signer,
+		processor:     processor,
+		writer:        w,
+		maxUploadSize: conf.Storage.MaxUploadSize,
+		urlLifetime:   conf.Storage.URLLifetime,
+	}
+}
+
+// HandleUpload uploads a file of the authenticated account, sent on the file field of a multipart form.
+// The type is detected by the file content, and the files with the same content share the same blob.
+// The variants of the new images are generated in background.
+func (a AttachmentHandler) HandleUpload(w http.ResponseWriter, r *http.Request) {
+	// The limit includes a margin for the multipart headers, the file size is checked while reading it.
+	r.Body = http.MaxBytesReader(w, r.Body, a.maxUploadSize+64*1024)
+
+	tmp, name, size, hash, err := a.readFile(r)
+	if tmp != nil {
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+	}
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	head := make([]byte, 512)
+	n, err := tmp.ReadAt(head, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		a.handleError(w, fmt.Errorf("failed to read uploaded file: %s", err))
+		return
+	}
+
+	att, err := attachment.New(handlers.GetAccountID(r), name, attachment.DetectMimeType(head[:n]), size, hash, a.maxUploadSize)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	exists, err := a.repository.HasBlob(att.Hash)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+	if !exists {
+		err = a.store.Put(att.Key(), io.NewSectionReader(tmp, 0, size), size, att.MimeType)
+		if err != nil {
+			a.handleError(w, err)
+			return
+		}
+	}
+
+	att, err = a.repository.SaveAttachment(att)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+	if !exists && att.Processable() {
+		a.processor.Enqueue(att.Blob())
+	}
+
+	att.URL = a.signer.URL(att, time.Now().Add(a.urlLifetime))
+	a.writer.JSON(w, http.StatusCreated, att)
+}
+
+// HandleGet gets a attachment visible for the authenticated account with a new signed download URL.
+func (a AttachmentHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	id, err := handlers.GetIntVar(r, "id")
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	can, err := a.repository.CanAccess(id, handlers.GetAccountID(r))
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+	if !can {
+		a.handleError(w, attachment.NewNotFoundError(id))
+		return
+	}
+
+	att, err := a.repository.GetAttachment(id)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	att.URL = a.signer.URL(att, time.Now().Add(a.urlLifetime))
+	a.writer.JSON(w, http.StatusOK, att)
+}
+
+// HandleDownload sends the content of a attachment of a signed download URL,
+// or the content of one of its variants with the variant query param.
+// The pictures URLs just send the variant of their signature.
+func (a AttachmentHandler) HandleDownload(w http.ResponseWriter, r *http.Request) {
+	id, err := handlers.GetIntVar(r, "id")
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	variantName, err := a.signer.Verify(id, r.URL.Query(), time.Now())
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	att, err := a.repository.GetAttachment(id)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	key, mimeType, size, name := att.Key(), att.MimeType, att.Size, att.Name
+	if variantName != "" {
+		variant, ok := att.Variant(variantName)
+		if !ok {
+			a.handleError(w, attachment.NewVariantNotFoundError(id, variantName))
+			return
+		}
+		key, mimeType, size = att.Blob().VariantKey(variant.Name), variant.MimeType, variant.Size
+		name = variantFileName(att.Name, variant)
+	}
+
+	content, err := a.store.Get(key)
+	if err != nil {
+		if errors.Is(err, storage.ErrBlobNotFound) {
+			err = attachment.NewNotFoundError(id)
+		}
+		a.handleError(w, err)
+		return
+	}
+	defer content.Close()
+
+	// The images are shown inline, the other files are always downloaded.
+	disposition := "attachment"
+	if att.IsImage() {
+		disposition = "inline"
+	}
+
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = io.Copy(w, content)
+	if err != nil {
+		log.Printf("failed to send attachment %d: %s", id, err)
+	}
+}
+
+// variantFileName gets the file name of a variant, like photo_256.jpg of photo.jpeg.
+func variantFileName(name string, variant attachment.Variant) string {
+	ext := ".png"
+	if variant.MimeType == "image/jpeg" {
+		ext = ".jpg"
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name)) + "_" + variant.Name + ext
+}
+
+// readFile reads the uploaded file to a temporary file, computing its size and hash.
+// The temporary file must be removed by the caller, even on error.
+func (a AttachmentHandler) readFile(r *http.Request) (tmp *os.File, name string, size int64, hash string, err error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		err = sErrors.NewClientError(http.StatusBadRequest, "invalid body: %s", err)
+		return
+	}
+
+	for {
+		part, pErr := mr.NextPart()
+		if pErr == io.EOF {
+			err = sErrors.NewClientError(http.StatusBadRequest, "invalid body: missing %s field", fileField)
+			return
+		}
+		if pErr != nil {
+			err = sErrors.NewClientError(http.StatusBadRequest, "invalid body: %s", pErr)
+			return
+		}
+		if part.FormName() != fileField {
+			part.Close()
+			continue
+		}
+
+		name = part.FileName()
+		tmp, err = os.CreateTemp("", "upload-*")
+		if err != nil {
+			err = fmt.Errorf("failed to create upload file: %s", err)
+			return
+		}
+
+		hasher := sha256.New()
+		size, err = io.Copy(io.MultiWriter(tmp, hasher), io.LimitReader(part, a.maxUploadSize+1))
+		part.Close()
+		if err != nil {
+			err = sErrors.NewClientError(http.StatusBadRequest, "invalid body: %s", err)
+			return
+		}
+
+		hash = hex.EncodeToString(hasher.Sum(nil))
+		err = attachment.ValidateSize(size, a.maxUploadSize)
+		return
+	}
+}
+
+func (a AttachmentHandler) handleError(w http.ResponseWriter, err error) {
+	hErr, ok := err.(sErrors.ClientError)
+	if !ok {
+		log.Println(err)
+		a.writer.JSON(w, http.StatusInternalServerError, handlers.Hash{
+			"message": sErrors.SERVER_ERROR_MESSAGE,
+		})
+		return
+	}
+	a.writer.JSON(w, hErr.HTTPCode(), handlers.Hash{
+		"message": hErr.Error(),
+	})
+}
@@ -0,0 +1,4 @@
+// Package attachment implements the uploads and downloads of the attachments.
+// The downloads use signed URLs, so they can be used on the clients without the session token.
+
+package attachment
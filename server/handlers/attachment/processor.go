@@ -0,0 +1,162 @@
+package attachment
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/coffemanfp/chat/attachment"
+	"github.com/coffemanfp/chat/database"
+	"github.com/coffemanfp/chat/storage"
+	"github.com/coffemanfp/chat/thumbnail"
+)
+
+const (
+	// queueSize is the number of blobs waiting to be processed. The blobs which don't fit
+	// are processed by the next scan of pending blobs.
+	queueSize = 256
+
+	// scanInterval is the time between the scans of the pending blobs.
+	scanInterval = time.Minute
+)
+
+// Processor generates the image variants of the uploaded blobs in background workers,
+// so the uploads don't wait for them.
+type Processor struct {
+	repository database.AttachmentRepository
+	store      storage.BlobStore
+	workers    int
+	jobs       chan attachment.Blob
+
+	mu sync.Mutex
+
+	// queued keeps the hashes of the blobs queued or in process, to not process them twice.
+	queued map[string]bool
+}
+
+// NewProcessor initializes a new Processor instance.
+//
+//	@param repo database.AttachmentRepository: AttachmentRepository interface to store the variants.
+//	@param store storage.BlobStore: BlobStore interface of the blobs and variants content.
+//	@param workers int: number of background workers.
+//	@return p *Processor: new Processor instance.
+func NewProcessor(repo database.AttachmentRepository, store storage.BlobStore, workers int) (p *Processor) {
+	return &Processor{
+		repository: repo,
+		store:      store,
+		workers:    workers,
+		jobs:       make(chan attachment.Blob, queueSize),
+		queued:     make(map[string]bool),
+	}
+}
+
+// Start starts the workers and the periodic scan of the pending blobs, which resumes the blobs
+// not processed before a restart.
+func (p *Processor) Start() {
+	for i := 0; i < p.workers; i++ {
+		go p.work()
+	}
+	go p.scan()
+}
+
+// Enqueue queues a blob to generate its variants, without waiting.
+//
+//	@param blob attachment.Blob: blob to process.
+//	@return ok bool: the blob is queued or already in process.
+func (p *Processor) Enqueue(blob attachment.Blob) (ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.queued[blob.Hash] {
+		ok = true
+		return
+	}
+
+	select {
+	case p.jobs <- blob:
+		p.queued[blob.Hash] = true
+		ok = true
+	default:
+	}
+	return
+}
+
+func (p *Processor) work() {
+	for blob := range p.jobs {
+		err := p.process(blob)
+		if err != nil {
+			log.Printf("failed to process attachment blob %s: %s", blob.Hash, err)
+		}
+
+		p.mu.Lock()
+		delete(p.queued, blob.Hash)
+		p.mu.Unlock()
+	}
+}
+
+func (p *Processor) scan() {
+	ticker := time.NewTicker(scanInterval)
+	defer ticker.Stop()
+
+	for {
+		blobs, err := p.repository.GetPendingBlobs(thumbnail.SupportedTypes(), queueSize)
+		if err != nil {
+			log.Println(err)
+		}
+		for _, blob := range blobs {
+			if !p.Enqueue(blob) {
+				break
+			}
+		}
+		<-ticker.C
+	}
+}
+
+// process generates and stores the variants of a blob. The invalid images are marked as processed
+// with their error, the storage errors are retried by the next scan.
+func (p *Processor) process(blob attachment.Blob) (err error) {
+	content, err := p.store.Get(blob.Key())
+	if err != nil {
+		if errors.Is(err, storage.ErrBlobNotFound) {
+			err = p.repository.SaveVariants(blob.Hash, nil, err.Error())
+		}
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(content, blob.Size+1))
+	content.Close()
+	if err != nil {
+		err = fmt.Errorf("failed to read attachment blob: %s", err)
+		return
+	}
+
+	thumbnails, err := thumbnail.Generate(data, blob.MimeType)
+	if err != nil {
+		err = p.repository.SaveVariants(blob.Hash, nil, err.Error())
+		return
+	}
+
+	variants := make([]attachment.Variant, 0, len(thumbnails))
+	for _, t := range thumbnails {
+		size := int64(len(t.Data))
+		err = p.store.Put(blob.VariantKey(t.Name), bytes.NewReader(t.Data), size, t.MimeType)
+		if err != nil {
+			return
+		}
+
+		variants = append(variants, attachment.Variant{
+			Name:      t.Name,
+			MimeType:  t.MimeType,
+			Width:     t.Width,
+			Height:    t.Height,
+			Size:      size,
+			CreatedAt: time.Now(),
+		})
+	}
+
+	err = p.repository.SaveVariants(blob.Hash, variants, "")
+	return
+}
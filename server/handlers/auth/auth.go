@@ -1,19 +1,22 @@
 package auth
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/coffemanfp/chat/account"
 	"github.com/coffemanfp/chat/auth"
 	"github.com/coffemanfp/chat/config"
 	"github.com/coffemanfp/chat/database"
 	sErrors "github.com/coffemanfp/chat/errors"
+	"github.com/coffemanfp/chat/mail"
 	"github.com/coffemanfp/chat/server/handlers"
+	"github.com/coffemanfp/chat/server/handlers/chat"
 	"github.com/gorilla/mux"
 )
 
@@ -24,9 +27,23 @@ type handlerName string
 type AuthHandler struct {
 	config     config.ConfigInfo
 	repository database.AuthRepository
+	sessions   database.SessionRepository
+	tokens     auth.JWTManager
 	writer     handlers.ResponseWriter
 	reader     handlers.RequestReader
 
+	// hub keeps the online connections, closed with their sessions.
+	hub *chat.Hub
+
+	// mailer sends the email verification and password reset emails.
+	mailer mail.Mailer
+
+	// actionTokens signs the single use tokens of the emails.
+	actionTokens auth.ActionTokenSigner
+
+	// dummyHash is compared when the account is missing, so the logins take the same time for every account.
+	dummyHash string
+
 	// accountReaders keeps the services to be used for read the account info which is trying to sign.
 	accountReaders map[handlerName]accountReader
 }
@@ -36,31 +53,79 @@ type accountReader interface {
 	// read reads the account info and return it in a new instance.
 	//  @param w http.ResponseWriter: response writer of the call.
 	//  @param r *http.Request: request instance of the call.
-	//	@return $1 account.Account: new account information instance.
+	//	@return $1 identity: new account identity instance.
 	//	@return $2 error: connection or reading error.
-	read(w http.ResponseWriter, r *http.Request) (account.Account, error)
+	read(w http.ResponseWriter, r *http.Request) (identity, error)
+}
+
+// externalAccountReader represents a service which reads the account info of a external sign platform.
+type externalAccountReader interface {
+	accountReader
+
+	// start redirects the client to the sign page of the external platform.
+	//  @param w http.ResponseWriter: response writer of the call.
+	//  @param r *http.Request: request instance of the call.
+	//	@return $1 error: sign flow initialization error.
+	start(w http.ResponseWriter, r *http.Request) error
+}
+
+// identity is the account info read by a accountReader.
+type identity struct {
+	account account.Account
+
+	// subject is the account unique identifier in the external platform. Is empty for the system accounts.
+	subject string
+
+	// emailVerified is true if the external platform has verified the account email.
+	emailVerified bool
 }
 
 // NewAuthHandler initializes a new AuthHandler instance.
 //
 //	@param repo database.AuthRepository: AuthRepository interface for the authentication handling.
+//	@param sessions database.SessionRepository: SessionRepository interface for the sessions handling.
+//	@param tokens auth.JWTManager: generator of the session tokens.
+//	@param hub *chat.Hub: keeps the online connections to close them with their sessions.
+//	@param mailer mail.Mailer: Mailer interface to send the account emails.
 //	@param r handlers.RequestReader: RequestReader interface for reading request operations.
 //	@param w handlers.ResponseWriter: ResponseWriter interface for writing request response operations.
 //	@param conf config.ConfigInfo: keeps the config information of the service.
 //	@return u AuthHandler: new AuthHandler instance.
-func NewAuthHandler(repo database.AuthRepository, r handlers.RequestReader, w handlers.ResponseWriter, conf config.ConfigInfo) (u AuthHandler) {
-	return AuthHandler{
+func NewAuthHandler(repo database.AuthRepository, sessions database.SessionRepository, tokens auth.JWTManager, hub *chat.Hub, mailer mail.Mailer, r handlers.RequestReader, w handlers.ResponseWriter, conf config.ConfigInfo) (u AuthHandler) {
+	u = AuthHandler{
 		reader:     r,
 		writer:     w,
 		repository: repo,
+		sessions:   sessions,
+		tokens:     tokens,
 		config:     conf,
+
+		hub:          hub,
+		mailer:       mailer,
+		actionTokens: auth.NewActionTokenSigner(conf.Server.SecretKey),
+		dummyHash:    newDummyHash(conf.Server.HashCost),
 		accountReaders: map[handlerName]accountReader{
 			systemHandlerName: systemAccountReader{
 				reader: r,
-				writer: w,
 			},
 		},
 	}
+
+	if conf.OAuth.Google.Enabled() {
+		u.accountReaders[googleHandlerName] = newGoogleAccountReader(conf.OAuth.Google)
+	}
+	if conf.OAuth.Facebook.Enabled() {
+		u.accountReaders[facebookHandlerName] = newFacebookAccountReader(conf.OAuth.Facebook)
+	}
+	for _, props := range conf.OAuth.OIDC {
+		name := handlerName(props.Name)
+		if _, ok := u.accountReaders[name]; ok {
+			log.Printf("Skipping OIDC platform %s: duplicated sign platform name", name)
+			continue
+		}
+		u.accountReaders[name] = newOIDCAccountReader(props)
+	}
+	return
 }
 
 // HandleAuth implements the account authentication actions.
@@ -69,9 +134,32 @@ func (a AuthHandler) HandleAuth(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	action := vars["action"]
 
-	var account account.Account
-	err := JSON(r, account)
+	switch action {
+	case "login", "signup":
+		a.handleSign(action, w, r)
+	case "exchange":
+		a.handleExchange(w, r)
+	case "refresh":
+		a.handleRefresh(w, r)
+	case "verify-email":
+		a.handleVerifyEmail(w, r)
+	case "forgot-password":
+		a.handleForgotPassword(w, r)
+	case "reset-password":
+		a.handleResetPassword(w, r)
+	default:
+		a.handleError(w, sErrors.NewClientError(http.StatusNotFound, "not found: unknown auth action %s", action))
+	}
+}
+
+// handleSign performs the sign actions of the system accounts.
+// Responds with the one use TmpID of the new session.
+//
+//	@param action string: sign action, login or signup.
+func (a AuthHandler) handleSign(action string, w http.ResponseWriter, r *http.Request) {
+	identity, err := a.accountReaders[systemHandlerName].read(w, r)
 	if err != nil {
+		a.handleError(w, err)
 		return
 	}
 
@@ -79,22 +167,330 @@ func (a AuthHandler) HandleAuth(w http.ResponseWriter, r *http.Request) {
 
 	switch action {
 	case "login":
-		id, err = a.handleLogin(account, w, r)
+		id, err = a.handleLogin(identity.account, w, r)
+		code = http.StatusOK
+	case "signup":
+		id, err = a.handleSignUp(identity.account, w, r)
+		code = http.StatusCreated
+	}
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	session, err := a.createSession(id, systemHandlerName)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	// The sign up succeeds even if the email can't be sent, the account can request it again.
+	if action == "signup" {
+		err = a.sendVerification(id)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	a.writer.JSON(w, code, handlers.Hash{
+		"tmp_id": session.TmpID,
+	})
+	log.Println("Success", action)
+}
+
+// handleExchange swaps the one use TmpID of a new session for its session tokens.
+// The TmpID is invalidated, so the exchange can be performed just once.
+func (a AuthHandler) handleExchange(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		TmpID string `json:"tmp_id"`
+	}
+	err := a.reader.JSON(r, &body)
+	if err != nil {
+		a.handleError(w, sErrors.NewClientError(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	_, tokens, err := a.ExchangeTmpID(body.TmpID)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	a.writer.JSON(w, http.StatusOK, tokens)
+	log.Println("Success exchange")
+}
+
+// handleRefresh rotates the refresh token of a session, generating a new access token.
+// A refresh token reuse revokes the whole session.
+func (a AuthHandler) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	err := a.reader.JSON(r, &body)
+	if err != nil {
+		a.handleError(w, sErrors.NewClientError(http.StatusBadRequest, "%s", err))
+		return
+	}
+
+	tokens, err := a.refresh(body.RefreshToken)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	a.writer.JSON(w, http.StatusOK, tokens)
+	log.Println("Success refresh")
+}
+
+// ExchangeTmpID invalidates the TmpID and generates the tokens of its session.
+//
+//	@param tmpID string: one use TmpID of the session.
+//	@return session auth.Session: session of the TmpID.
+//	@return tokens auth.TokenPair: new tokens of the session.
+//	@return err error: invalid or already used TmpID, signing or connection error.
+func (a AuthHandler) ExchangeTmpID(tmpID string) (session auth.Session, tokens auth.TokenPair, err error) {
+	if tmpID == "" {
+		err = sErrors.NewClientError(http.StatusBadRequest, "invalid tmp id: empty tmp id")
+		return
+	}
+
+	session, err = a.sessions.ExchangeTmpID(tmpID)
+	if err != nil {
+		return
+	}
+	if session.ID == "" {
+		err = sErrors.NewClientError(http.StatusUnauthorized, "invalid tmp id: tmp id not found or already used")
+		return
+	}
+
+	refreshToken, err := auth.NewRefreshToken()
+	if err != nil {
+		return
+	}
+
+	err = a.sessions.SaveRefreshToken(session.ID, auth.HashToken(refreshToken), time.Now().Add(a.config.Server.Token.RefreshLifetime))
+	if err != nil {
+		return
+	}
+
+	tokens, err = a.newTokenPair(session, refreshToken)
+	return
+}
+
+// refresh rotates the refresh token provided.
+//
+//	@param refreshToken string: refresh token to use.
+//	@return tokens auth.TokenPair: new tokens of the session.
+//	@return err error: invalid, expired or reused refresh token, signing or connection error.
+func (a AuthHandler) refresh(refreshToken string) (tokens auth.TokenPair, err error) {
+	if refreshToken == "" {
+		err = sErrors.NewClientError(http.StatusBadRequest, "invalid refresh token: empty refresh token")
+		return
+	}
+
+	newRefreshToken, err := auth.NewRefreshToken()
+	if err != nil {
+		return
+	}
+
+	session, reused, err := a.sessions.RotateRefreshToken(auth.HashToken(refreshToken), auth.HashToken(newRefreshToken), time.Now().Add(a.config.Server.Token.RefreshLifetime))
+	if err != nil {
+		return
+	}
+	if reused {
+		log.Printf("Refresh token reuse detected, session of account %d revoked", session.AccountID)
+		a.hub.CloseSession(session.ID)
+		err = sErrors.NewClientError(http.StatusUnauthorized, "invalid refresh token: refresh token reused, session revoked")
+		return
+	}
+	if session.ID == "" {
+		err = sErrors.NewClientError(http.StatusUnauthorized, "invalid refresh token: refresh token not found, expired or session closed")
+		return
+	}
+
+	tokens, err = a.newTokenPair(session, newRefreshToken)
+	return
+}
+
+// newTokenPair generates a new access token for the session.
+//
+//	@param session auth.Session: session of the tokens.
+//	@param refreshToken string: refresh token already stored.
+//	@return tokens auth.TokenPair: tokens of the session.
+//	@return err error: signing error.
+func (a AuthHandler) newTokenPair(session auth.Session, refreshToken string) (tokens auth.TokenPair, err error) {
+	accessToken, err := a.tokens.Generate(session.AccountID, session.ID)
+	if err != nil {
+		return
+	}
+
+	tokens = auth.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(a.tokens.Lifetime().Seconds()),
+	}
+	return
+}
+
+// HandleLogout deactivates the session of the authenticated account.
+func (a AuthHandler) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	sessionID := handlers.GetSessionID(r)
+	err := a.sessions.DeactivateSession(sessionID)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+	a.hub.CloseSession(sessionID)
+
+	a.writer.JSON(w, http.StatusOK, handlers.Hash{
+		"message": "session closed",
+	})
+	log.Println("Success logout")
+}
+
+// HandleExternalStart starts the sign flow of a external platform, redirecting the client to its sign page.
+// The identity link flows keep their link token in a cookie until the callback.
+func (a AuthHandler) HandleExternalStart(w http.ResponseWriter, r *http.Request) {
+	reader, err := a.getExternalAccountReader(r)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	if link := r.URL.Query().Get("link"); link != "" {
+		_, err = a.actionTokens.Parse(link, auth.LinkIdentityPurpose, time.Now())
 		if err != nil {
 			a.handleError(w, err)
 			return
 		}
-		code = 200
+		http.SetCookie(w, &http.Cookie{
+			Name:     oauthLinkCookie,
+			Value:    link,
+			Path:     r.URL.Path[:strings.LastIndex(r.URL.Path, "/")],
+			MaxAge:   int(oauthStateLifetime.Seconds()),
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
 	}
 
-	token, err := auth.GenerateJWT(a.config.Server.SecretKey, id)
+	err = reader.start(w, r)
+	if err != nil {
+		a.handleError(w, err)
+	}
+}
+
+// HandleLinkStart generates the URL to start the link of a external platform identity to the authenticated account.
+// The URL is valid just once, for LinkIdentityLifetime.
+func (a AuthHandler) HandleLinkStart(w http.ResponseWriter, r *http.Request) {
+	_, err := a.getExternalAccountReader(r)
 	if err != nil {
 		a.handleError(w, err)
 		return
 	}
 
-	a.writer.JSON(w, code, token)
-	log.Println("Success", action)
+	token, err := a.actionTokens.New(handlers.GetAccountID(r), auth.LinkIdentityPurpose, auth.LinkIdentityLifetime)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	err = a.repository.SaveActionToken(token)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	startPath := strings.TrimSuffix(r.URL.Path, "/link") + "/start"
+	a.writer.JSON(w, http.StatusOK, handlers.Hash{
+		"url": strings.TrimSuffix(a.config.Server.PublicURL, "/") + startPath + "?" + url.Values{"link": {token.Token}}.Encode(),
+	})
+}
+
+// HandleExternalCallback completes the sign flow of a external platform.
+// The platform identity is linked to a account, creating it if it doesn't exists. The identity link flows
+// link it to the account of their link token.
+// Responds with the one use TmpID of the new session, redirecting to the client if it's configured.
+func (a AuthHandler) HandleExternalCallback(w http.ResponseWriter, r *http.Request) {
+	reader, err := a.getExternalAccountReader(r)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+	provider := handlerName(mux.Vars(r)["provider"])
+
+	identity, err := reader.read(w, r)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	var id int
+	link, err := a.checkLinkCookie(w, r)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+	if link.ID != "" {
+		id = link.AccountID
+		err = a.repository.LinkIdentity(link, string(provider), identity.subject)
+	} else {
+		id, err = a.repository.SignWithIdentity(identity.account, string(provider), identity.subject, identity.emailVerified)
+	}
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	session, err := a.createSession(id, provider)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	if a.config.OAuth.ClientRedirectURL != "" {
+		http.Redirect(w, r, fmt.Sprintf("%s#tmp_id=%s", a.config.OAuth.ClientRedirectURL, url.QueryEscape(session.TmpID)), http.StatusFound)
+	} else {
+		a.writer.JSON(w, http.StatusOK, handlers.Hash{
+			"tmp_id": session.TmpID,
+		})
+	}
+	log.Println("Success external sign with", provider)
+}
+
+// checkLinkCookie gets the link token of the identity link flows, removing its cookie.
+// Returns a empty token for the sign flows.
+func (a AuthHandler) checkLinkCookie(w http.ResponseWriter, r *http.Request) (token auth.ActionToken, err error) {
+	cookie, err := r.Cookie(oauthLinkCookie)
+	if err != nil {
+		err = nil
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   oauthLinkCookie,
+		Path:   r.URL.Path[:strings.LastIndex(r.URL.Path, "/")],
+		MaxAge: -1,
+	})
+
+	token, err = a.actionTokens.Parse(cookie.Value, auth.LinkIdentityPurpose, time.Now())
+	return
+}
+
+func (a AuthHandler) getExternalAccountReader(r *http.Request) (reader externalAccountReader, err error) {
+	provider := mux.Vars(r)["provider"]
+	reader, ok := a.accountReaders[handlerName(provider)].(externalAccountReader)
+	if !ok {
+		err = sErrors.NewClientError(http.StatusNotFound, "not found: unknown sign platform %s", provider)
+	}
+	return
+}
+
+// HandleJWKS serves the public keys to verify the session tokens.
+func (a AuthHandler) HandleJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	a.writer.JSON(w, http.StatusOK, a.tokens.JWKS())
 }
 
 // handleLogin performs a login process for the account requested.
@@ -102,7 +498,16 @@ func (a AuthHandler) HandleAuth(w http.ResponseWriter, r *http.Request) {
 //	@param account account.Account: account to login.
 //	@return id int: account authenticated id.
 func (a AuthHandler) handleLogin(account account.Account, w http.ResponseWriter, r *http.Request) (id int, err error) {
-	id, _, err = a.login(account)
+	id, err = a.login(account)
+	return
+}
+
+// handleSignUp performs a sign up process for the account requested.
+//
+//	@param account account.Account: account to register.
+//	@return id int: new account id.
+func (a AuthHandler) handleSignUp(account account.Account, w http.ResponseWriter, r *http.Request) (id int, err error) {
+	id, err = a.signUp(account)
 	return
 }
 
@@ -110,27 +515,106 @@ func (a AuthHandler) handleLogin(account account.Account, w http.ResponseWriter,
 //
 //	 @param accountR account.Account: account to login.
 //		@return id int: account authenticated id.
-//		@return session auth.Session: new session of the account.
 //		@return err error: login, validation or connection error
-func (a AuthHandler) login(accountR account.Account) (id int, session auth.Session, err error) {
+func (a AuthHandler) login(accountR account.Account) (id int, err error) {
 	log.Printf("Creating login session of %s %s", accountR.Nickname, accountR.Email)
 
-	accountR.Password, err = auth.HashPassword(accountR.Password)
+	id, hash, err := a.repository.GetCredentials(accountR)
+	if err != nil {
+		return
+	}
+
+	var match bool
+	if id != 0 && hash != "" {
+		match, err = auth.ComparePassword(hash, accountR.Password)
+		if err != nil {
+			return
+		}
+	} else {
+		// The missing accounts must not answer faster than the wrong passwords.
+		auth.ComparePassword(a.dummyHash, accountR.Password)
+	}
+
+	if !match {
+		id = 0
+		err = sErrors.NewClientError(http.StatusUnauthorized, "credentials don't match: invalid credentials of account %s %s", accountR.Nickname, accountR.Email)
+		return
+	}
+
+	if auth.NeedsRehash(hash, a.config.Server.HashCost) {
+		err = a.rehashPassword(id, accountR.Password)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// signUp performs the account registration process.
+//
+//	@param accountR account.Account: account to register.
+//	@return id int: new account id.
+//	@return err error: validation, duplicated account or connection error.
+func (a AuthHandler) signUp(accountR account.Account) (id int, err error) {
+	log.Printf("Signing up account %s %s", accountR.Nickname, accountR.Email)
+
+	accountR, err = account.New(accountR, a.config.Server.HashCost)
 	if err != nil {
 		return
 	}
 
-	id, err = a.repository.MatchCredentials(accountR)
+	id, err = a.repository.SignUp(accountR)
+	return
+}
+
+// createSession initializes and stores a new session for the account.
+//
+//	@param id int: account id.
+//	@param loggedWith handlerName: platform which the account has been sign.
+//	@return session auth.Session: new stored session.
+//	@return err error: session generation or connection error.
+func (a AuthHandler) createSession(id int, loggedWith handlerName) (session auth.Session, err error) {
+	// Signing in again cancels the deletion of a account in its grace period.
+	restored, err := a.repository.RestoreAccount(id)
 	if err != nil {
 		return
 	}
+	if restored {
+		log.Printf("Restored deleted account %d", id)
+	}
 
-	if id == 0 {
-		err = sErrors.NewClientError(http.StatusUnauthorized, "credentials don't match: invalid credentials of account %s %s", accountR.Nickname, accountR.Email)
+	session, err = auth.NewSession(id, string(loggedWith))
+	if err != nil {
+		return
+	}
+
+	err = a.sessions.SaveSession(session)
+	return
+}
+
+// newDummyHash generates the hash compared on the logins of missing accounts, with the configured cost.
+func newDummyHash(cost int) (hash string) {
+	hash, err := auth.HashPassword("dummy-password", cost)
+	if err != nil {
+		log.Println(err)
+	}
+	return
+}
+
+// rehashPassword encrypts the password again with the configured cost and stores it.
+//
+//	@param id int: account id.
+//	@param password string: plain password already verified.
+//	@return err error: encryptation or connection error.
+func (a AuthHandler) rehashPassword(id int, password string) (err error) {
+	log.Printf("Rehashing password of account %d", id)
+
+	hash, err := auth.HashPassword(password, a.config.Server.HashCost)
+	if err != nil {
 		return
 	}
 
-	session, err = auth.NewSession(id, "system")
+	err = a.repository.UpdatePassword(id, hash)
 	return
 }
 
@@ -214,32 +698,3 @@ func NewCheckNoAuthHandler(w handlers.ResponseWriter) func(http.Handler) http.Ha
 		}
 	}
 }
-
-// Temp code
-
-func JSON(r *http.Request, v interface{}) (err error) {
-	if r == nil {
-		err = fmt.Errorf("invalid request value: empty or nil *http.Request")
-		err = sErrors.NewClientError(http.StatusInternalServerError, sErrors.SERVER_ERROR_MESSAGE, err)
-		return
-	}
-	if !checkContentTypeJSON(r.Header) {
-		err = fmt.Errorf("invalid content type: Content-Type header is not application/json")
-		return
-	}
-
-	err = json.NewDecoder(r.Body).Decode(v)
-	if err != nil {
-		if errors.Is(err, io.EOF) {
-			err = fmt.Errorf("error checking body: empty body content (%s)", err)
-			return
-		}
-
-		err = fmt.Errorf("error decoding body: %s", err)
-	}
-	return
-}
-
-func checkContentTypeJSON(h http.Header) (match bool) {
-	return h.Get("Content-Type") == "application/json"
-}
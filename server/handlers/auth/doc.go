@@ -1,4 +1,4 @@
 // Package auth implements the client authentication for several platforms.
-// Available platform includes Facebook, Google and System (own-server) sign services.
+// Available platform includes Facebook, Google, OpenID Connect and System (own-server) sign services.
 
 package auth
@@ -0,0 +1,230 @@
+package auth
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coffemanfp/chat/account"
+	"github.com/coffemanfp/chat/auth"
+	sErrors "github.com/coffemanfp/chat/errors"
+	"github.com/coffemanfp/chat/mail"
+	"github.com/coffemanfp/chat/server/handlers"
+)
+
+// tokenRequest is the body of a email verification.
+type tokenRequest struct {
+	Token string `json:"token"`
+}
+
+// forgotPasswordRequest is the body of a password reset request.
+type forgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// resetPasswordRequest is the body of a password reset.
+type resetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// HandleSendVerification sends again the email verification email to the authenticated account.
+func (a AuthHandler) HandleSendVerification(w http.ResponseWriter, r *http.Request) {
+	err := a.sendVerification(handlers.GetAccountID(r))
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleVerifyEmail marks the email of the account of a verification token as verified.
+func (a AuthHandler) handleVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req tokenRequest
+	err := a.readJSON(r, &req)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	token, err := a.actionTokens.Parse(req.Token, auth.VerifyEmailPurpose, time.Now())
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	err = a.repository.VerifyEmail(token)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	a.writer.JSON(w, http.StatusOK, handlers.Hash{
+		"message": "email verified",
+	})
+	log.Println("Success verify-email")
+}
+
+// handleForgotPassword sends a password reset email to the account of the email provided.
+// Responds the same if there is no account with the email, to not reveal the registered emails:
+// the account lookup and the email are done in background, after the response.
+func (a AuthHandler) handleForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req forgotPasswordRequest
+	err := a.readJSON(r, &req)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	email := strings.TrimSpace(req.Email)
+	err = account.ValidateEmail(email)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	go func() {
+		err := a.sendResetPassword(email)
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleResetPassword replaces the password of the account of a reset token and closes all its sessions.
+func (a AuthHandler) handleResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req resetPasswordRequest
+	err := a.readJSON(r, &req)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	token, err := a.actionTokens.Parse(req.Token, auth.ResetPasswordPurpose, time.Now())
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	err = account.ValidatePassword(req.Password)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password, a.config.Server.HashCost)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+
+	err = a.repository.ResetPassword(token, hash)
+	if err != nil {
+		a.handleError(w, err)
+		return
+	}
+	a.hub.CloseAccount(token.AccountID)
+
+	a.writer.JSON(w, http.StatusOK, handlers.Hash{
+		"message": "password reset",
+	})
+	log.Println("Success reset-password")
+}
+
+// sendVerification sends a email verification email to the account, if its email is not verified yet.
+//
+//	@param id int: account id.
+//	@return err error: account without email, already verified, throttled request, token or delivery error.
+func (a AuthHandler) sendVerification(id int) (err error) {
+	email, verified, err := a.repository.GetAccountEmail(id)
+	if err != nil {
+		return
+	}
+	if email == "" {
+		err = sErrors.NewClientError(http.StatusConflict, "no email: account %d has no email", id)
+		return
+	}
+	if verified {
+		err = sErrors.NewClientError(http.StatusConflict, "already verified: email of account %d is already verified", id)
+		return
+	}
+
+	link, err := a.newActionLink(id, auth.VerifyEmailPurpose, auth.VerifyEmailLifetime, "verify-email")
+	if err != nil {
+		return
+	}
+
+	err = a.mailer.Send(mail.Message{
+		To:      email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Open the following link to verify your email:\n\n%s\n\nThe link expires in %s.\n",
+			link, auth.VerifyEmailLifetime),
+	})
+	return
+}
+
+// sendResetPassword sends a password reset email to the account of the email, if there is one.
+//
+//	@param email string: account email.
+//	@return err error: throttled request ClientError, token or delivery error.
+func (a AuthHandler) sendResetPassword(email string) (err error) {
+	id, err := a.repository.GetAccountIDByEmail(email)
+	if err != nil || id == 0 {
+		return
+	}
+
+	link, err := a.newActionLink(id, auth.ResetPasswordPurpose, auth.ResetPasswordLifetime, "reset-password")
+	if err != nil {
+		return
+	}
+
+	err = a.mailer.Send(mail.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Open the following link to choose a new password:\n\n%s\n\nThe link expires in %s. "+
+			"If you didn't request it, you can ignore this email.\n", link, auth.ResetPasswordLifetime),
+	})
+	return
+}
+
+// newActionLink generates and stores a new action token, and gets the client app link to use it.
+// A new token is refused while the account has a recent token of the same purpose, to not flood its inbox.
+func (a AuthHandler) newActionLink(id int, purpose auth.ActionPurpose, lifetime time.Duration, path string) (link string, err error) {
+	recent, err := a.repository.HasRecentActionToken(id, purpose, time.Now().Add(-auth.ActionTokenThrottle))
+	if err != nil {
+		return
+	}
+	if recent {
+		err = sErrors.NewClientError(http.StatusTooManyRequests, "too many requests: a %s email was sent to account %d recently", purpose, id)
+		return
+	}
+
+	token, err := a.actionTokens.New(id, purpose, lifetime)
+	if err != nil {
+		return
+	}
+
+	err = a.repository.SaveActionToken(token)
+	if err != nil {
+		return
+	}
+
+	link = strings.TrimSuffix(a.config.Mail.AppURL, "/") + "/" + path + "?" + url.Values{"token": {token.Token}}.Encode()
+	return
+}
+
+func (a AuthHandler) readJSON(r *http.Request, v interface{}) (err error) {
+	err = a.reader.JSON(r, v)
+	if err != nil {
+		if _, ok := err.(sErrors.ClientError); !ok {
+			err = sErrors.NewClientError(http.StatusBadRequest, "invalid body: %s", err)
+		}
+	}
+	return
+}
@@ -0,0 +1,312 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coffemanfp/chat/auth"
+	"github.com/coffemanfp/chat/config"
+	"github.com/coffemanfp/chat/database"
+	sErrors "github.com/coffemanfp/chat/errors"
+	"github.com/coffemanfp/chat/mail/memory"
+	"github.com/coffemanfp/chat/server/handlers"
+	"github.com/coffemanfp/chat/server/handlers/chat"
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	testAccountID = 1
+	testEmail     = "ana@example.com"
+)
+
+// tokenLinkRegex gets the token of the link of a email.
+var tokenLinkRegex = regexp.MustCompile(`\?token=(\S+)`)
+
+// fakeActionToken is a action token stored by fakeAuthRepository.
+type fakeActionToken struct {
+	accountID int
+	purpose   auth.ActionPurpose
+	createdAt time.Time
+	expiresAt time.Time
+	used      bool
+}
+
+// fakeAuthRepository keeps a single account in memory, with the action tokens semantics of the database.
+type fakeAuthRepository struct {
+	database.AuthRepository
+
+	mu       sync.Mutex
+	verified bool
+	password string
+	tokens   map[string]*fakeActionToken
+
+	// sessions keeps the active state of the account sessions.
+	sessions map[string]bool
+}
+
+func newFakeAuthRepository() *fakeAuthRepository {
+	return &fakeAuthRepository{
+		tokens:   make(map[string]*fakeActionToken),
+		sessions: map[string]bool{"session-1": true, "session-2": true},
+	}
+}
+
+func (f *fakeAuthRepository) GetAccountEmail(id int) (email string, verified bool, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if id != testAccountID {
+		err = sErrors.NewClientError(http.StatusNotFound, "not found: account %d not found", id)
+		return
+	}
+	return testEmail, f.verified, nil
+}
+
+func (f *fakeAuthRepository) GetAccountIDByEmail(email string) (id int, err error) {
+	if email == testEmail {
+		id = testAccountID
+	}
+	return
+}
+
+func (f *fakeAuthRepository) SaveActionToken(token auth.ActionToken) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.tokens[auth.HashToken(token.ID)] = &fakeActionToken{
+		accountID: token.AccountID,
+		purpose:   token.Purpose,
+		createdAt: time.Now(),
+		expiresAt: token.ExpiresAt,
+	}
+	return
+}
+
+func (f *fakeAuthRepository) HasRecentActionToken(accountID int, purpose auth.ActionPurpose, since time.Time) (exists bool, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, t := range f.tokens {
+		if t.accountID == accountID && t.purpose == purpose && t.createdAt.After(since) && !t.used && t.expiresAt.After(time.Now()) {
+			exists = true
+		}
+	}
+	return
+}
+
+func (f *fakeAuthRepository) VerifyEmail(token auth.ActionToken) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err = f.useActionToken(token)
+	if err != nil {
+		return
+	}
+	f.verified = true
+	return
+}
+
+func (f *fakeAuthRepository) ResetPassword(token auth.ActionToken, hash string) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	err = f.useActionToken(token)
+	if err != nil {
+		return
+	}
+	f.password = hash
+
+	for _, t := range f.tokens {
+		if t.accountID == token.AccountID && t.purpose == token.Purpose {
+			t.used = true
+		}
+	}
+	for id := range f.sessions {
+		f.sessions[id] = false
+	}
+	return
+}
+
+func (f *fakeAuthRepository) useActionToken(token auth.ActionToken) (err error) {
+	t, ok := f.tokens[auth.HashToken(token.ID)]
+	if !ok || t.used || t.accountID != token.AccountID || t.purpose != token.Purpose || !t.expiresAt.After(time.Now()) {
+		err = auth.NewInvalidActionTokenError()
+		return
+	}
+	t.used = true
+	return
+}
+
+// activeSessions counts the active sessions of the account.
+func (f *fakeAuthRepository) activeSessions() (n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, active := range f.sessions {
+		if active {
+			n++
+		}
+	}
+	return
+}
+
+func newTestAuthHandler(repo database.AuthRepository, sessions database.SessionRepository, mailer *memory.Mailer) AuthHandler {
+	var conf config.ConfigInfo
+	conf.Server.SecretKey = "secret"
+	conf.Server.HashCost = bcrypt.MinCost
+	conf.Mail.AppURL = "http://localhost:3000/"
+	conf.Server.Token.RefreshLifetime = time.Hour
+
+	tokens := auth.NewJWTManager(conf.Server.SecretKey, auth.KeySet{}, "chat", "chat", time.Minute)
+	return NewAuthHandler(repo, sessions, tokens, chat.NewHub(), mailer,
+		handlers.NewRequestReaderImpl(), handlers.NewResponseWriterImpl(), conf)
+}
+
+// doAuthAction performs a auth action with the body provided and gets the response status.
+func doAuthAction(h AuthHandler, action, body string) int {
+	r := httptest.NewRequest(http.MethodPost, "/auth/"+action, bytes.NewBufferString(body))
+	r.Header.Set("Content-Type", "application/json")
+	r = mux.SetURLVars(r, map[string]string{"action": action})
+
+	w := httptest.NewRecorder()
+	h.HandleAuth(w, r)
+	return w.Code
+}
+
+// sentToken gets the token of the last email sent to the account, waiting for the background emails.
+func sentToken(t *testing.T, mailer *memory.Mailer) string {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		message, ok := mailer.Last(testEmail)
+		if ok {
+			m := tokenLinkRegex.FindStringSubmatch(message.Body)
+			if m == nil {
+				t.Fatalf("missing token link on email body %q", message.Body)
+			}
+			token, err := url.QueryUnescape(m[1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			return token
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("email not sent")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	repo := newFakeAuthRepository()
+	mailer := memory.NewMailer()
+	h := newTestAuthHandler(repo, nil, mailer)
+
+	sendVerification := func() int {
+		r := handlers.WithAuth(httptest.NewRequest(http.MethodPost, "/auth/verification", nil), testAccountID, "session-1")
+		w := httptest.NewRecorder()
+		h.HandleSendVerification(w, r)
+		return w.Code
+	}
+
+	if code := sendVerification(); code != http.StatusAccepted {
+		t.Fatalf("send verification status = %d, want %d", code, http.StatusAccepted)
+	}
+	token := sentToken(t, mailer)
+
+	if code := sendVerification(); code != http.StatusTooManyRequests {
+		t.Errorf("repeated send verification status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if n := len(mailer.Messages()); n != 1 {
+		t.Errorf("sent emails = %d, want 1", n)
+	}
+
+	if code := doAuthAction(h, "reset-password", `{"token":"`+token+`","password":"new-password"}`); code != http.StatusBadRequest {
+		t.Errorf("reset password with verification token status = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	if code := doAuthAction(h, "verify-email", `{"token":"`+token+`"}`); code != http.StatusOK {
+		t.Fatalf("verify email status = %d, want %d", code, http.StatusOK)
+	}
+	if !repo.verified {
+		t.Error("email not verified")
+	}
+
+	if code := doAuthAction(h, "verify-email", `{"token":"`+token+`"}`); code != http.StatusBadRequest {
+		t.Errorf("verify email with used token status = %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := sendVerification(); code != http.StatusConflict {
+		t.Errorf("send verification of verified email status = %d, want %d", code, http.StatusConflict)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	repo := newFakeAuthRepository()
+	mailer := memory.NewMailer()
+	h := newTestAuthHandler(repo, nil, mailer)
+
+	if code := doAuthAction(h, "forgot-password", `{"email":"not an email"}`); code != http.StatusBadRequest {
+		t.Errorf("forgot password with invalid email status = %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := doAuthAction(h, "forgot-password", `{"email":"`+testEmail+`"}`); code != http.StatusAccepted {
+		t.Fatalf("forgot password status = %d, want %d", code, http.StatusAccepted)
+	}
+	token := sentToken(t, mailer)
+
+	if code := doAuthAction(h, "verify-email", `{"token":"`+token+`"}`); code != http.StatusBadRequest {
+		t.Errorf("verify email with reset token status = %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := doAuthAction(h, "reset-password", `{"token":"`+token+`","password":"short"}`); code != http.StatusBadRequest {
+		t.Errorf("reset password with invalid password status = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	if code := doAuthAction(h, "reset-password", `{"token":"`+token+`","password":"new-password"}`); code != http.StatusOK {
+		t.Fatalf("reset password status = %d, want %d", code, http.StatusOK)
+	}
+	match, err := auth.ComparePassword(repo.password, "new-password")
+	if err != nil || !match {
+		t.Errorf("password not replaced: match %t, error %v", match, err)
+	}
+	if n := repo.activeSessions(); n != 0 {
+		t.Errorf("active sessions = %d, want none", n)
+	}
+
+	if code := doAuthAction(h, "reset-password", `{"token":"`+token+`","password":"other-password"}`); code != http.StatusBadRequest {
+		t.Errorf("reset password with used token status = %d, want %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestSendResetPassword(t *testing.T) {
+	repo := newFakeAuthRepository()
+	mailer := memory.NewMailer()
+	h := newTestAuthHandler(repo, nil, mailer)
+
+	err := h.sendResetPassword("other@example.com")
+	if err != nil {
+		t.Fatalf("sendResetPassword of unknown email failed: %s", err)
+	}
+	if n := len(mailer.Messages()); n != 0 {
+		t.Fatalf("sent emails = %d, want none", n)
+	}
+
+	err = h.sendResetPassword(testEmail)
+	if err != nil {
+		t.Fatalf("sendResetPassword failed: %s", err)
+	}
+
+	err = h.sendResetPassword(testEmail)
+	var cErr sErrors.ClientError
+	if !errors.As(err, &cErr) || cErr.HTTPCode() != http.StatusTooManyRequests {
+		t.Errorf("repeated sendResetPassword error = %v, want too many requests client error", err)
+	}
+	if n := len(mailer.Messages()); n != 1 {
+		t.Errorf("sent emails = %d, want 1", n)
+	}
+}
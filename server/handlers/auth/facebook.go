@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/coffemanfp/chat/config"
+)
+
+const facebookHandlerName handlerName = "facebook"
+
+func newFacebookAccountReader(props config.OAuthProperties) oauthAccountReader {
+	config := newOAuth2Config(props.ClientID, props.ClientSecret, props.RedirectURIS, props.Scopes, props.Endpoint)
+	return oauthAccountReader{
+		name:     facebookHandlerName,
+		config:   config,
+		identify: newUserInfoIdentifier(facebookHandlerName, config, props.UserInfoURL, parseFacebookUserInfo),
+	}
+}
+
+func parseFacebookUserInfo(raw []byte) (identity identity, err error) {
+	var info struct {
+		ID        string `json:"id"`
+		Email     string `json:"email"`
+		Name      string `json:"name"`
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+	}
+	err = json.Unmarshal(raw, &info)
+	if err != nil {
+		err = fmt.Errorf("failed to decode facebook user info: %s", err)
+		return
+	}
+
+	// Facebook doesn't tell if the email is verified, so it's never used to link the accounts.
+	identity.subject = info.ID
+	identity.emailVerified = false
+	identity.account.Email = info.Email
+	identity.account.Name = info.FirstName
+	identity.account.LastName = info.LastName
+	if identity.account.Name == "" {
+		identity.account.Name = info.Name
+	}
+	return
+}
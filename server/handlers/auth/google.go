@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/coffemanfp/chat/config"
+)
+
+const googleHandlerName handlerName = "google"
+
+func newGoogleAccountReader(props config.OAuthProperties) oauthAccountReader {
+	config := newOAuth2Config(props.ClientID, props.ClientSecret, props.RedirectURIS, props.Scopes, props.Endpoint)
+	return oauthAccountReader{
+		name:     googleHandlerName,
+		config:   config,
+		identify: newUserInfoIdentifier(googleHandlerName, config, props.UserInfoURL, parseGoogleUserInfo),
+	}
+}
+
+func parseGoogleUserInfo(raw []byte) (identity identity, err error) {
+	var info struct {
+		Subject       string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+		GivenName     string `json:"given_name"`
+		FamilyName    string `json:"family_name"`
+	}
+	err = json.Unmarshal(raw, &info)
+	if err != nil {
+		err = fmt.Errorf("failed to decode google user info: %s", err)
+		return
+	}
+
+	identity.subject = info.Subject
+	identity.emailVerified = info.EmailVerified
+	identity.account.Email = info.Email
+	identity.account.Name = info.GivenName
+	identity.account.LastName = info.FamilyName
+	if identity.account.Name == "" {
+		identity.account.Name = info.Name
+	}
+	return
+}
@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coffemanfp/chat/auth"
+	sErrors "github.com/coffemanfp/chat/errors"
+	"golang.org/x/oauth2"
+)
+
+// oauthStateCookie is the cookie which keeps the state, the PKCE code verifier and the nonce
+// between the start and callback calls.
+const oauthStateCookie = "oauth_state"
+
+// oauthLinkCookie is the cookie which keeps the link token of the identity link flows between the start and
+// callback calls.
+const oauthLinkCookie = "oauth_link"
+
+// oauthStateLifetime is the time limit to complete the sign flow after started.
+const oauthStateLifetime = 10 * time.Minute
+
+// oauthAccountReader is a accountReader for the OAuth2 sign platforms.
+// Performs the authorization code flow with PKCE.
+type oauthAccountReader struct {
+	name   handlerName
+	config oauth2.Config
+
+	// identify reads the account identity with the platform token.
+	//  The nonce is the value sent on the authorization request, used by the OpenID Connect platforms.
+	identify func(ctx context.Context, token *oauth2.Token, nonce string) (identity, error)
+}
+
+func (o oauthAccountReader) start(w http.ResponseWriter, r *http.Request) (err error) {
+	state, err := auth.RandomToken(16)
+	if err != nil {
+		return
+	}
+
+	verifier, err := auth.NewPKCEVerifier()
+	if err != nil {
+		return
+	}
+
+	nonce, err := auth.RandomToken(16)
+	if err != nil {
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    strings.Join([]string{state, verifier, nonce}, "."),
+		Path:     r.URL.Path[:strings.LastIndex(r.URL.Path, "/")],
+		MaxAge:   int(oauthStateLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	authURL := o.config.AuthCodeURL(state,
+		oauth2.SetAuthURLParam("code_challenge", auth.PKCEChallenge(verifier)),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+		oauth2.SetAuthURLParam("nonce", nonce),
+	)
+	http.Redirect(w, r, authURL, http.StatusFound)
+	return
+}
+
+func (o oauthAccountReader) read(w http.ResponseWriter, r *http.Request) (identity identity, err error) {
+	verifier, nonce, err := o.checkState(w, r)
+	if err != nil {
+		return
+	}
+
+	token, err := o.exchange(r.Context(), r, verifier)
+	if err != nil {
+		return
+	}
+
+	identity, err = o.identify(r.Context(), token, nonce)
+	if err != nil {
+		return
+	}
+	if identity.subject == "" {
+		err = fmt.Errorf("failed to read %s identity: missing subject", o.name)
+	}
+	return
+}
+
+// checkState compares the state of the callback with the state cookie, removing it.
+// Returns the PKCE code verifier and the nonce of the state cookie.
+func (o oauthAccountReader) checkState(w http.ResponseWriter, r *http.Request) (verifier, nonce string, err error) {
+	cookie, err := r.Cookie(oauthStateCookie)
+	if err != nil {
+		err = sErrors.NewClientError(http.StatusBadRequest, "invalid state: missing %s sign state", o.name)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   oauthStateCookie,
+		Path:   r.URL.Path[:strings.LastIndex(r.URL.Path, "/")],
+		MaxAge: -1,
+	})
+
+	parts := strings.Split(cookie.Value, ".")
+	state := r.URL.Query().Get("state")
+	if len(parts) != 3 || state == "" || subtle.ConstantTimeCompare([]byte(parts[0]), []byte(state)) != 1 {
+		err = sErrors.NewClientError(http.StatusBadRequest, "invalid state: %s sign state doesn't match", o.name)
+		return
+	}
+
+	verifier, nonce = parts[1], parts[2]
+	return
+}
+
+func (o oauthAccountReader) exchange(ctx context.Context, r *http.Request, verifier string) (token *oauth2.Token, err error) {
+	q := r.URL.Query()
+	if errCode := q.Get("error"); errCode != "" {
+		err = sErrors.NewClientError(http.StatusUnauthorized, "sign denied: %s sign failed with %s", o.name, errCode)
+		return
+	}
+
+	code := q.Get("code")
+	if code == "" {
+		err = sErrors.NewClientError(http.StatusBadRequest, "invalid code: missing %s authorization code", o.name)
+		return
+	}
+
+	token, err = o.config.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", verifier))
+	if err != nil {
+		err = sErrors.NewClientError(http.StatusUnauthorized, "invalid code: failed to exchange %s authorization code", o.name)
+	}
+	return
+}
+
+// newUserInfoIdentifier initializes a identify function which reads the account identity of the platform user info URL.
+//
+//	@param name handlerName: platform name.
+//	@param config oauth2.Config: platform OAuth2 config.
+//	@param userInfoURL string: platform URL to get the account info with the access token.
+//	@param parse func(raw []byte) (identity, error): reader of the user info response body.
+func newUserInfoIdentifier(name handlerName, config oauth2.Config, userInfoURL string, parse func(raw []byte) (identity, error)) func(context.Context, *oauth2.Token, string) (identity, error) {
+	return func(ctx context.Context, token *oauth2.Token, _ string) (identity identity, err error) {
+		res, err := config.Client(ctx, token).Get(userInfoURL)
+		if err != nil {
+			err = fmt.Errorf("failed to get %s user info: %s", name, err)
+			return
+		}
+		defer res.Body.Close()
+
+		if res.StatusCode != http.StatusOK {
+			err = fmt.Errorf("failed to get %s user info: unexpected status %d", name, res.StatusCode)
+			return
+		}
+
+		raw, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			err = fmt.Errorf("failed to read %s user info: %s", name, err)
+			return
+		}
+
+		identity, err = parse(raw)
+		return
+	}
+}
+
+// newOAuth2Config initializes the oauth2.Config of the platform properties.
+// The first redirect URI is used as the callback URL.
+func newOAuth2Config(clientID, clientSecret string, redirectURIS, scopes []string, endpoint oauth2.Endpoint) (c oauth2.Config) {
+	c = oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       scopes,
+		Endpoint:     endpoint,
+	}
+	if len(redirectURIS) > 0 {
+		c.RedirectURL = redirectURIS[0]
+	}
+	return
+}
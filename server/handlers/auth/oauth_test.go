@@ -0,0 +1,423 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/coffemanfp/chat/account"
+	"github.com/coffemanfp/chat/auth"
+	"github.com/coffemanfp/chat/config"
+	"github.com/coffemanfp/chat/database"
+	"github.com/coffemanfp/chat/mail/memory"
+	"github.com/coffemanfp/chat/server/handlers"
+	"github.com/gorilla/mux"
+	"golang.org/x/oauth2"
+)
+
+const stubAccessToken = "stub-access-token"
+
+// stubOAuthServer is a OAuth2 platform which checks the PKCE code verifier and serves the user info of
+// the Google and Facebook platforms.
+type stubOAuthServer struct {
+	*httptest.Server
+
+	mu sync.Mutex
+
+	// challenge is the PKCE code challenge sent on the authorization request.
+	challenge string
+}
+
+func newStubOAuthServer(t *testing.T) (s *stubOAuthServer) {
+	s = &stubOAuthServer{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		s.mu.Lock()
+		challenge := s.challenge
+		s.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if r.Form.Get("code") != stubCode || auth.PKCEChallenge(r.Form.Get("code_verifier")) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": stubAccessToken,
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	})
+	mux.HandleFunc("/google/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+stubAccessToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"sub":            "google-subject",
+			"email":          "ana@example.com",
+			"email_verified": true,
+			"given_name":     "Ana",
+			"family_name":    "Diaz",
+		})
+	})
+	mux.HandleFunc("/facebook/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+stubAccessToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":         "facebook-subject",
+			"email":      "ana@example.com",
+			"first_name": "Ana",
+			"last_name":  "Diaz",
+		})
+	})
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return
+}
+
+// props gets the platform properties of the stub server.
+func (s *stubOAuthServer) props(provider handlerName) config.OAuthProperties {
+	return config.OAuthProperties{
+		ClientID:     stubClientID,
+		ClientSecret: "stub-secret",
+		RedirectURIS: []string{"http://localhost/api/v1/auth/" + string(provider) + "/callback"},
+		Scopes:       []string{"email"},
+		Endpoint: oauth2.Endpoint{
+			AuthURL:   s.URL + "/authorize",
+			TokenURL:  s.URL + "/token",
+			AuthStyle: oauth2.AuthStyleInParams,
+		},
+		UserInfoURL: s.URL + "/" + string(provider) + "/userinfo",
+	}
+}
+
+// newStubReaders initializes the Google and Facebook readers of the stub server.
+func (s *stubOAuthServer) newStubReaders() map[handlerName]externalAccountReader {
+	return map[handlerName]externalAccountReader{
+		googleHandlerName:   newGoogleAccountReader(s.props(googleHandlerName)),
+		facebookHandlerName: newFacebookAccountReader(s.props(facebookHandlerName)),
+	}
+}
+
+// startExternal performs a start call and gets the cookies and the authorization request params.
+// The stub server takes the code challenge of the authorization request.
+func (s *stubOAuthServer) startExternal(t *testing.T, start func(w http.ResponseWriter, r *http.Request), target string) (cookies []*http.Cookie, params url.Values) {
+	w := httptest.NewRecorder()
+	start(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+	res := w.Result()
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("start status = %d, want %d", res.StatusCode, http.StatusFound)
+	}
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	params = location.Query()
+
+	s.mu.Lock()
+	s.challenge = params.Get("code_challenge")
+	s.mu.Unlock()
+	return res.Cookies(), params
+}
+
+// callbackRequest initializes the callback request of the provider with the cookies provided.
+func callbackRequest(provider handlerName, query url.Values, cookies []*http.Cookie) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/auth/"+string(provider)+"/callback?"+query.Encode(), nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	return mux.SetURLVars(r, map[string]string{"provider": string(provider)})
+}
+
+// replaceStateCookie modifies the value of the state cookie.
+func replaceStateCookie(cookies []*http.Cookie, modify func(parts []string)) []*http.Cookie {
+	replaced := make([]*http.Cookie, 0, len(cookies))
+	for _, c := range cookies {
+		if c.Name == oauthStateCookie {
+			parts := strings.Split(c.Value, ".")
+			modify(parts)
+			c = &http.Cookie{Name: c.Name, Value: strings.Join(parts, ".")}
+		}
+		replaced = append(replaced, c)
+	}
+	return replaced
+}
+
+func TestOAuthAccountReaders(t *testing.T) {
+	stub := newStubOAuthServer(t)
+	readers := stub.newStubReaders()
+
+	tests := []struct {
+		name     string
+		provider handlerName
+
+		// cookies modifies the cookies of the start call.
+		cookies func(cookies []*http.Cookie) []*http.Cookie
+
+		// query modifies the callback params.
+		query func(q url.Values)
+
+		wantErr           bool
+		wantCode          int
+		wantSubject       string
+		wantEmailVerified bool
+	}{
+		{name: "google", provider: googleHandlerName, wantSubject: "google-subject", wantEmailVerified: true},
+		{name: "facebook email not verified", provider: facebookHandlerName, wantSubject: "facebook-subject"},
+		{
+			name:     "wrong PKCE verifier",
+			provider: googleHandlerName,
+			cookies: func(cookies []*http.Cookie) []*http.Cookie {
+				return replaceStateCookie(cookies, func(parts []string) { parts[1] = "other-verifier-other-verifier-other-verifier" })
+			},
+			wantErr:  true,
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "state cookie mismatch",
+			provider: facebookHandlerName,
+			cookies: func(cookies []*http.Cookie) []*http.Cookie {
+				return replaceStateCookie(cookies, func(parts []string) { parts[0] = "other-state" })
+			},
+			wantErr:  true,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "missing state cookie",
+			provider: googleHandlerName,
+			cookies:  func(cookies []*http.Cookie) []*http.Cookie { return nil },
+			wantErr:  true,
+			wantCode: http.StatusBadRequest,
+		},
+		{name: "wrong state", provider: googleHandlerName, query: func(q url.Values) { q.Set("state", "other-state") }, wantErr: true, wantCode: http.StatusBadRequest},
+		{name: "sign denied", provider: facebookHandlerName, query: func(q url.Values) { q.Set("error", "access_denied") }, wantErr: true, wantCode: http.StatusUnauthorized},
+		{name: "wrong code", provider: googleHandlerName, query: func(q url.Values) { q.Set("code", "other-code") }, wantErr: true, wantCode: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := readers[tt.provider]
+			start := func(w http.ResponseWriter, r *http.Request) {
+				err := reader.start(w, r)
+				if err != nil {
+					t.Fatalf("start failed: %s", err)
+				}
+			}
+			cookies, params := stub.startExternal(t, start, "/api/v1/auth/"+string(tt.provider)+"/start")
+
+			if params.Get("code_challenge_method") != "S256" || params.Get("code_challenge") == "" {
+				t.Fatalf("authorization params = %v, want S256 code challenge", params)
+			}
+			if tt.cookies != nil {
+				cookies = tt.cookies(cookies)
+			}
+			query := url.Values{
+				"state": {params.Get("state")},
+				"code":  {stubCode},
+			}
+			if tt.query != nil {
+				tt.query(query)
+			}
+
+			identity, err := reader.read(httptest.NewRecorder(), callbackRequest(tt.provider, query, cookies))
+			if tt.wantErr {
+				assertClientError(t, "read", err, tt.wantCode)
+				return
+			}
+			if err != nil {
+				t.Fatalf("read failed: %s", err)
+			}
+
+			if identity.subject != tt.wantSubject {
+				t.Errorf("subject = %q, want %q", identity.subject, tt.wantSubject)
+			}
+			if identity.emailVerified != tt.wantEmailVerified {
+				t.Errorf("emailVerified = %t, want %t", identity.emailVerified, tt.wantEmailVerified)
+			}
+			if identity.account.Email != "ana@example.com" || identity.account.Name != "Ana" || identity.account.LastName != "Diaz" {
+				t.Errorf("account = %+v, want ana@example.com Ana Diaz", identity.account)
+			}
+		})
+	}
+}
+
+// fakeIdentityCall is a call to link a external platform identity.
+type fakeIdentityCall struct {
+	accountID     int
+	provider      string
+	subject       string
+	emailVerified bool
+}
+
+// fakeIdentityRepository records the sign and link calls of the external platform identities.
+type fakeIdentityRepository struct {
+	fakeRestoreRepository
+
+	mu     sync.Mutex
+	signs  []fakeIdentityCall
+	links  []fakeIdentityCall
+	tokens map[string]bool
+}
+
+func newFakeIdentityRepository() *fakeIdentityRepository {
+	return &fakeIdentityRepository{tokens: make(map[string]bool)}
+}
+
+// signedAccountID is the account of the identities signed without a link.
+const signedAccountID = 7
+
+func (f *fakeIdentityRepository) SignWithIdentity(account account.Account, provider, subject string, emailVerified bool) (id int, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.signs = append(f.signs, fakeIdentityCall{accountID: signedAccountID, provider: provider, subject: subject, emailVerified: emailVerified})
+	return signedAccountID, nil
+}
+
+func (f *fakeIdentityRepository) SaveActionToken(token auth.ActionToken) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.tokens[auth.HashToken(token.ID)] = false
+	return
+}
+
+func (f *fakeIdentityRepository) LinkIdentity(token auth.ActionToken, provider, subject string) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	used, ok := f.tokens[auth.HashToken(token.ID)]
+	if !ok || used {
+		err = auth.NewInvalidActionTokenError()
+		return
+	}
+	f.tokens[auth.HashToken(token.ID)] = true
+	f.links = append(f.links, fakeIdentityCall{accountID: token.AccountID, provider: provider, subject: subject})
+	return
+}
+
+// newTestExternalHandler initializes a AuthHandler with the Google and Facebook readers of the stub server.
+func newTestExternalHandler(stub *stubOAuthServer, repo database.AuthRepository) AuthHandler {
+	h := newTestAuthHandler(repo, newFakeSessionRepository(), memory.NewMailer())
+	h.config.Server.PublicURL = "http://localhost"
+	for name, reader := range stub.newStubReaders() {
+		h.accountReaders[name] = reader
+	}
+	return h
+}
+
+// externalSign performs the start and callback calls of a external sign and gets the callback response status.
+func externalSign(t *testing.T, stub *stubOAuthServer, h AuthHandler, provider handlerName, startTarget string) int {
+	start := func(w http.ResponseWriter, r *http.Request) {
+		h.HandleExternalStart(w, mux.SetURLVars(r, map[string]string{"provider": string(provider)}))
+	}
+	cookies, params := stub.startExternal(t, start, startTarget)
+
+	w := httptest.NewRecorder()
+	h.HandleExternalCallback(w, callbackRequest(provider, url.Values{
+		"state": {params.Get("state")},
+		"code":  {stubCode},
+	}, cookies))
+	return w.Code
+}
+
+func TestExternalSignLinking(t *testing.T) {
+	stub := newStubOAuthServer(t)
+
+	tests := []struct {
+		name     string
+		provider handlerName
+		want     fakeIdentityCall
+	}{
+		{name: "google verified email", provider: googleHandlerName, want: fakeIdentityCall{signedAccountID, "google", "google-subject", true}},
+		{name: "facebook never links by email", provider: facebookHandlerName, want: fakeIdentityCall{signedAccountID, "facebook", "facebook-subject", false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeIdentityRepository()
+			h := newTestExternalHandler(stub, repo)
+
+			code := externalSign(t, stub, h, tt.provider, "/api/v1/auth/"+string(tt.provider)+"/start")
+			if code != http.StatusOK {
+				t.Fatalf("callback status = %d, want %d", code, http.StatusOK)
+			}
+			if len(repo.signs) != 1 || repo.signs[0] != tt.want {
+				t.Errorf("signs = %+v, want %+v", repo.signs, tt.want)
+			}
+			if len(repo.links) != 0 {
+				t.Errorf("links = %+v, want none", repo.links)
+			}
+		})
+	}
+}
+
+func TestExternalLink(t *testing.T) {
+	const linkedAccountID = 3
+
+	stub := newStubOAuthServer(t)
+	repo := newFakeIdentityRepository()
+	h := newTestExternalHandler(stub, repo)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/auth/google/link", nil)
+	r = mux.SetURLVars(handlers.WithAuth(r, linkedAccountID, "session-1"), map[string]string{"provider": "google"})
+	w := httptest.NewRecorder()
+	h.HandleLinkStart(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("link start status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var body struct {
+		URL string `json:"url"`
+	}
+	err := json.NewDecoder(w.Body).Decode(&body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(body.URL, "http://localhost/api/v1/auth/google/start?link=") {
+		t.Fatalf("link URL = %s, want the google start URL", body.URL)
+	}
+	startTarget := strings.TrimPrefix(body.URL, "http://localhost")
+
+	code := externalSign(t, stub, h, googleHandlerName, startTarget)
+	if code != http.StatusOK {
+		t.Fatalf("link callback status = %d, want %d", code, http.StatusOK)
+	}
+	want := fakeIdentityCall{accountID: linkedAccountID, provider: "google", subject: "google-subject"}
+	if len(repo.links) != 1 || repo.links[0] != want {
+		t.Errorf("links = %+v, want %+v", repo.links, want)
+	}
+	if len(repo.signs) != 0 {
+		t.Errorf("signs = %+v, want none", repo.signs)
+	}
+
+	// The link token is used once.
+	code = externalSign(t, stub, h, googleHandlerName, startTarget)
+	if code != http.StatusBadRequest {
+		t.Errorf("reused link callback status = %d, want %d", code, http.StatusBadRequest)
+	}
+
+	w = httptest.NewRecorder()
+	h.HandleExternalStart(w, mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/v1/auth/google/start?link=invalid", nil), map[string]string{"provider": "google"}))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("start with invalid link status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	r = mux.SetURLVars(handlers.WithAuth(httptest.NewRequest(http.MethodPost, "/api/v1/auth/unknown/link", nil), linkedAccountID, "session-1"), map[string]string{"provider": "unknown"})
+	w = httptest.NewRecorder()
+	h.HandleLinkStart(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("link start of unknown platform status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,297 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coffemanfp/chat/account"
+	"github.com/coffemanfp/chat/auth"
+	"github.com/coffemanfp/chat/config"
+	sErrors "github.com/coffemanfp/chat/errors"
+	"github.com/golang-jwt/jwt"
+	"golang.org/x/oauth2"
+)
+
+// oidcKeysRefreshInterval is the min time between the JWKS fetches of a platform,
+// avoiding to fetch it on each token signed with a unknown key.
+const oidcKeysRefreshInterval = time.Minute
+
+// oidcAccountReader is a accountReader for the OpenID Connect sign platforms.
+// The account identity is read from the ID token claims.
+type oidcAccountReader struct {
+	provider *oidcProvider
+}
+
+func newOIDCAccountReader(props config.OIDCProperties) oidcAccountReader {
+	return oidcAccountReader{
+		provider: &oidcProvider{
+			name:   handlerName(props.Name),
+			props:  props,
+			client: &http.Client{Timeout: 10 * time.Second},
+		},
+	}
+}
+
+func (o oidcAccountReader) start(w http.ResponseWriter, r *http.Request) (err error) {
+	reader, err := o.provider.oauthReader(r.Context())
+	if err != nil {
+		return
+	}
+	return reader.start(w, r)
+}
+
+func (o oidcAccountReader) read(w http.ResponseWriter, r *http.Request) (identity identity, err error) {
+	reader, err := o.provider.oauthReader(r.Context())
+	if err != nil {
+		return
+	}
+	return reader.read(w, r)
+}
+
+// oidcDiscovery is the OpenID Connect discovery document of a platform.
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// oidcProvider keeps the discovered endpoints and the signing keys of a OpenID Connect platform.
+type oidcProvider struct {
+	name   handlerName
+	props  config.OIDCProperties
+	client *http.Client
+
+	mu            sync.Mutex
+	discovery     *oidcDiscovery
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+// oauthReader initializes the oauthAccountReader with the discovered endpoints of the platform.
+func (p *oidcProvider) oauthReader(ctx context.Context) (reader oauthAccountReader, err error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return
+	}
+
+	reader = oauthAccountReader{
+		name: p.name,
+		config: newOAuth2Config(p.props.ClientID, p.props.ClientSecret, p.props.RedirectURIS, p.props.Scopes, oauth2.Endpoint{
+			AuthURL:  discovery.AuthorizationEndpoint,
+			TokenURL: discovery.TokenEndpoint,
+		}),
+		identify: p.identify,
+	}
+	return
+}
+
+// discover gets the discovery document of the platform, fetching it on the first call.
+func (p *oidcProvider) discover(ctx context.Context) (discovery oidcDiscovery, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		discovery = *p.discovery
+		return
+	}
+
+	issuer := strings.TrimSuffix(p.props.IssuerURL, "/")
+	err = p.getJSON(ctx, issuer+"/.well-known/openid-configuration", &discovery)
+	if err != nil {
+		return
+	}
+
+	if strings.TrimSuffix(discovery.Issuer, "/") != issuer {
+		err = fmt.Errorf("invalid %s discovery: issuer %s doesn't match with %s", p.name, discovery.Issuer, p.props.IssuerURL)
+		return
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		err = fmt.Errorf("invalid %s discovery: missing endpoints", p.name)
+		return
+	}
+
+	p.discovery = &discovery
+	return
+}
+
+// identify verifies the ID token of the platform token and reads the account identity of its claims.
+func (p *oidcProvider) identify(ctx context.Context, token *oauth2.Token, nonce string) (identity identity, err error) {
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		err = fmt.Errorf("failed to read %s identity: missing id token", p.name)
+		return
+	}
+
+	claims, err := p.verifyIDToken(ctx, rawIDToken, nonce)
+	if err != nil {
+		return
+	}
+
+	identity.subject = stringClaim(claims, "sub")
+	identity.emailVerified = boolClaim(claims, "email_verified")
+	identity.account.Email = stringClaim(claims, p.props.Claims.Email)
+	identity.account.Name = stringClaim(claims, p.props.Claims.Name)
+	identity.account.LastName = stringClaim(claims, p.props.Claims.LastName)
+	if identity.account.Name == "" {
+		identity.account.Name = stringClaim(claims, "name")
+	}
+
+	// The platform nickname is just kept if it's valid for the system, and the repository
+	// leaves it empty if another account already took it.
+	nickname := strings.ToLower(stringClaim(claims, p.props.Claims.Nickname))
+	if account.ValidateNickname(nickname) == nil {
+		identity.account.Nickname = nickname
+	}
+
+	err = p.checkEmailDomain(identity)
+	return
+}
+
+// verifyIDToken verifies the signature, issuer, audience, expiration and nonce of the ID token.
+func (p *oidcProvider) verifyIDToken(ctx context.Context, rawIDToken, nonce string) (claims jwt.MapClaims, err error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return
+	}
+
+	_, err = jwt.ParseWithClaims(rawIDToken, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, discovery.JWKSURI, kid)
+	})
+	if err != nil {
+		err = sErrors.NewClientError(http.StatusUnauthorized, "invalid id token: %s id token verification failed", p.name)
+		return
+	}
+
+	switch {
+	case !claims.VerifyIssuer(discovery.Issuer, true):
+		err = fmt.Errorf("unexpected issuer")
+	case !claims.VerifyAudience(p.props.ClientID, true):
+		err = fmt.Errorf("unexpected audience")
+	case !claims.VerifyExpiresAt(time.Now().Unix(), true):
+		err = fmt.Errorf("expired")
+	case stringClaim(claims, "nonce") != nonce:
+		err = fmt.Errorf("unexpected nonce")
+	}
+	if err != nil {
+		err = sErrors.NewClientError(http.StatusUnauthorized, "invalid id token: %s id token %s", p.name, err)
+	}
+	return
+}
+
+// key gets the platform signing key of the kid provided, fetching the JWKS if it's unknown.
+func (p *oidcProvider) key(ctx context.Context, jwksURI, kid string) (key crypto.PublicKey, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key, ok := p.keys[kid]
+	if ok {
+		return
+	}
+
+	if time.Since(p.keysFetchedAt) < oidcKeysRefreshInterval {
+		err = fmt.Errorf("unknown %s signing key %s", p.name, kid)
+		return
+	}
+
+	var set auth.JWKS
+	err = p.getJSON(ctx, jwksURI, &set)
+	if err != nil {
+		return
+	}
+
+	p.keys = make(map[string]crypto.PublicKey, len(set.Keys))
+	p.keysFetchedAt = time.Now()
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		var pub crypto.PublicKey
+		pub, err = jwk.PublicKey()
+		if err != nil {
+			log.Printf("Skipping %s signing key: %s", p.name, err)
+			err = nil
+			continue
+		}
+		p.keys[jwk.KeyID] = pub
+	}
+
+	key, ok = p.keys[kid]
+	if !ok {
+		err = fmt.Errorf("unknown %s signing key %s", p.name, kid)
+	}
+	return
+}
+
+// checkEmailDomain checks if the identity has a verified email of the allowed domains.
+func (p *oidcProvider) checkEmailDomain(identity identity) (err error) {
+	if len(p.props.AllowedEmailDomains) == 0 {
+		return
+	}
+
+	email := identity.account.Email
+	if identity.emailVerified && strings.Contains(email, "@") {
+		domain := strings.ToLower(email[strings.LastIndex(email, "@")+1:])
+		for _, allowed := range p.props.AllowedEmailDomains {
+			if domain == strings.ToLower(allowed) {
+				return
+			}
+		}
+	}
+
+	err = sErrors.NewClientError(http.StatusForbidden, "forbidden: %s sign is not allowed for the email %s", p.name, email)
+	return
+}
+
+func (p *oidcProvider) getJSON(ctx context.Context, url string, v interface{}) (err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		err = fmt.Errorf("failed to request %s %s: %s", p.name, url, err)
+		return
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		err = fmt.Errorf("failed to request %s %s: %s", p.name, url, err)
+		return
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		err = fmt.Errorf("failed to request %s %s: unexpected status %d", p.name, url, res.StatusCode)
+		return
+	}
+
+	err = json.NewDecoder(res.Body).Decode(v)
+	if err != nil {
+		err = fmt.Errorf("failed to decode %s %s: %s", p.name, url, err)
+	}
+	return
+}
+
+func stringClaim(claims jwt.MapClaims, name string) (v string) {
+	v, _ = claims[name].(string)
+	return
+}
+
+// boolClaim reads a boolean claim, supporting the platforms which send it as string.
+func boolClaim(claims jwt.MapClaims, name string) bool {
+	switch v := claims[name].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+	return false
+}
@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/coffemanfp/chat/auth"
+	"github.com/coffemanfp/chat/config"
+	sErrors "github.com/coffemanfp/chat/errors"
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	stubClientID = "chat-client"
+	stubKeyID    = "stub-key"
+	stubCode     = "stub-code"
+)
+
+// stubOIDCServer is a OpenID Connect platform which signs the ID tokens with a RSA key.
+type stubOIDCServer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	// idToken is the ID token sent on the next code exchange.
+	idToken string
+
+	// challenge is the PKCE code challenge sent on the authorization request.
+	challenge string
+}
+
+func newStubOIDCServer(t *testing.T) (s *stubOIDCServer) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = &stubOIDCServer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscovery{
+			Issuer:                s.URL,
+			AuthorizationEndpoint: s.URL + "/authorize",
+			TokenEndpoint:         s.URL + "/token",
+			JWKSURI:               s.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(auth.JWKS{Keys: []auth.JWK{{
+			KeyType:   "RSA",
+			KeyID:     stubKeyID,
+			Use:       "sig",
+			Algorithm: "RS256",
+			N:         base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != stubCode || auth.PKCEChallenge(r.Form.Get("code_verifier")) != s.challenge {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "stub-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     s.idToken,
+		})
+	})
+
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return
+}
+
+// sign signs the claims with the method and kid provided.
+func (s *stubOIDCServer) sign(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+
+	var key interface{} = s.key
+	if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+		key = []byte("shared-secret")
+	}
+
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// startSign performs the start call of the reader and gets the state cookie and the authorization request params.
+func startSign(t *testing.T, reader oidcAccountReader) (cookie *http.Cookie, params url.Values) {
+	w := httptest.NewRecorder()
+	err := reader.start(w, httptest.NewRequest(http.MethodGet, "/auth/stub/start", nil))
+	if err != nil {
+		t.Fatalf("start failed: %s", err)
+	}
+
+	res := w.Result()
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("start status = %d, want %d", res.StatusCode, http.StatusFound)
+	}
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range res.Cookies() {
+		if c.Name == oauthStateCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("missing state cookie")
+	}
+	params = location.Query()
+	return
+}
+
+func TestOIDCAccountReader(t *testing.T) {
+	stub := newStubOIDCServer(t)
+
+	tests := []struct {
+		name string
+
+		// claims modifies the valid ID token claims.
+		claims         func(c jwt.MapClaims)
+		method         jwt.SigningMethod
+		kid            string
+		state          string
+		allowedDomains []string
+
+		wantErr      bool
+		wantCode     int
+		wantNickname string
+	}{
+		{name: "valid", wantNickname: "ana_d"},
+		{name: "invalid nickname", claims: func(c jwt.MapClaims) { c["preferred_username"] = "Ana D" }},
+		{name: "allowed email domain", allowedDomains: []string{"EXAMPLE.com"}, wantNickname: "ana_d"},
+		{name: "not allowed email domain", allowedDomains: []string{"other.com"}, wantErr: true, wantCode: http.StatusForbidden},
+		{name: "unverified email domain", allowedDomains: []string{"example.com"}, claims: func(c jwt.MapClaims) { c["email_verified"] = false }, wantErr: true, wantCode: http.StatusForbidden},
+		{name: "wrong issuer", claims: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, wantErr: true, wantCode: http.StatusUnauthorized},
+		{name: "wrong audience", claims: func(c jwt.MapClaims) { c["aud"] = "other-client" }, wantErr: true, wantCode: http.StatusUnauthorized},
+		{name: "expired", claims: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, wantErr: true, wantCode: http.StatusUnauthorized},
+		{name: "wrong nonce", claims: func(c jwt.MapClaims) { c["nonce"] = "other-nonce" }, wantErr: true, wantCode: http.StatusUnauthorized},
+		{name: "unknown key", kid: "unknown-key", wantErr: true, wantCode: http.StatusUnauthorized},
+		{name: "hmac signed", method: jwt.SigningMethodHS256, wantErr: true, wantCode: http.StatusUnauthorized},
+		{name: "wrong state", state: "other-state", wantErr: true, wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := newOIDCAccountReader(config.OIDCProperties{
+				Name:                "stub",
+				IssuerURL:           stub.URL,
+				ClientID:            stubClientID,
+				RedirectURIS:        []string{"http://localhost/auth/stub/callback"},
+				Scopes:              []string{"openid", "email", "profile"},
+				AllowedEmailDomains: tt.allowedDomains,
+			})
+			reader.provider.props.Claims.Nickname = "preferred_username"
+			reader.provider.props.Claims.Email = "email"
+			reader.provider.props.Claims.Name = "given_name"
+			reader.provider.props.Claims.LastName = "family_name"
+
+			cookie, params := startSign(t, reader)
+			stub.challenge = params.Get("code_challenge")
+
+			claims := jwt.MapClaims{
+				"iss":                stub.URL,
+				"aud":                stubClientID,
+				"sub":                "subject-1",
+				"exp":                time.Now().Add(time.Hour).Unix(),
+				"nonce":              params.Get("nonce"),
+				"email":              "ana@example.com",
+				"email_verified":     true,
+				"given_name":         "Ana",
+				"family_name":        "Diaz",
+				"preferred_username": "Ana_D",
+			}
+			if tt.claims != nil {
+				tt.claims(claims)
+			}
+			method, kid := tt.method, tt.kid
+			if method == nil {
+				method = jwt.SigningMethodRS256
+			}
+			if kid == "" {
+				kid = stubKeyID
+			}
+			stub.idToken = stub.sign(t, method, kid, claims)
+
+			state := tt.state
+			if state == "" {
+				state = params.Get("state")
+			}
+			r := httptest.NewRequest(http.MethodGet, "/auth/stub/callback?"+url.Values{
+				"state": {state},
+				"code":  {stubCode},
+			}.Encode(), nil)
+			r.AddCookie(cookie)
+
+			identity, err := reader.read(httptest.NewRecorder(), r)
+			if tt.wantErr {
+				var cErr sErrors.ClientError
+				if !errors.As(err, &cErr) || cErr.HTTPCode() != tt.wantCode {
+					t.Fatalf("read error = %v, want client error %d", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("read failed: %s", err)
+			}
+
+			if identity.subject != "subject-1" {
+				t.Errorf("subject = %q, want %q", identity.subject, "subject-1")
+			}
+			if !identity.emailVerified || identity.account.Email != "ana@example.com" {
+				t.Errorf("email = %q verified %t, want ana@example.com verified", identity.account.Email, identity.emailVerified)
+			}
+			if identity.account.Name != "Ana" || identity.account.LastName != "Diaz" {
+				t.Errorf("name = %q %q, want Ana Diaz", identity.account.Name, identity.account.LastName)
+			}
+			if identity.account.Nickname != tt.wantNickname {
+				t.Errorf("nickname = %q, want %q", identity.account.Nickname, tt.wantNickname)
+			}
+		})
+	}
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscovery{
+			Issuer:                "https://evil.example.com",
+			AuthorizationEndpoint: "https://evil.example.com/authorize",
+			TokenEndpoint:         "https://evil.example.com/token",
+			JWKSURI:               "https://evil.example.com/jwks",
+		})
+	}))
+	defer srv.Close()
+
+	reader := newOIDCAccountReader(config.OIDCProperties{
+		Name:      "stub",
+		IssuerURL: srv.URL,
+		ClientID:  stubClientID,
+	})
+
+	err := reader.start(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/auth/stub/start", nil))
+	if err == nil {
+		t.Fatal("start succeeded with the discovery of another issuer")
+	}
+}
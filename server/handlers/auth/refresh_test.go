@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coffemanfp/chat/auth"
+	"github.com/coffemanfp/chat/database"
+	sErrors "github.com/coffemanfp/chat/errors"
+	"github.com/coffemanfp/chat/mail/memory"
+)
+
+// fakeRefreshToken is a refresh token stored by fakeSessionRepository.
+type fakeRefreshToken struct {
+	sessionID string
+	expiresAt time.Time
+	used      bool
+}
+
+// fakeSessionRepository keeps the sessions in memory, with the refresh tokens semantics of the database.
+type fakeSessionRepository struct {
+	database.SessionRepository
+
+	mu       sync.Mutex
+	sessions map[string]*auth.Session
+	tmpIDs   map[string]string
+	refresh  map[string]*fakeRefreshToken
+}
+
+func newFakeSessionRepository() *fakeSessionRepository {
+	return &fakeSessionRepository{
+		sessions: make(map[string]*auth.Session),
+		tmpIDs:   make(map[string]string),
+		refresh:  make(map[string]*fakeRefreshToken),
+	}
+}
+
+func (f *fakeSessionRepository) SaveSession(session auth.Session) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sessions[session.ID] = &session
+	f.tmpIDs[auth.HashToken(session.TmpID)] = session.ID
+	return
+}
+
+func (f *fakeSessionRepository) ExchangeTmpID(tmpID string) (session auth.Session, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id, ok := f.tmpIDs[auth.HashToken(tmpID)]
+	if !ok || !f.sessions[id].Actived {
+		return
+	}
+	delete(f.tmpIDs, auth.HashToken(tmpID))
+	session = *f.sessions[id]
+	return
+}
+
+func (f *fakeSessionRepository) SaveRefreshToken(sessionID, tokenHash string, expiresAt time.Time) (err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.refresh[tokenHash] = &fakeRefreshToken{sessionID: sessionID, expiresAt: expiresAt}
+	return
+}
+
+func (f *fakeSessionRepository) RotateRefreshToken(tokenHash, newTokenHash string, expiresAt time.Time) (session auth.Session, reused bool, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	token, ok := f.refresh[tokenHash]
+	if !ok {
+		return
+	}
+	found := f.sessions[token.sessionID]
+
+	if token.used {
+		found.Actived = false
+		reused = true
+		session = *found
+		return
+	}
+	if !found.Actived || time.Now().After(token.expiresAt) {
+		return
+	}
+
+	token.used = true
+	f.refresh[newTokenHash] = &fakeRefreshToken{sessionID: found.ID, expiresAt: expiresAt}
+	session = *found
+	return
+}
+
+// expire expires the refresh tokens of the session.
+func (f *fakeSessionRepository) expire(sessionID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, token := range f.refresh {
+		if token.sessionID == sessionID {
+			token.expiresAt = time.Now().Add(-time.Second)
+		}
+	}
+}
+
+// active checks if the session is active.
+func (f *fakeSessionRepository) active(sessionID string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.sessions[sessionID].Actived
+}
+
+// assertClientError checks the error is a ClientError with the status code provided.
+func assertClientError(t *testing.T, name string, err error, code int) {
+	t.Helper()
+
+	var cErr sErrors.ClientError
+	if !errors.As(err, &cErr) || cErr.HTTPCode() != code {
+		t.Errorf("%s error = %v, want client error %d", name, err, code)
+	}
+}
+
+// newTestSession creates a new session of the test account and exchanges its TmpID.
+func newTestSession(t *testing.T, h AuthHandler) (session auth.Session, tokens auth.TokenPair) {
+	created, err := h.createSession(testAccountID, systemHandlerName)
+	if err != nil {
+		t.Fatalf("createSession failed: %s", err)
+	}
+
+	session, tokens, err = h.ExchangeTmpID(created.TmpID)
+	if err != nil {
+		t.Fatalf("ExchangeTmpID failed: %s", err)
+	}
+	if session.ID != created.ID {
+		t.Fatalf("ExchangeTmpID session = %s, want %s", session.ID, created.ID)
+	}
+
+	_, _, err = h.ExchangeTmpID(created.TmpID)
+	assertClientError(t, "repeated ExchangeTmpID", err, http.StatusUnauthorized)
+	return
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	sessions := newFakeSessionRepository()
+	h := newTestAuthHandler(fakeRestoreRepository{}, sessions, memory.NewMailer())
+
+	session, first := newTestSession(t, h)
+
+	second, err := h.refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh failed: %s", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Error("refresh token not rotated")
+	}
+	claims, err := h.tokens.Parse(second.AccessToken)
+	if err != nil || claims.SessionID != session.ID || claims.AccountID != testAccountID {
+		t.Errorf("access token claims = %+v, error %v, want session %s of account %d", claims, err, session.ID, testAccountID)
+	}
+
+	third, err := h.refresh(second.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh of rotated token failed: %s", err)
+	}
+	if !sessions.active(session.ID) {
+		t.Fatal("session deactivated by a legit rotation")
+	}
+
+	// The first token was already used, so the session is revoked.
+	_, err = h.refresh(first.RefreshToken)
+	assertClientError(t, "refresh of reused token", err, http.StatusUnauthorized)
+	if sessions.active(session.ID) {
+		t.Error("session still active after a refresh token reuse")
+	}
+
+	_, err = h.refresh(third.RefreshToken)
+	assertClientError(t, "refresh of revoked session", err, http.StatusUnauthorized)
+}
+
+func TestRefreshTokenInvalid(t *testing.T) {
+	sessions := newFakeSessionRepository()
+	h := newTestAuthHandler(fakeRestoreRepository{}, sessions, memory.NewMailer())
+
+	_, err := h.refresh("")
+	assertClientError(t, "refresh of empty token", err, http.StatusBadRequest)
+
+	_, err = h.refresh("unknown-token")
+	assertClientError(t, "refresh of unknown token", err, http.StatusUnauthorized)
+
+	session, tokens := newTestSession(t, h)
+	sessions.expire(session.ID)
+
+	_, err = h.refresh(tokens.RefreshToken)
+	assertClientError(t, "refresh of expired token", err, http.StatusUnauthorized)
+	if !sessions.active(session.ID) {
+		t.Error("session deactivated by a expired refresh token")
+	}
+}
+
+// fakeRestoreRepository is a AuthRepository without deleted accounts.
+type fakeRestoreRepository struct {
+	database.AuthRepository
+}
+
+func (fakeRestoreRepository) RestoreAccount(id int) (bool, error) {
+	return false, nil
+}
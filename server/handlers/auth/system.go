@@ -1,10 +1,9 @@
 package auth
 
 import (
-	"fmt"
 	"net/http"
 
-	"github.com/coffemanfp/chat/account"
+	sErrors "github.com/coffemanfp/chat/errors"
 	"github.com/coffemanfp/chat/server/handlers"
 )
 
@@ -12,17 +11,12 @@ const systemHandlerName handlerName = "system"
 
 type systemAccountReader struct {
 	reader handlers.RequestReader
-	writer handlers.ResponseWriter
 }
 
-func (s systemAccountReader) read(w http.ResponseWriter, r *http.Request) (account account.Account, err error) {
-	err = s.reader.JSON(r, &account)
+func (s systemAccountReader) read(w http.ResponseWriter, r *http.Request) (identity identity, err error) {
+	err = s.reader.JSON(r, &identity.account)
 	if err != nil {
-		fmt.Printf("failed for %s\n", err)
-		s.writer.JSON(w, http.StatusBadRequest, handlers.Hash{
-			"code":    http.StatusBadRequest,
-			"message": err.Error(),
-		})
+		err = sErrors.NewClientError(http.StatusBadRequest, "%s", err)
 	}
 	return
 }
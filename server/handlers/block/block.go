@@ -0,0 +1,135 @@
+package block
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/coffemanfp/chat/database"
+	sErrors "github.com/coffemanfp/chat/errors"
+	"github.com/coffemanfp/chat/server/handlers"
+)
+
+const (
+	// defaultLimit is the page size of the listings without limit.
+	defaultLimit = 50
+
+	// maxLimit is the max page size of a listing.
+	maxLimit = 200
+)
+
+// BlockHandler handles the blocking requests.
+type BlockHandler struct {
+	repository database.BlockRepository
+	writer     handlers.ResponseWriter
+	reader     handlers.RequestReader
+}
+
+// NewBlockHandler initializes a new BlockHandler instance.
+//
+//	@param repo database.BlockRepository: BlockRepository interface for the blocks handling.
+//	@param r handlers.RequestReader: RequestReader interface for reading request body operations.
+//	@param w handlers.ResponseWriter: ResponseWriter interface for writing request response operations.
+//	@return b BlockHandler: new BlockHandler instance.
+func NewBlockHandler(repo database.BlockRepository, r handlers.RequestReader, w handlers.ResponseWriter) (b BlockHandler) {
+	return BlockHandler{
+		repository: repo,
+		writer:     w,
+		reader:     r,
+	}
+}
+
+// blockRequest is the body of a block.
+type blockRequest struct {
+	AccountID int `json:"account_id"`
+}
+
+// HandleBlock blocks a account for the authenticated account.
+func (b BlockHandler) HandleBlock(w http.ResponseWriter, r *http.Request) {
+	var req blockRequest
+	err := b.reader.JSON(r, &req)
+	if err != nil {
+		if _, ok := err.(sErrors.ClientError); !ok {
+			err = sErrors.NewClientError(http.StatusBadRequest, "invalid body: %s", err)
+		}
+		b.handleError(w, err)
+		return
+	}
+
+	if req.AccountID <= 0 {
+		b.handleError(w, sErrors.NewClientError(http.StatusBadRequest, "invalid account id: account_id must be a positive integer"))
+		return
+	}
+
+	err = b.repository.Block(handlers.GetAccountID(r), req.AccountID)
+	if err != nil {
+		b.handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleUnblock removes a block of the authenticated account.
+func (b BlockHandler) HandleUnblock(w http.ResponseWriter, r *http.Request) {
+	accountID, err := handlers.GetIntVar(r, "account_id")
+	if err != nil {
+		b.handleError(w, err)
+		return
+	}
+
+	err = b.repository.Unblock(handlers.GetAccountID(r), accountID)
+	if err != nil {
+		b.handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleList lists a page of the accounts blocked by the authenticated account.
+// Supports the limit and offset query params.
+func (b BlockHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	limit, err := handlers.GetIntQuery(r, "limit", defaultLimit)
+	if err != nil {
+		b.handleError(w, err)
+		return
+	}
+
+	offset, err := handlers.GetIntQuery(r, "offset", 0)
+	if err != nil {
+		b.handleError(w, err)
+		return
+	}
+
+	if limit <= 0 || limit > maxLimit || offset < 0 {
+		b.handleError(w, sErrors.NewClientError(http.StatusBadRequest, "invalid page: limit must be between 1 and %d and offset can't be negative", maxLimit))
+		return
+	}
+
+	blocked, total, err := b.repository.GetBlocked(handlers.GetAccountID(r), limit, offset)
+	if err != nil {
+		b.handleError(w, err)
+		return
+	}
+
+	b.writer.JSON(w, http.StatusOK, handlers.Hash{
+		"blocked": blocked,
+		"total":   total,
+		"limit":   limit,
+		"offset":  offset,
+	})
+}
+
+func (b BlockHandler) handleError(w http.ResponseWriter, err error) {
+	hErr, ok := err.(sErrors.ClientError)
+	if !ok {
+		log.Println(err)
+		b.writer.JSON(w, http.StatusInternalServerError, handlers.Hash{
+			"message": sErrors.SERVER_ERROR_MESSAGE,
+		})
+		return
+	}
+	b.writer.JSON(w, hErr.HTTPCode(), handlers.Hash{
+		"message": hErr.Error(),
+	})
+}
@@ -0,0 +1,4 @@
+// Package block implements the blocking of accounts.
+// The blocks are enforced by the messaging, conversations and presence handlers.
+
+package block
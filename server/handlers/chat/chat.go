@@ -0,0 +1,328 @@
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/coffemanfp/chat/attachment"
+	"github.com/coffemanfp/chat/config"
+	"github.com/coffemanfp/chat/conversation"
+	"github.com/coffemanfp/chat/database"
+	sErrors "github.com/coffemanfp/chat/errors"
+	"github.com/coffemanfp/chat/message"
+	"github.com/coffemanfp/chat/server/handlers"
+	"github.com/gorilla/websocket"
+)
+
+var errInvalidEvent = sErrors.NewClientError(http.StatusBadRequest, "invalid event: failed to decode event")
+
+// ChatHandler handles the WebSocket connections of the accounts and their events.
+type ChatHandler struct {
+	hub           *Hub
+	messages      database.MessageRepository
+	conversations database.ConversationRepository
+	blocks        database.BlockRepository
+	sessions      database.SessionRepository
+	attachments   database.AttachmentRepository
+	typing        *typingTracker
+	upgrader      websocket.Upgrader
+}
+
+// NewChatHandler initializes a new ChatHandler instance.
+//
+//	@param hub *Hub: keeps the online connections.
+//	@param messages database.MessageRepository: MessageRepository interface for the messages handling.
+//	@param conversations database.ConversationRepository: ConversationRepository interface for the conversations handling.
+//	@param blocks database.BlockRepository: BlockRepository interface for the blocks enforcement.
+//	@param sessions database.SessionRepository: SessionRepository interface to keep the last seen of the sessions.
+//	@param attachments database.AttachmentRepository: AttachmentRepository interface to check the attached files.
+//	@param conf config.ConfigInfo: keeps the config information of the service.
+//	@return c ChatHandler: new ChatHandler instance.
+func NewChatHandler(hub *Hub, messages database.MessageRepository, conversations database.ConversationRepository, blocks database.BlockRepository, sessions database.SessionRepository, attachments database.AttachmentRepository, conf config.ConfigInfo) (c ChatHandler) {
+	return ChatHandler{
+		hub:           hub,
+		messages:      messages,
+		conversations: conversations,
+		blocks:        blocks,
+		sessions:      sessions,
+		attachments:   attachments,
+		typing:        newTypingTracker(conversations, blocks),
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     checkOrigin(conf.Server.AllowedOrigins),
+		},
+	}
+}
+
+// HandleWebSocket upgrades the request of the authenticated account to a WebSocket connection.
+func (c ChatHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	accountID := handlers.GetAccountID(r)
+	sessionID := handlers.GetSessionID(r)
+
+	conn, err := c.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already wrote the error response.
+		log.Printf("failed to upgrade connection of account %d: %s", accountID, err)
+		return
+	}
+
+	cl := newClient(accountID, sessionID, conn, c.hub)
+	c.hub.register(cl)
+
+	go cl.writePump()
+	go func() {
+		cl.readPump(c.handleEvent)
+
+		// The session last seen is the end of its connection, shown while the account is offline.
+		_, err := c.sessions.UpdateLastSeen(sessionID)
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+}
+
+// handleEvent handles the events read from a connection.
+func (c ChatHandler) handleEvent(cl *client, event Event) {
+	var err error
+	switch event.Type {
+	case SendEvent:
+		err = c.handleSend(cl, event)
+	case ReadEvent:
+		err = c.handleRead(cl, event)
+	case TypingEvent:
+		err = c.handleTyping(cl, event)
+	default:
+		err = sErrors.NewClientError(http.StatusBadRequest, "invalid event: unknown event type %s", event.Type)
+	}
+	if err != nil {
+		cl.sendError(event.Ref, err)
+	}
+}
+
+// handleSend stores the message of the event and sends it to the online members of its conversation,
+// except the members which have blocked its author.
+func (c ChatHandler) handleSend(cl *client, event Event) (err error) {
+	var data sendData
+	err = json.Unmarshal(event.Data, &data)
+	if err != nil {
+		err = errInvalidEvent
+		return
+	}
+
+	msg, err := message.New(data.ConversationID, cl.accountID, data.Content, data.AttachmentID)
+	if err != nil {
+		return
+	}
+
+	// Only the files uploaded by the author can be attached.
+	if data.AttachmentID != 0 {
+		var att attachment.Attachment
+		att, err = c.attachments.GetAttachment(data.AttachmentID)
+		if err != nil {
+			return
+		}
+		if att.AccountID != cl.accountID {
+			err = attachment.NewNotFoundError(data.AttachmentID)
+			return
+		}
+	}
+
+	// The direct conversation is opened once the message is valid, to not open empty conversations.
+	if data.ConversationID == 0 && data.AccountID != 0 {
+		data.ConversationID, err = c.openDirect(cl.accountID, data.AccountID)
+		if err != nil {
+			return
+		}
+		msg.ConversationID = data.ConversationID
+	}
+
+	role, err := c.conversations.GetMemberRole(data.ConversationID, cl.accountID)
+	if err != nil {
+		return
+	}
+
+	err = conversation.Authorize(role, conversation.WritePermission)
+	if err != nil {
+		return
+	}
+
+	conv, err := c.conversations.GetConversation(data.ConversationID)
+	if err != nil {
+		return
+	}
+
+	members, err := c.conversations.GetMemberIDs(data.ConversationID)
+	if err != nil {
+		return
+	}
+
+	if conv.Type == conversation.DirectType {
+		err = c.checkDirectBlocks(cl.accountID, conversation.ExcludeIDs(members, []int{cl.accountID}))
+		if err != nil {
+			return
+		}
+	}
+
+	// The members which have blocked the author don't receive its messages.
+	blockers, err := c.blocks.GetBlockerIDs(cl.accountID, members)
+	if err != nil {
+		return
+	}
+	members = conversation.ExcludeIDs(members, blockers)
+
+	msg, err = c.messages.SaveMessage(msg)
+	if err != nil {
+		return
+	}
+
+	cl.sendEvent(AckEvent, event.Ref, msg)
+
+	messageEvent, err := NewEvent(MessageEvent, "", msg)
+	if err != nil {
+		return
+	}
+	c.hub.send(members, messageEvent, cl, &msg)
+	return
+}
+
+// openDirect gets the direct conversation of the account with the peer, creating it on the first message.
+func (c ChatHandler) openDirect(accountID, peerID int) (conversationID int, err error) {
+	conv, err := conversation.NewDirect(accountID, peerID)
+	if err != nil {
+		return
+	}
+
+	err = c.checkDirectBlocks(accountID, []int{peerID})
+	if err != nil {
+		return
+	}
+
+	conv, _, err = c.conversations.GetOrCreateDirect(conv, accountID, peerID)
+	if err != nil {
+		return
+	}
+	conversationID = conv.ID
+	return
+}
+
+// checkDirectBlocks checks neither the account nor its direct conversation peer have blocked each other.
+func (c ChatHandler) checkDirectBlocks(accountID int, peerIDs []int) (err error) {
+	blockers, err := c.blocks.GetBlockerIDs(accountID, peerIDs)
+	if err != nil {
+		return
+	}
+
+	blocked, err := c.blocks.GetBlockedIDs(accountID, peerIDs)
+	if err != nil {
+		return
+	}
+
+	if len(blockers) != 0 || len(blocked) != 0 {
+		err = conversation.NewDirectBlockedError(peerIDs[0])
+	}
+	return
+}
+
+// handleRead moves the read cursor of the account and notifies it to the online members of the conversation,
+// except the members blocked by the account.
+func (c ChatHandler) handleRead(cl *client, event Event) (err error) {
+	var data ReadData
+	err = json.Unmarshal(event.Data, &data)
+	if err != nil {
+		err = errInvalidEvent
+		return
+	}
+	data.AccountID = cl.accountID
+
+	_, err = c.conversations.GetMemberRole(data.ConversationID, cl.accountID)
+	if err != nil {
+		return
+	}
+
+	moved, err := c.messages.MarkRead(data.ConversationID, cl.accountID, data.MessageID)
+	if err != nil || !moved {
+		return
+	}
+
+	members, err := c.conversations.GetMemberIDs(data.ConversationID)
+	if err != nil {
+		return
+	}
+
+	blocked, err := c.blocks.GetBlockedIDs(cl.accountID, members)
+	if err != nil {
+		return
+	}
+
+	readEvent, err := NewEvent(ReadEvent, "", data)
+	if err != nil {
+		return
+	}
+	c.hub.SendToAccounts(conversation.ExcludeIDs(members, blocked), readEvent)
+	return
+}
+
+// handleTyping sends the typing indicator of the account to the online members of the conversation,
+// except the members which have blocked the account. The indicators are never stored.
+func (c ChatHandler) handleTyping(cl *client, event Event) (err error) {
+	var data typingData
+	err = json.Unmarshal(event.Data, &data)
+	if err != nil {
+		err = errInvalidEvent
+		return
+	}
+	data.AccountID = cl.accountID
+
+	recipients, err := c.typing.recipients(typingKey{
+		conversationID: data.ConversationID,
+		accountID:      cl.accountID,
+	}, data.Typing, time.Now())
+	if err != nil || len(recipients) == 0 {
+		return
+	}
+
+	typingEvent, err := NewEvent(TypingEvent, "", data)
+	if err != nil {
+		return
+	}
+	c.hub.SendToAccounts(recipients, typingEvent)
+	return
+}
+
+// newErrorData builds the data of a error event, hiding the server errors.
+func newErrorData(err error) errorData {
+	var cErr sErrors.ClientError
+	if !errors.As(err, &cErr) {
+		log.Println(err)
+		return errorData{
+			Code:    http.StatusInternalServerError,
+			Message: sErrors.SERVER_ERROR_MESSAGE,
+		}
+	}
+	return errorData{
+		Code:    cErr.HTTPCode(),
+		Message: cErr.Error(),
+	}
+}
+
+// checkOrigin allows the WebSocket connections of the allowed origins or the same host.
+func checkOrigin(allowedOrigins []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		for _, allowed := range allowedOrigins {
+			if allowed == "*" || allowed == origin {
+				return true
+			}
+		}
+		u, err := url.Parse(origin)
+		return err == nil && u.Host == r.Host
+	}
+}
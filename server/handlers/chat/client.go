@@ -0,0 +1,187 @@
+package chat
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coffemanfp/chat/message"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// writeWait is the time allowed to write a event to the connection.
+	writeWait = 10 * time.Second
+
+	// pongWait is the time allowed to read the next pong from the connection.
+	pongWait = 60 * time.Second
+
+	// pingPeriod is the period to send pings to the connection. Must be less than pongWait.
+	pingPeriod = (pongWait * 9) / 10
+
+	// maxEventSize is the max size in bytes of a event read from the connection.
+	maxEventSize = 32 * 1024
+
+	// sendBufferSize is the number of events queued for a connection.
+	// The connection is closed if its queue is full.
+	sendBufferSize = 256
+)
+
+// outgoing is a event queued to be written to a connection.
+type outgoing struct {
+	payload []byte
+
+	// message is the message sent by the event, used to notify its delivery. Is nil for other events.
+	message *message.Message
+}
+
+// client is a WebSocket connection of a account.
+type client struct {
+	accountID int
+	sessionID string
+	conn      *websocket.Conn
+	hub       *Hub
+	send      chan outgoing
+
+	// lastActivity is the unix nano time of the last event read from the connection.
+	lastActivity int64
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newClient(accountID int, sessionID string, conn *websocket.Conn, hub *Hub) *client {
+	return &client{
+		accountID:    accountID,
+		sessionID:    sessionID,
+		conn:         conn,
+		hub:          hub,
+		send:         make(chan outgoing, sendBufferSize),
+		lastActivity: time.Now().UnixNano(),
+		done:         make(chan struct{}),
+	}
+}
+
+// activity gets the time of the last event read from the connection.
+func (c *client) activity() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.lastActivity))
+}
+
+// enqueue queues the event to be written without blocking. Returns false if the queue is full.
+func (c *client) enqueue(o outgoing) bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+	}
+
+	select {
+	case c.send <- o:
+		return true
+	default:
+		return false
+	}
+}
+
+// close stops the pumps of the connection. The connection is closed by the writePump.
+func (c *client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// readPump reads the events of the connection, passing them to the handle function.
+// Just one readPump must run per connection.
+func (c *client) readPump(handle func(c *client, event Event)) {
+	defer func() {
+		c.hub.unregister(c)
+		c.close()
+	}()
+
+	c.conn.SetReadLimit(maxEventSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, raw, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("failed to read connection of account %d: %s", c.accountID, err)
+			}
+			return
+		}
+
+		atomic.StoreInt64(&c.lastActivity, time.Now().UnixNano())
+
+		var event Event
+		err = json.Unmarshal(raw, &event)
+		if err != nil {
+			c.sendError("", errInvalidEvent)
+			continue
+		}
+		handle(c, event)
+	}
+}
+
+// writePump writes the queued events and the pings to the connection.
+// Just one writePump must run per connection.
+func (c *client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+		c.close()
+	}()
+
+	for {
+		select {
+		case o := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			err := c.conn.WriteMessage(websocket.TextMessage, o.payload)
+			if err != nil {
+				return
+			}
+			if o.message != nil {
+				c.hub.delivered(*o.message, c.accountID)
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			err := c.conn.WriteMessage(websocket.PingMessage, nil)
+			if err != nil {
+				return
+			}
+		case <-c.done:
+			c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
+			return
+		}
+	}
+}
+
+// sendEvent queues a event to the connection.
+func (c *client) sendEvent(t EventType, ref string, data interface{}) {
+	event, err := NewEvent(t, ref, data)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("failed to encode %s event: %s", t, err)
+		return
+	}
+
+	if !c.enqueue(outgoing{payload: payload}) {
+		log.Printf("Closing slow connection of account %d", c.accountID)
+		c.close()
+	}
+}
+
+// sendError queues a error event to the connection.
+func (c *client) sendError(ref string, err error) {
+	c.sendEvent(ErrorEvent, ref, newErrorData(err))
+}
@@ -0,0 +1,4 @@
+// Package chat implements the real-time messaging over WebSocket connections.
+// The connections of the online accounts are kept by a Hub, which fans out the events to them.
+
+package chat
@@ -0,0 +1,120 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// EventType is the type of a WebSocket event.
+type EventType string
+
+const (
+	// SendEvent is sent by the client to send a new message.
+	SendEvent EventType = "send"
+
+	// AckEvent is sent to the client when its sent message has been stored.
+	AckEvent EventType = "ack"
+
+	// MessageEvent is sent to the online members of a conversation when a new message is sent.
+	MessageEvent EventType = "message"
+
+	// DeliveredEvent is sent to the message author when the message was written to a member connection.
+	DeliveredEvent EventType = "delivered"
+
+	// ErrorEvent is sent to the client when one of its events fails.
+	ErrorEvent EventType = "error"
+
+	// EditedEvent is sent to the online members of a conversation when a message is edited.
+	EditedEvent EventType = "edited"
+
+	// ReadEvent is sent by the client to mark the messages as read up to a message,
+	// and sent to the online members of the conversation when a member read cursor moves.
+	ReadEvent EventType = "read"
+
+	// TypingEvent is sent by the client when its account starts or stops typing in a conversation,
+	// and sent to the online members of the conversation. The clients must expire the typing starts
+	// not refreshed in 10 seconds.
+	TypingEvent EventType = "typing"
+
+	// DeletedEvent is sent to the online members of a conversation when a message is deleted.
+	// Its data is the message tombstone.
+	DeletedEvent EventType = "deleted"
+)
+
+// Event is the envelope of all the WebSocket events.
+type Event struct {
+	Type EventType `json:"type"`
+
+	// Ref is the client reference of a event, sent back on its ack or error events.
+	Ref string `json:"ref,omitempty"`
+
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// NewEvent initializes a new Event with the data provided encoded as JSON.
+//
+//	@param t EventType: event type.
+//	@param ref string: client reference of the event.
+//	@param data interface{}: event data.
+//	@return event Event: new Event instance.
+//	@return err error: encoding error.
+func NewEvent(t EventType, ref string, data interface{}) (event Event, err error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		err = fmt.Errorf("failed to encode %s event: %s", t, err)
+		return
+	}
+
+	event = Event{
+		Type: t,
+		Ref:  ref,
+		Data: raw,
+	}
+	return
+}
+
+// sendData is the data of a SendEvent.
+type sendData struct {
+	ConversationID int `json:"conversation_id,omitempty"`
+
+	// AccountID is the id of the peer of a direct conversation, used instead of the ConversationID.
+	// The direct conversation is created on its first message.
+	AccountID int    `json:"account_id,omitempty"`
+	Content   string `json:"content"`
+
+	// AttachmentID is the id of a file uploaded by the account to attach to the message.
+	AttachmentID int `json:"attachment_id,omitempty"`
+}
+
+// ReadData is the data of a ReadEvent.
+type ReadData struct {
+	ConversationID int `json:"conversation_id"`
+	MessageID      int `json:"message_id"`
+
+	// AccountID is the id of the member which read the messages. Is missing on the client events.
+	AccountID int `json:"account_id,omitempty"`
+}
+
+// typingData is the data of a TypingEvent.
+type typingData struct {
+	ConversationID int  `json:"conversation_id"`
+	Typing         bool `json:"typing"`
+
+	// AccountID is the id of the typing member. Is missing on the client events.
+	AccountID int `json:"account_id,omitempty"`
+}
+
+// deliveredData is the data of a DeliveredEvent.
+type deliveredData struct {
+	MessageID      int `json:"message_id"`
+	ConversationID int `json:"conversation_id"`
+
+	// AccountID is the id of the member which the message was delivered.
+	AccountID int `json:"account_id"`
+}
+
+// errorData is the data of a ErrorEvent.
+type errorData struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,157 @@
+package chat
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/coffemanfp/chat/message"
+)
+
+// Hub keeps the online connections of the accounts and fans out the events to them.
+// An account can keep several connections, one for each client.
+type Hub struct {
+	mu      sync.RWMutex
+	clients map[int]map[*client]struct{}
+}
+
+// NewHub initializes a new *Hub instance.
+func NewHub() *Hub {
+	return &Hub{
+		clients: make(map[int]map[*client]struct{}),
+	}
+}
+
+func (h *Hub) register(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.clients[c.accountID] == nil {
+		h.clients[c.accountID] = make(map[*client]struct{})
+	}
+	h.clients[c.accountID][c] = struct{}{}
+}
+
+func (h *Hub) unregister(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.clients[c.accountID], c)
+	if len(h.clients[c.accountID]) == 0 {
+		delete(h.clients, c.accountID)
+	}
+}
+
+// CloseSession closes the connections opened with the session, used when the session is closed or revoked.
+//
+//	@param sessionID string: id of the session.
+func (h *Hub) CloseSession(sessionID string) {
+	h.closeWhere(func(c *client) bool {
+		return c.sessionID == sessionID
+	})
+}
+
+// CloseAccount closes all the connections of the account, used when all its sessions are closed.
+//
+//	@param accountID int: id of the account.
+func (h *Hub) CloseAccount(accountID int) {
+	h.closeWhere(func(c *client) bool {
+		return c.accountID == accountID
+	})
+}
+
+// closeWhere closes the connections which match the function provided.
+func (h *Hub) closeWhere(match func(c *client) bool) {
+	var closing []*client
+
+	h.mu.RLock()
+	for _, clients := range h.clients {
+		for c := range clients {
+			if match(c) {
+				closing = append(closing, c)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, c := range closing {
+		log.Printf("Closing connection of account %d: session closed", c.accountID)
+		c.close()
+	}
+}
+
+// Activity gets the last activity of the online connections of a account.
+//
+//	@param accountID int: id of the account.
+//	@return lastActivity time.Time: time of the last event read from any connection of the account.
+//	@return online bool: account has online connections.
+func (h *Hub) Activity(accountID int) (lastActivity time.Time, online bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for c := range h.clients[accountID] {
+		online = true
+		if activity := c.activity(); activity.After(lastActivity) {
+			lastActivity = activity
+		}
+	}
+	return
+}
+
+// SendToAccounts sends the event to all the online connections of the accounts.
+//
+//	@param accountIDs []int: ids of the accounts to send.
+//	@param event Event: event to send.
+func (h *Hub) SendToAccounts(accountIDs []int, event Event) {
+	h.send(accountIDs, event, nil, nil)
+}
+
+// send sends the event to the online connections of the accounts, except the excluded connection.
+// The message is the message sent by the event, used to notify its delivery.
+// The connections which are not reading fast enough are closed.
+func (h *Hub) send(accountIDs []int, event Event, exclude *client, msg *message.Message) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("failed to encode %s event: %s", event.Type, err)
+		return
+	}
+
+	var slow []*client
+
+	h.mu.RLock()
+	for _, id := range accountIDs {
+		for c := range h.clients[id] {
+			if c == exclude {
+				continue
+			}
+			if !c.enqueue(outgoing{payload: payload, message: msg}) {
+				slow = append(slow, c)
+			}
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, c := range slow {
+		log.Printf("Closing slow connection of account %d", c.accountID)
+		c.close()
+	}
+}
+
+// delivered notifies the author of the message that it was written to the connection of the account.
+func (h *Hub) delivered(msg message.Message, accountID int) {
+	if msg.AccountID == accountID {
+		return
+	}
+
+	event, err := NewEvent(DeliveredEvent, "", deliveredData{
+		MessageID:      msg.ID,
+		ConversationID: msg.ConversationID,
+		AccountID:      accountID,
+	})
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	h.SendToAccounts([]int{msg.AccountID}, event)
+}
@@ -0,0 +1,136 @@
+package chat
+
+import (
+	"sync"
+	"time"
+
+	"github.com/coffemanfp/chat/conversation"
+	"github.com/coffemanfp/chat/database"
+	sErrors "github.com/coffemanfp/chat/errors"
+)
+
+const (
+	// typingAudienceTTL is the time a typing audience is reused before loading it again.
+	typingAudienceTTL = 30 * time.Second
+
+	// typingThrottle is the min time between the typing starts of a account in a conversation
+	// sent to the members. The typing stops are always sent.
+	typingThrottle = 3 * time.Second
+)
+
+// typingKey identifies a account typing in a conversation.
+type typingKey struct {
+	conversationID int
+	accountID      int
+}
+
+// typingAudience is the cached result of the permission check and the recipients of a typing account.
+type typingAudience struct {
+	recipients []int
+	err        error
+	loadedAt   time.Time
+}
+
+// typingTracker forwards the typing indicators. The indicators are ephemeral, they are never stored,
+// and the audiences are cached to not read the database on each keystroke.
+type typingTracker struct {
+	conversations database.ConversationRepository
+	blocks        database.BlockRepository
+
+	mu        sync.Mutex
+	audiences map[typingKey]typingAudience
+	lastSent  map[typingKey]time.Time
+}
+
+func newTypingTracker(conversations database.ConversationRepository, blocks database.BlockRepository) *typingTracker {
+	return &typingTracker{
+		conversations: conversations,
+		blocks:        blocks,
+		audiences:     make(map[typingKey]typingAudience),
+		lastSent:      make(map[typingKey]time.Time),
+	}
+}
+
+// recipients gets the members which must receive the typing indicator of the account.
+// Returns nil recipients if the indicator is throttled.
+func (t *typingTracker) recipients(key typingKey, typing bool, now time.Time) (recipients []int, err error) {
+	t.mu.Lock()
+	audience, ok := t.audiences[key]
+	t.mu.Unlock()
+
+	// The audience is loaded without the lock, to not block the other conversations on the database.
+	if !ok || now.Sub(audience.loadedAt) > typingAudienceTTL {
+		audience = t.load(key, now)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Only the client errors are cached, the server errors are retried on the next indicator.
+	if _, isClientErr := audience.err.(sErrors.ClientError); audience.err == nil || isClientErr {
+		if !ok {
+			t.sweep(now)
+		}
+		t.audiences[key] = audience
+	}
+	if audience.err != nil {
+		err = audience.err
+		return
+	}
+
+	if typing {
+		if now.Sub(t.lastSent[key]) < typingThrottle {
+			return
+		}
+		t.lastSent[key] = now
+	} else {
+		delete(t.lastSent, key)
+	}
+	recipients = audience.recipients
+	return
+}
+
+// load loads the audience of the typing account.
+func (t *typingTracker) load(key typingKey, now time.Time) (audience typingAudience) {
+	audience.loadedAt = now
+
+	role, err := t.conversations.GetMemberRole(key.conversationID, key.accountID)
+	if err != nil {
+		audience.err = err
+		return
+	}
+
+	audience.err = conversation.Authorize(role, conversation.WritePermission)
+	if audience.err != nil {
+		return
+	}
+
+	members, err := t.conversations.GetMemberIDs(key.conversationID)
+	if err != nil {
+		audience.err = err
+		return
+	}
+
+	// The members which have blocked the account don't receive its indicators.
+	blockers, err := t.blocks.GetBlockerIDs(key.accountID, members)
+	if err != nil {
+		audience.err = err
+		return
+	}
+	audience.recipients = conversation.ExcludeIDs(members, append(blockers, key.accountID))
+	return
+}
+
+// sweep removes the expired audiences and indicators.
+func (t *typingTracker) sweep(now time.Time) {
+	for key, audience := range t.audiences {
+		if now.Sub(audience.loadedAt) > typingAudienceTTL {
+			delete(t.audiences, key)
+		}
+	}
+	for key, sent := range t.lastSent {
+		if now.Sub(sent) > typingThrottle {
+			delete(t.lastSent, key)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package contact
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/coffemanfp/chat/contact"
+	"github.com/coffemanfp/chat/database"
+	sErrors "github.com/coffemanfp/chat/errors"
+	"github.com/coffemanfp/chat/server/handlers"
+)
+
+// ContactHandler handles the address book requests.
+type ContactHandler struct {
+	repository database.ContactRepository
+	writer     handlers.ResponseWriter
+	reader     handlers.RequestReader
+}
+
+// NewContactHandler initializes a new ContactHandler instance.
+//
+//	@param repo database.ContactRepository: ContactRepository interface for the contacts handling.
+//	@param r handlers.RequestReader: RequestReader interface for reading request body operations.
+//	@param w handlers.ResponseWriter: ResponseWriter interface for writing request response operations.
+//	@return c ContactHandler: new ContactHandler instance.
+func NewContactHandler(repo database.ContactRepository, r handlers.RequestReader, w handlers.ResponseWriter) (c ContactHandler) {
+	return ContactHandler{
+		repository: repo,
+		writer:     w,
+		reader:     r,
+	}
+}
+
+// addRequest is the body of a contact addition.
+type addRequest struct {
+	// Account is the nickname or email of the account to save.
+	Account  string `json:"account"`
+	Name     string `json:"name,omitempty"`
+	LastName string `json:"last_name,omitempty"`
+}
+
+// renameRequest is the body of a contact rename. The missing fields are not updated.
+type renameRequest struct {
+	Name     *string `json:"name,omitempty"`
+	LastName *string `json:"last_name,omitempty"`
+}
+
+// HandleAdd saves a account in the address book of the authenticated account.
+func (c ContactHandler) HandleAdd(w http.ResponseWriter, r *http.Request) {
+	var req addRequest
+	err := c.readJSON(r, &req)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	if strings.TrimSpace(req.Account) == "" {
+		c.handleError(w, sErrors.NewClientError(http.StatusBadRequest, "invalid account: missing nickname or email"))
+		return
+	}
+
+	ct, err := contact.New(handlers.GetAccountID(r), contact.Contact{
+		Name:     req.Name,
+		LastName: req.LastName,
+	})
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	ct, err = c.repository.AddContact(ct, req.Account)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	c.writer.JSON(w, http.StatusCreated, ct)
+}
+
+// HandleList lists a page of the address book of the authenticated account.
+// Supports the q (search), sort, limit and offset query params.
+func (c ContactHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	limit, err := handlers.GetIntQuery(r, "limit", 0)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	offset, err := handlers.GetIntQuery(r, "offset", 0)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	query, err := contact.NewQuery(r.URL.Query().Get("q"), r.URL.Query().Get("sort"), limit, offset)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	contacts, total, err := c.repository.GetContacts(handlers.GetAccountID(r), query)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	c.writer.JSON(w, http.StatusOK, handlers.Hash{
+		"contacts": contacts,
+		"total":    total,
+		"limit":    query.Limit,
+		"offset":   query.Offset,
+	})
+}
+
+// HandleGet gets a contact of the address book of the authenticated account.
+func (c ContactHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	id, err := handlers.GetIntVar(r, "id")
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	ct, err := c.repository.GetContact(handlers.GetAccountID(r), id)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	c.writer.JSON(w, http.StatusOK, ct)
+}
+
+// HandleRename renames a contact of the address book of the authenticated account.
+func (c ContactHandler) HandleRename(w http.ResponseWriter, r *http.Request) {
+	id, err := handlers.GetIntVar(r, "id")
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	ct, err := c.repository.GetContact(handlers.GetAccountID(r), id)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	var req renameRequest
+	err = c.readJSON(r, &req)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	if req.Name != nil {
+		ct.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.LastName != nil {
+		ct.LastName = strings.TrimSpace(*req.LastName)
+	}
+
+	if ct.Name == "" {
+		c.handleError(w, sErrors.NewClientError(http.StatusBadRequest, "invalid name: empty contact name"))
+		return
+	}
+	err = contact.ValidateNames(ct.Name, ct.LastName)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	err = c.repository.UpdateContact(ct)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	c.writer.JSON(w, http.StatusOK, ct)
+}
+
+// HandleRemove removes a contact of the address book of the authenticated account.
+func (c ContactHandler) HandleRemove(w http.ResponseWriter, r *http.Request) {
+	id, err := handlers.GetIntVar(r, "id")
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	err = c.repository.DeleteContact(handlers.GetAccountID(r), id)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c ContactHandler) readJSON(r *http.Request, v interface{}) (err error) {
+	err = c.reader.JSON(r, v)
+	if err != nil {
+		if _, ok := err.(sErrors.ClientError); !ok {
+			err = sErrors.NewClientError(http.StatusBadRequest, "invalid body: %s", err)
+		}
+	}
+	return
+}
+
+func (c ContactHandler) handleError(w http.ResponseWriter, err error) {
+	hErr, ok := err.(sErrors.ClientError)
+	if !ok {
+		log.Println(err)
+		c.writer.JSON(w, http.StatusInternalServerError, handlers.Hash{
+			"message": sErrors.SERVER_ERROR_MESSAGE,
+		})
+		return
+	}
+	c.writer.JSON(w, hErr.HTTPCode(), handlers.Hash{
+		"message": hErr.Error(),
+	})
+}
@@ -0,0 +1,3 @@
+// Package contact implements the address book of the accounts.
+
+package contact
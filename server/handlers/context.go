@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+)
+
+type contextKey string
+
+const (
+	accountIDKey contextKey = "id"
+	sessionIDKey contextKey = "session_id"
+)
+
+// WithAuth sets the authenticated account and session ids in the request context.
+//
+//	@param r *http.Request: authenticated request.
+//	@param accountID int: authenticated account id.
+//	@param sessionID string: session id used for the authentication.
+//	@return $1 *http.Request: request copy with the new context.
+func WithAuth(r *http.Request, accountID int, sessionID string) *http.Request {
+	ctx := context.WithValue(r.Context(), accountIDKey, accountID)
+	ctx = context.WithValue(ctx, sessionIDKey, sessionID)
+	return r.WithContext(ctx)
+}
+
+// GetAccountID gets the authenticated account id of the request. Returns 0 if it's not available.
+func GetAccountID(r *http.Request) (id int) {
+	id, _ = r.Context().Value(accountIDKey).(int)
+	return
+}
+
+// GetSessionID gets the session id of the authenticated request. Returns "" if it's not available.
+func GetSessionID(r *http.Request) (id string) {
+	id, _ = r.Context().Value(sessionIDKey).(string)
+	return
+}
@@ -0,0 +1,411 @@
+package conversation
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/coffemanfp/chat/account"
+	"github.com/coffemanfp/chat/attachment"
+	"github.com/coffemanfp/chat/conversation"
+	"github.com/coffemanfp/chat/database"
+	sErrors "github.com/coffemanfp/chat/errors"
+	"github.com/coffemanfp/chat/server/handlers"
+)
+
+// ConversationHandler handles the conversations management requests.
+type ConversationHandler struct {
+	repository  database.ConversationRepository
+	blocks      database.BlockRepository
+	attachments database.AttachmentRepository
+	signer      attachment.Signer
+	writer      handlers.ResponseWriter
+	reader      handlers.RequestReader
+}
+
+// NewConversationHandler initializes a new ConversationHandler instance.
+//
+//	@param repo database.ConversationRepository: ConversationRepository interface for the conversations handling.
+//	@param blocks database.BlockRepository: BlockRepository interface for the blocks enforcement.
+//	@param attachments database.AttachmentRepository: AttachmentRepository interface for the pictures.
+//	@param signer attachment.Signer: signs the pictures URLs.
+//	@param r handlers.RequestReader: RequestReader interface for reading request body operations.
+//	@param w handlers.ResponseWriter: ResponseWriter interface for writing request response operations.
+//	@return c ConversationHandler: new ConversationHandler instance.
+func NewConversationHandler(repo database.ConversationRepository, blocks database.BlockRepository, attachments database.AttachmentRepository, signer attachment.Signer, r handlers.RequestReader, w handlers.ResponseWriter) (c ConversationHandler) {
+	return ConversationHandler{
+		repository:  repo,
+		blocks:      blocks,
+		attachments: attachments,
+		signer:      signer,
+		writer:      w,
+		reader:      r,
+	}
+}
+
+// createRequest is the body of a conversation creation.
+type createRequest struct {
+	conversation.Conversation
+
+	// MemberIDs are the ids of the initial members, besides the creator.
+	MemberIDs []int `json:"member_ids,omitempty"`
+
+	// PictureAttachmentID is the id of a image uploaded by the creator, used instead of the picture url.
+	PictureAttachmentID int `json:"picture_attachment_id,omitempty"`
+}
+
+// updateRequest is the body of a conversation update. The missing fields are not updated.
+type updateRequest struct {
+	Name       *string `json:"name,omitempty"`
+	PictureURL *string `json:"picture_url,omitempty"`
+
+	// PictureAttachmentID is the id of a image uploaded by the account, used instead of the picture url.
+	PictureAttachmentID *int `json:"picture_attachment_id,omitempty"`
+}
+
+// roleRequest is the body of a member role change.
+type roleRequest struct {
+	RoleID int `json:"role_id"`
+}
+
+// membersRequest is the body of a members addition.
+type membersRequest struct {
+	AccountIDs []int `json:"account_ids"`
+}
+
+// HandleCreate creates a new conversation owned by the authenticated account.
+func (c ConversationHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	accountID := handlers.GetAccountID(r)
+
+	var req createRequest
+	err := c.readJSON(r, &req)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	if req.PictureAttachmentID != 0 {
+		req.PictureURL, err = c.getPictureURL(accountID, req.PictureAttachmentID)
+		if err != nil {
+			c.handleError(w, err)
+			return
+		}
+	}
+
+	conv, err := conversation.New(req.Conversation)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	memberIDs := conversation.UniqueIDs(req.MemberIDs, accountID)
+	err = conversation.ValidateMembers(conv, 1, len(memberIDs))
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	err = c.checkBlockers(accountID, memberIDs)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	conv, err = c.repository.CreateConversation(conv, accountID, memberIDs)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	c.writer.JSON(w, http.StatusCreated, conv)
+}
+
+// HandleList lists the conversations of the authenticated account.
+func (c ConversationHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	convs, err := c.repository.GetConversations(handlers.GetAccountID(r))
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	c.writer.JSON(w, http.StatusOK, handlers.Hash{
+		"conversations": convs,
+	})
+}
+
+// HandleGet gets a conversation of the authenticated account with its members.
+func (c ConversationHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	conv, _, err := c.getMemberConversation(r)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	if conv.Type == conversation.DirectType {
+		var peer account.Profile
+		peer, err = c.repository.GetDirectPeer(conv.ID, handlers.GetAccountID(r))
+		if err != nil {
+			c.handleError(w, err)
+			return
+		}
+		conv = conv.WithPeer(peer)
+	}
+
+	members, err := c.repository.GetMembers(conv.ID)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	c.writer.JSON(w, http.StatusOK, handlers.Hash{
+		"conversation": conv,
+		"members":      members,
+	})
+}
+
+// HandleUpdate renames or changes the picture of a conversation of the authenticated account.
+func (c ConversationHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
+	conv, _, err := c.getGroupConversation(r, conversation.ChangeConversationDetailPermission)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	var req updateRequest
+	err = c.readJSON(r, &req)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	if req.Name != nil {
+		conv.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.PictureURL != nil {
+		conv.PictureURL = *req.PictureURL
+	}
+	if req.PictureAttachmentID != nil {
+		conv.PictureURL, err = c.getPictureURL(handlers.GetAccountID(r), *req.PictureAttachmentID)
+		if err != nil {
+			c.handleError(w, err)
+			return
+		}
+	}
+
+	err = conversation.Validate(conv)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	err = c.repository.UpdateConversation(conv)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	c.writer.JSON(w, http.StatusOK, conv)
+}
+
+// HandleDelete soft deletes a conversation of the authenticated account.
+// Requires to manage both the roles and the details of the conversation.
+func (c ConversationHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	conv, _, err := c.getGroupConversation(r, conversation.ChangeRolePermission, conversation.ChangeConversationDetailPermission)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	err = c.repository.DeleteConversation(conv.ID)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleAddMembers adds new members to a conversation of the authenticated account.
+func (c ConversationHandler) HandleAddMembers(w http.ResponseWriter, r *http.Request) {
+	conv, _, err := c.getGroupConversation(r, conversation.AddAccountPermission)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	var req membersRequest
+	err = c.readJSON(r, &req)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	accountIDs := conversation.UniqueIDs(req.AccountIDs, 0)
+	if len(accountIDs) == 0 {
+		c.handleError(w, sErrors.NewClientError(http.StatusBadRequest, "invalid account ids: no accounts to add"))
+		return
+	}
+
+	err = c.checkBlockers(handlers.GetAccountID(r), accountIDs)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	err = c.repository.AddMembers(conv.ID, accountIDs)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	members, err := c.repository.GetMembers(conv.ID)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	c.writer.JSON(w, http.StatusOK, handlers.Hash{
+		"members": members,
+	})
+}
+
+// HandleSetRole changes the role of a member of a conversation of the authenticated account.
+func (c ConversationHandler) HandleSetRole(w http.ResponseWriter, r *http.Request) {
+	conv, actor, err := c.getGroupConversation(r)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	accountID, err := handlers.GetIntVar(r, "account_id")
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	var req roleRequest
+	err = c.readJSON(r, &req)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	current, err := c.repository.GetMemberRole(conv.ID, accountID)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	role, err := c.repository.GetRole(conv.ID, req.RoleID)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	err = conversation.AuthorizeRoleChange(actor, current, role)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	err = c.repository.SetMemberRole(conv.ID, accountID, role.ID)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	c.writer.JSON(w, http.StatusOK, handlers.Hash{
+		"account_id": accountID,
+		"role":       role,
+	})
+}
+
+// getMemberConversation gets the conversation of the request route and the role of the authenticated account,
+// hiding the conversation if the account is not a member.
+//
+//	@param r *http.Request: authenticated request with the conversation id route variable.
+//	@param permissions ...conversation.Permission: permissions required to the account role.
+//	@return conv conversation.Conversation: found conversation.
+//	@return role conversation.Role: role of the authenticated account.
+//	@return err error: not found, forbidden or database error.
+func (c ConversationHandler) getMemberConversation(r *http.Request, permissions ...conversation.Permission) (conv conversation.Conversation, role conversation.Role, err error) {
+	id, err := handlers.GetIntVar(r, "id")
+	if err != nil {
+		return
+	}
+
+	role, err = c.repository.GetMemberRole(id, handlers.GetAccountID(r))
+	if err != nil {
+		return
+	}
+
+	err = conversation.Authorize(role, permissions...)
+	if err != nil {
+		return
+	}
+
+	conv, err = c.repository.GetConversation(id)
+	return
+}
+
+// getGroupConversation gets the group conversation of the request route like getMemberConversation.
+// The direct conversations have no managed members or details, so they are rejected.
+func (c ConversationHandler) getGroupConversation(r *http.Request, permissions ...conversation.Permission) (conv conversation.Conversation, role conversation.Role, err error) {
+	conv, role, err = c.getMemberConversation(r, permissions...)
+	if err != nil {
+		return
+	}
+	err = conversation.CheckGroup(conv)
+	return
+}
+
+// getPictureURL gets the picture url of a image uploaded by the account.
+func (c ConversationHandler) getPictureURL(accountID, attachmentID int) (pictureURL string, err error) {
+	att, err := c.attachments.GetAttachment(attachmentID)
+	if err != nil {
+		return
+	}
+
+	err = attachment.CheckPicture(att, accountID)
+	if err != nil {
+		return
+	}
+	pictureURL = c.signer.PictureURL(att)
+	return
+}
+
+// checkBlockers checks none of the accounts to add has blocked the account which adds them.
+func (c ConversationHandler) checkBlockers(accountID int, accountIDs []int) (err error) {
+	blockers, err := c.blocks.GetBlockerIDs(accountID, accountIDs)
+	if err != nil {
+		return
+	}
+	if len(blockers) != 0 {
+		err = sErrors.NewClientError(http.StatusForbidden, "forbidden: account %d can't be added", blockers[0])
+	}
+	return
+}
+
+func (c ConversationHandler) readJSON(r *http.Request, v interface{}) (err error) {
+	err = c.reader.JSON(r, v)
+	if err != nil {
+		if _, ok := err.(sErrors.ClientError); !ok {
+			err = sErrors.NewClientError(http.StatusBadRequest, "invalid body: %s", err)
+		}
+	}
+	return
+}
+
+func (c ConversationHandler) handleError(w http.ResponseWriter, err error) {
+	hErr, ok := err.(sErrors.ClientError)
+	if !ok {
+		log.Println(err)
+		c.writer.JSON(w, http.StatusInternalServerError, handlers.Hash{
+			"message": sErrors.SERVER_ERROR_MESSAGE,
+		})
+		return
+	}
+	c.writer.JSON(w, hErr.HTTPCode(), handlers.Hash{
+		"message": hErr.Error(),
+	})
+}
@@ -0,0 +1,3 @@
+// Package conversation implements the conversations management of the accounts.
+
+package conversation
@@ -0,0 +1,168 @@
+package conversation
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/coffemanfp/chat/auth"
+	"github.com/coffemanfp/chat/conversation"
+	"github.com/coffemanfp/chat/server/handlers"
+	"github.com/gorilla/mux"
+)
+
+// inviteRequest is the body of a invite creation.
+type inviteRequest struct {
+	// ExpiresIn is the number of seconds to expire of the invite. 0 means the default lifetime.
+	ExpiresIn int `json:"expires_in,omitempty"`
+
+	// MaxUses is the number of times the invite can be used. 0 means no limit.
+	MaxUses int `json:"max_uses,omitempty"`
+}
+
+// HandleLeave ends the membership of the authenticated account in a conversation.
+func (c ConversationHandler) HandleLeave(w http.ResponseWriter, r *http.Request) {
+	conv, _, err := c.getGroupConversation(r)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	err = c.repository.RemoveMember(conv.ID, handlers.GetAccountID(r))
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleKick ends the membership of a member of a conversation of the authenticated account.
+// Kicking the authenticated account itself is handled as leaving the conversation.
+func (c ConversationHandler) HandleKick(w http.ResponseWriter, r *http.Request) {
+	conv, actor, err := c.getGroupConversation(r)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	accountID, err := handlers.GetIntVar(r, "account_id")
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	if accountID != handlers.GetAccountID(r) {
+		var target conversation.Role
+		target, err = c.repository.GetMemberRole(conv.ID, accountID)
+		if err != nil {
+			c.handleError(w, err)
+			return
+		}
+
+		err = conversation.AuthorizeKick(actor, target)
+		if err != nil {
+			c.handleError(w, err)
+			return
+		}
+	}
+
+	err = c.repository.RemoveMember(conv.ID, accountID)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleCreateInvite creates a new invite link to a conversation of the authenticated account.
+// The invite token is only sent on its creation.
+func (c ConversationHandler) HandleCreateInvite(w http.ResponseWriter, r *http.Request) {
+	conv, _, err := c.getGroupConversation(r, conversation.AddAccountPermission)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	var req inviteRequest
+	err = c.readJSON(r, &req)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	invite, err := conversation.NewInvite(conv.ID, handlers.GetAccountID(r), time.Duration(req.ExpiresIn)*time.Second, req.MaxUses)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	invite, err = c.repository.CreateInvite(invite, auth.HashToken(invite.Token))
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	c.writer.JSON(w, http.StatusCreated, invite)
+}
+
+// HandleListInvites lists the usable invites of a conversation of the authenticated account.
+func (c ConversationHandler) HandleListInvites(w http.ResponseWriter, r *http.Request) {
+	conv, _, err := c.getGroupConversation(r, conversation.AddAccountPermission)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	invites, err := c.repository.GetInvites(conv.ID)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	c.writer.JSON(w, http.StatusOK, handlers.Hash{
+		"invites": invites,
+	})
+}
+
+// HandleRevokeInvite revokes a invite of a conversation of the authenticated account.
+func (c ConversationHandler) HandleRevokeInvite(w http.ResponseWriter, r *http.Request) {
+	conv, _, err := c.getGroupConversation(r, conversation.AddAccountPermission)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	inviteID, err := handlers.GetIntVar(r, "invite_id")
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	err = c.repository.RevokeInvite(conv.ID, inviteID)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleJoin adds the authenticated account to the conversation of a invite link.
+func (c ConversationHandler) HandleJoin(w http.ResponseWriter, r *http.Request) {
+	token := mux.Vars(r)["token"]
+
+	id, err := c.repository.JoinWithInvite(auth.HashToken(token), handlers.GetAccountID(r))
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	conv, err := c.repository.GetConversation(id)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	c.writer.JSON(w, http.StatusOK, conv)
+}
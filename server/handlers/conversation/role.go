@@ -0,0 +1,172 @@
+package conversation
+
+import (
+	"net/http"
+
+	"github.com/coffemanfp/chat/conversation"
+	sErrors "github.com/coffemanfp/chat/errors"
+	"github.com/coffemanfp/chat/server/handlers"
+)
+
+// HandleListRoles lists the default and custom roles of a conversation of the authenticated account.
+func (c ConversationHandler) HandleListRoles(w http.ResponseWriter, r *http.Request) {
+	conv, _, err := c.getMemberConversation(r)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	roles, err := c.repository.GetRoles(conv.ID)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	c.writer.JSON(w, http.StatusOK, handlers.Hash{
+		"roles": roles,
+	})
+}
+
+// HandleCreateRole creates a new custom role in a conversation of the authenticated account.
+func (c ConversationHandler) HandleCreateRole(w http.ResponseWriter, r *http.Request) {
+	conv, actor, err := c.getGroupConversation(r)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	var req conversation.Role
+	err = c.readJSON(r, &req)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+	req.ConversationID = conv.ID
+
+	role, err := conversation.NewRole(req)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	err = conversation.AuthorizeRoleEdit(actor, role)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	role, err = c.repository.CreateRole(role)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	c.writer.JSON(w, http.StatusCreated, role)
+}
+
+// HandleUpdateRole edits the name, description or permissions of a custom role of a conversation.
+// The missing fields are not updated.
+func (c ConversationHandler) HandleUpdateRole(w http.ResponseWriter, r *http.Request) {
+	conv, actor, role, err := c.getCustomRole(r)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	err = conversation.AuthorizeRoleEdit(actor, role)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	var req updateRoleRequest
+	err = c.readJSON(r, &req)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	if req.Name != nil {
+		role.Name = *req.Name
+	}
+	if req.Description != nil {
+		role.Description = *req.Description
+	}
+	if req.Permissions != nil {
+		role.Permissions = *req.Permissions
+	}
+	role.ConversationID = conv.ID
+
+	role, err = conversation.NewRole(role)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	err = conversation.AuthorizeRoleEdit(actor, role)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	err = c.repository.UpdateRole(role)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	c.writer.JSON(w, http.StatusOK, role)
+}
+
+// HandleDeleteRole deletes a custom role of a conversation which is not assigned to any member.
+func (c ConversationHandler) HandleDeleteRole(w http.ResponseWriter, r *http.Request) {
+	conv, actor, role, err := c.getCustomRole(r)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	err = conversation.AuthorizeRoleEdit(actor, role)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	err = c.repository.DeleteRole(conv.ID, role.ID)
+	if err != nil {
+		c.handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// updateRoleRequest is the body of a custom role update.
+type updateRoleRequest struct {
+	Name        *string                   `json:"name,omitempty"`
+	Description *string                   `json:"description,omitempty"`
+	Permissions *conversation.Permissions `json:"permissions,omitempty"`
+}
+
+// getCustomRole gets the custom role of the request route, its conversation and the role of the authenticated account.
+// The default roles can't be managed.
+func (c ConversationHandler) getCustomRole(r *http.Request) (conv conversation.Conversation, actor, role conversation.Role, err error) {
+	conv, actor, err = c.getGroupConversation(r)
+	if err != nil {
+		return
+	}
+
+	roleID, err := handlers.GetIntVar(r, "role_id")
+	if err != nil {
+		return
+	}
+
+	role, err = c.repository.GetRole(conv.ID, roleID)
+	if err != nil {
+		return
+	}
+	if role.Default() {
+		err = sErrors.NewClientError(http.StatusForbidden, "forbidden: default role %s can't be changed", role.Name)
+	}
+	return
+}
@@ -0,0 +1,4 @@
+// Package message implements the REST requests of the conversation messages, like the history.
+// The real-time messaging is implemented by the chat package.
+
+package message
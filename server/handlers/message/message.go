@@ -0,0 +1,386 @@
+package message
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coffemanfp/chat/config"
+	"github.com/coffemanfp/chat/conversation"
+	"github.com/coffemanfp/chat/database"
+	sErrors "github.com/coffemanfp/chat/errors"
+	"github.com/coffemanfp/chat/message"
+	"github.com/coffemanfp/chat/server/handlers"
+	"github.com/coffemanfp/chat/server/handlers/chat"
+)
+
+// MessageHandler handles the conversation messages requests.
+type MessageHandler struct {
+	hub           *chat.Hub
+	messages      database.MessageRepository
+	conversations database.ConversationRepository
+	blocks        database.BlockRepository
+	writer        handlers.ResponseWriter
+	reader        handlers.RequestReader
+	editWindow    time.Duration
+}
+
+// NewMessageHandler initializes a new MessageHandler instance.
+//
+//	@param hub *chat.Hub: keeps the online connections to broadcast the messages changes.
+//	@param messages database.MessageRepository: MessageRepository interface for the messages handling.
+//	@param conversations database.ConversationRepository: ConversationRepository interface for the conversations handling.
+//	@param blocks database.BlockRepository: BlockRepository interface for the blocks enforcement.
+//	@param r handlers.RequestReader: RequestReader interface for reading request body operations.
+//	@param w handlers.ResponseWriter: ResponseWriter interface for writing request response operations.
+//	@param conf config.ConfigInfo: keeps the config information of the service.
+//	@return m MessageHandler: new MessageHandler instance.
+func NewMessageHandler(hub *chat.Hub, messages database.MessageRepository, conversations database.ConversationRepository, blocks database.BlockRepository, r handlers.RequestReader, w handlers.ResponseWriter, conf config.ConfigInfo) (m MessageHandler) {
+	return MessageHandler{
+		hub:           hub,
+		messages:      messages,
+		conversations: conversations,
+		blocks:        blocks,
+		writer:        w,
+		reader:        r,
+		editWindow:    conf.Server.Messages.EditWindow,
+	}
+}
+
+// readRequest is the body of a read cursor move.
+type readRequest struct {
+	MessageID int `json:"message_id"`
+}
+
+// editRequest is the body of a message edition.
+type editRequest struct {
+	Content string `json:"content"`
+}
+
+// HandleHistory gets a page of the history of a conversation of the authenticated account,
+// limited to the messages sent since the account joined it.
+// Supports the before (cursor) and limit query params.
+func (m MessageHandler) HandleHistory(w http.ResponseWriter, r *http.Request) {
+	accountID := handlers.GetAccountID(r)
+
+	conversationID, err := handlers.GetIntVar(r, "id")
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	limit, err := handlers.GetIntQuery(r, "limit", message.DefaultPageSize)
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+	err = message.ValidatePageSize(limit)
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	var before *message.Cursor
+	if raw := r.URL.Query().Get("before"); raw != "" {
+		var cursor message.Cursor
+		cursor, err = message.DecodeCursor(raw)
+		if err != nil {
+			m.handleError(w, err)
+			return
+		}
+		before = &cursor
+	}
+
+	// The membership is checked first to hide the conversations of other accounts.
+	_, err = m.conversations.GetMemberRole(conversationID, accountID)
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	// One more message is requested to know if there is a previous page.
+	messages, err := m.messages.GetHistory(conversationID, accountID, before, limit+1)
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	m.writer.JSON(w, http.StatusOK, message.NewPage(messages, limit))
+}
+
+// HandleEdit replaces the content of a message of the authenticated account, within the edit window.
+func (m MessageHandler) HandleEdit(w http.ResponseWriter, r *http.Request) {
+	accountID := handlers.GetAccountID(r)
+
+	msg, role, err := m.getMessage(r)
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	// The members which can't write anymore can't edit their messages either.
+	err = conversation.Authorize(role, conversation.WritePermission)
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	now := time.Now()
+	err = message.AuthorizeEdit(msg, accountID, m.editWindow, now)
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	var req editRequest
+	err = m.readJSON(r, &req)
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	content := strings.TrimSpace(req.Content)
+	err = message.ValidateContent(content)
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	msg, err = m.messages.EditMessage(msg.ID, content, now.Truncate(time.Microsecond))
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	m.broadcast(chat.EditedEvent, msg)
+	m.writer.JSON(w, http.StatusOK, msg)
+}
+
+// HandleDelete deletes a message, leaving a tombstone in the history.
+// The authors can delete their messages, and the members with the delete_message permission any message.
+func (m MessageHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	accountID := handlers.GetAccountID(r)
+
+	msg, role, err := m.getMessage(r)
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	err = message.AuthorizeDelete(msg, accountID, role.Permissions.Has(conversation.DeleteMessagePermission))
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	msg, err = m.messages.DeleteMessage(msg.ID, accountID)
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	m.broadcast(chat.DeletedEvent, msg)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleRevisions gets the previous contents of a message.
+func (m MessageHandler) HandleRevisions(w http.ResponseWriter, r *http.Request) {
+	msg, _, err := m.getMessage(r)
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	revisions, err := m.messages.GetRevisions(msg.ID)
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	m.writer.JSON(w, http.StatusOK, handlers.Hash{
+		"message":   msg,
+		"revisions": revisions,
+	})
+}
+
+// HandleMarkRead marks the messages of a conversation as read by the authenticated account up to a message.
+func (m MessageHandler) HandleMarkRead(w http.ResponseWriter, r *http.Request) {
+	accountID := handlers.GetAccountID(r)
+
+	conversationID, err := handlers.GetIntVar(r, "id")
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	var req readRequest
+	err = m.readJSON(r, &req)
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	_, err = m.conversations.GetMemberRole(conversationID, accountID)
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	moved, err := m.messages.MarkRead(conversationID, accountID, req.MessageID)
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	if moved {
+		m.broadcastRead(chat.ReadData{
+			ConversationID: conversationID,
+			MessageID:      req.MessageID,
+			AccountID:      accountID,
+		})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleReceipts lists the members which have read a message. Only available in small conversations.
+func (m MessageHandler) HandleReceipts(w http.ResponseWriter, r *http.Request) {
+	msg, _, err := m.getMessage(r)
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	members, err := m.conversations.GetMemberIDs(msg.ConversationID)
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	err = message.ValidateReceiptsMembers(len(members))
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	receipts, err := m.messages.GetReceipts(msg.ConversationID, msg.ID)
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+
+	// The receipts of the members which blocked the account are hidden.
+	blockers, err := m.blocks.GetBlockerIDs(handlers.GetAccountID(r), members)
+	if err != nil {
+		m.handleError(w, err)
+		return
+	}
+	hidden := make(map[int]bool, len(blockers))
+	for _, id := range blockers {
+		hidden[id] = true
+	}
+	visible := []message.Receipt{}
+	for _, receipt := range receipts {
+		if !hidden[receipt.AccountID] {
+			visible = append(visible, receipt)
+		}
+	}
+
+	m.writer.JSON(w, http.StatusOK, handlers.Hash{
+		"receipts": visible,
+	})
+}
+
+// getMessage gets the message of the request route and the role of the authenticated account in its conversation.
+// The messages hidden in the history of the account are not found, like the messages sent before it joined
+// the conversation or by the accounts it blocked.
+func (m MessageHandler) getMessage(r *http.Request) (msg message.Message, role conversation.Role, err error) {
+	conversationID, err := handlers.GetIntVar(r, "id")
+	if err != nil {
+		return
+	}
+
+	messageID, err := handlers.GetIntVar(r, "message_id")
+	if err != nil {
+		return
+	}
+
+	membership, err := m.conversations.GetMembership(conversationID, handlers.GetAccountID(r))
+	if err != nil {
+		return
+	}
+	role = membership.Role
+
+	msg, err = m.messages.GetMessage(conversationID, handlers.GetAccountID(r), messageID)
+	return
+}
+
+// broadcast sends the change of the message to the online members of its conversation,
+// except the members which have blocked its author.
+func (m MessageHandler) broadcast(t chat.EventType, msg message.Message) {
+	members, err := m.conversations.GetMemberIDs(msg.ConversationID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	blockers, err := m.blocks.GetBlockerIDs(msg.AccountID, members)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	event, err := chat.NewEvent(t, "", msg)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	m.hub.SendToAccounts(conversation.ExcludeIDs(members, blockers), event)
+}
+
+// broadcastRead sends the read cursor move to the online members of the conversation,
+// except the members blocked by the reader.
+func (m MessageHandler) broadcastRead(data chat.ReadData) {
+	members, err := m.conversations.GetMemberIDs(data.ConversationID)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	blocked, err := m.blocks.GetBlockedIDs(data.AccountID, members)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	event, err := chat.NewEvent(chat.ReadEvent, "", data)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	m.hub.SendToAccounts(conversation.ExcludeIDs(members, blocked), event)
+}
+
+func (m MessageHandler) readJSON(r *http.Request, v interface{}) (err error) {
+	err = m.reader.JSON(r, v)
+	if err != nil {
+		if _, ok := err.(sErrors.ClientError); !ok {
+			err = sErrors.NewClientError(http.StatusBadRequest, "invalid body: %s", err)
+		}
+	}
+	return
+}
+
+func (m MessageHandler) handleError(w http.ResponseWriter, err error) {
+	hErr, ok := err.(sErrors.ClientError)
+	if !ok {
+		log.Println(err)
+		m.writer.JSON(w, http.StatusInternalServerError, handlers.Hash{
+			"message": sErrors.SERVER_ERROR_MESSAGE,
+		})
+		return
+	}
+	m.writer.JSON(w, hErr.HTTPCode(), handlers.Hash{
+		"message": hErr.Error(),
+	})
+}
@@ -0,0 +1,3 @@
+// Package presence implements the presence and last seen queries of the accounts.
+
+package presence
@@ -0,0 +1,178 @@
+package presence
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coffemanfp/chat/account"
+	"github.com/coffemanfp/chat/conversation"
+	"github.com/coffemanfp/chat/database"
+	sErrors "github.com/coffemanfp/chat/errors"
+	"github.com/coffemanfp/chat/server/handlers"
+	"github.com/coffemanfp/chat/server/handlers/chat"
+)
+
+// PresenceHandler handles the presence requests.
+// The presence status is taken from the online connections and the last seen from the sessions activity.
+type PresenceHandler struct {
+	hub        *chat.Hub
+	repository database.PresenceRepository
+	blocks     database.BlockRepository
+	writer     handlers.ResponseWriter
+	reader     handlers.RequestReader
+}
+
+// NewPresenceHandler initializes a new PresenceHandler instance.
+//
+//	@param hub *chat.Hub: keeps the online connections.
+//	@param repo database.PresenceRepository: PresenceRepository interface for the last seen handling.
+//	@param blocks database.BlockRepository: BlockRepository interface for the blocks enforcement.
+//	@param r handlers.RequestReader: RequestReader interface for reading request body operations.
+//	@param w handlers.ResponseWriter: ResponseWriter interface for writing request response operations.
+//	@return p PresenceHandler: new PresenceHandler instance.
+func NewPresenceHandler(hub *chat.Hub, repo database.PresenceRepository, blocks database.BlockRepository, r handlers.RequestReader, w handlers.ResponseWriter) (p PresenceHandler) {
+	return PresenceHandler{
+		hub:        hub,
+		repository: repo,
+		blocks:     blocks,
+		writer:     w,
+		reader:     r,
+	}
+}
+
+// settingsRequest is the body of a presence settings update.
+type settingsRequest struct {
+	LastSeenVisibility account.LastSeenVisibility `json:"last_seen_visibility"`
+}
+
+// HandleGet gets the presence of the accounts of the account_ids query param, separated by commas.
+// The accounts which have blocked the authenticated account are shown as offline.
+func (p PresenceHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	viewerID := handlers.GetAccountID(r)
+
+	ids, err := getIDsQuery(r, "account_ids")
+	if err != nil {
+		p.handleError(w, err)
+		return
+	}
+
+	blockers, err := p.blocks.GetBlockerIDs(viewerID, ids)
+	if err != nil {
+		p.handleError(w, err)
+		return
+	}
+	visible := conversation.ExcludeIDs(ids, blockers)
+
+	lastSeen, err := p.repository.GetLastSeen(viewerID, visible)
+	if err != nil {
+		p.handleError(w, err)
+		return
+	}
+
+	hidden := make(map[int]bool, len(blockers))
+	for _, id := range blockers {
+		hidden[id] = true
+	}
+
+	now := time.Now()
+	presences := make([]account.Presence, 0, len(ids))
+	for _, id := range ids {
+		if hidden[id] {
+			presences = append(presences, account.NewPresence(id, false, time.Time{}, nil, now))
+			continue
+		}
+
+		var seen *time.Time
+		if s, ok := lastSeen[id]; ok {
+			seen = &s
+		}
+		lastActivity, online := p.hub.Activity(id)
+		presences = append(presences, account.NewPresence(id, online, lastActivity, seen, now))
+	}
+
+	p.writer.JSON(w, http.StatusOK, handlers.Hash{
+		"presences": presences,
+	})
+}
+
+// HandleGetSettings gets the presence settings of the authenticated account.
+func (p PresenceHandler) HandleGetSettings(w http.ResponseWriter, r *http.Request) {
+	visibility, err := p.repository.GetLastSeenVisibility(handlers.GetAccountID(r))
+	if err != nil {
+		p.handleError(w, err)
+		return
+	}
+
+	p.writer.JSON(w, http.StatusOK, settingsRequest{
+		LastSeenVisibility: visibility,
+	})
+}
+
+// HandleUpdateSettings updates the presence settings of the authenticated account.
+func (p PresenceHandler) HandleUpdateSettings(w http.ResponseWriter, r *http.Request) {
+	var req settingsRequest
+	err := p.reader.JSON(r, &req)
+	if err != nil {
+		if _, ok := err.(sErrors.ClientError); !ok {
+			err = sErrors.NewClientError(http.StatusBadRequest, "invalid body: %s", err)
+		}
+		p.handleError(w, err)
+		return
+	}
+
+	err = account.ValidateLastSeenVisibility(req.LastSeenVisibility)
+	if err != nil {
+		p.handleError(w, err)
+		return
+	}
+
+	err = p.repository.SetLastSeenVisibility(handlers.GetAccountID(r), req.LastSeenVisibility)
+	if err != nil {
+		p.handleError(w, err)
+		return
+	}
+
+	p.writer.JSON(w, http.StatusOK, req)
+}
+
+// getIDsQuery gets the unique positive ids of a query param separated by commas.
+func getIDsQuery(r *http.Request, name string) (ids []int, err error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		err = sErrors.NewClientError(http.StatusBadRequest, "invalid %s: missing %s", name, name)
+		return
+	}
+
+	for _, rawID := range strings.Split(raw, ",") {
+		var id int
+		id, err = strconv.Atoi(strings.TrimSpace(rawID))
+		if err != nil || id <= 0 {
+			err = sErrors.NewClientError(http.StatusBadRequest, "invalid %s: %s must be positive integers", name, name)
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	ids = conversation.UniqueIDs(ids, 0)
+	if len(ids) > account.MaxPresenceAccounts {
+		err = sErrors.NewClientError(http.StatusBadRequest, "invalid %s: max %d accounts", name, account.MaxPresenceAccounts)
+	}
+	return
+}
+
+func (p PresenceHandler) handleError(w http.ResponseWriter, err error) {
+	hErr, ok := err.(sErrors.ClientError)
+	if !ok {
+		log.Println(err)
+		p.writer.JSON(w, http.StatusInternalServerError, handlers.Hash{
+			"message": sErrors.SERVER_ERROR_MESSAGE,
+		})
+		return
+	}
+	p.writer.JSON(w, hErr.HTTPCode(), handlers.Hash{
+		"message": hErr.Error(),
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 
 	sErrors "github.com/coffemanfp/chat/errors"
@@ -26,8 +27,8 @@ var requestReaderImpl *RequestReaderImpl
 
 func (rR RequestReaderImpl) JSON(r *http.Request, v interface{}) (err error) {
 	if r == nil {
-		err = fmt.Errorf("invalid request value: empty or nil *http.Request")
-		err = sErrors.NewClientError(http.StatusInternalServerError, sErrors.SERVER_ERROR_MESSAGE, err)
+		log.Println("invalid request value: empty or nil *http.Request")
+		err = sErrors.NewClientError(http.StatusInternalServerError, sErrors.SERVER_ERROR_MESSAGE)
 		return
 	}
 	if !checkContentTypeJSON(r.Header) {
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	sErrors "github.com/coffemanfp/chat/errors"
+	"github.com/gorilla/mux"
+)
+
+// GetIntVar gets a positive integer route variable of the request.
+//
+//	@param r *http.Request: request to read.
+//	@param name string: route variable name.
+//	@return v int: variable value.
+//	@return err error: missing or invalid variable ClientError.
+func GetIntVar(r *http.Request, name string) (v int, err error) {
+	v, err = strconv.Atoi(mux.Vars(r)[name])
+	if err != nil || v <= 0 {
+		err = sErrors.NewClientError(http.StatusBadRequest, "invalid %s: %s must be a positive integer", name, name)
+	}
+	return
+}
+
+// GetIntQuery gets a integer query param of the request.
+//
+//	@param r *http.Request: request to read.
+//	@param name string: query param name.
+//	@param def int: value of the missing param.
+//	@return v int: param value.
+//	@return err error: invalid param ClientError.
+func GetIntQuery(r *http.Request, name string, def int) (v int, err error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		v = def
+		return
+	}
+	v, err = strconv.Atoi(raw)
+	if err != nil {
+		err = sErrors.NewClientError(http.StatusBadRequest, "invalid %s: %s must be a integer", name, name)
+	}
+	return
+}
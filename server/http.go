@@ -2,13 +2,30 @@ package server
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"time"
 
+	attachmentUtils "github.com/coffemanfp/chat/attachment"
+	authUtils "github.com/coffemanfp/chat/auth"
 	"github.com/coffemanfp/chat/config"
 	"github.com/coffemanfp/chat/database"
+	"github.com/coffemanfp/chat/mail"
+	"github.com/coffemanfp/chat/mail/memory"
+	"github.com/coffemanfp/chat/mail/smtp"
 	"github.com/coffemanfp/chat/server/handlers"
+	"github.com/coffemanfp/chat/server/handlers/account"
+	"github.com/coffemanfp/chat/server/handlers/attachment"
 	"github.com/coffemanfp/chat/server/handlers/auth"
+	"github.com/coffemanfp/chat/server/handlers/block"
+	"github.com/coffemanfp/chat/server/handlers/chat"
+	"github.com/coffemanfp/chat/server/handlers/contact"
+	"github.com/coffemanfp/chat/server/handlers/conversation"
+	"github.com/coffemanfp/chat/server/handlers/message"
+	"github.com/coffemanfp/chat/server/handlers/presence"
+	"github.com/coffemanfp/chat/storage"
+	"github.com/coffemanfp/chat/storage/local"
+	"github.com/coffemanfp/chat/storage/s3"
 	muxhandlers "github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 )
@@ -31,21 +48,74 @@ func (s Server) Run() (err error) {
 //	@param port int: port to listening.
 //	@return $1 *Server: new *Server instance.
 func NewServer(conf config.ConfigInfo, db database.Database, host string, port int) (server *Server, err error) {
+	sessions, err := database.GetSessionRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	tokens, err := newJWTManager(conf)
+	if err != nil {
+		return
+	}
+
+	hub := chat.NewHub()
+	ah, err := newAuthHandler(conf, db, tokens, hub)
+	if err != nil {
+		return
+	}
+
+	store, err := newBlobStore(conf)
+	if err != nil {
+		return
+	}
+	signer := attachmentUtils.NewSigner(conf.Server.SecretKey, conf.Server.PublicURL+"/api/v1/files")
+
 	r := mux.NewRouter().StrictSlash(true)
 	v1R := r.PathPrefix("/api/v1").Subrouter()
 	privateR := v1R.NewRoute().Subrouter()
-	privateR.Use(verifyJWTMiddleware(conf))
+	privateR.Use(verifyJWTMiddleware(tokens, sessions, ah))
 
 	setUpMiddlewares(r, conf)
 	setUpAPIHandlers(r)
-	err = setUpAuthHandlers(v1R, conf, db)
+	setUpAuthHandlers(r, v1R, privateR, ah)
+	err = setUpChatHandlers(privateR, conf, db, hub)
+	if err != nil {
+		return
+	}
+	err = setUpConversationHandlers(privateR, db, signer)
+	if err != nil {
+		return
+	}
+	err = setUpContactHandlers(privateR, db)
+	if err != nil {
+		return
+	}
+	err = setUpBlockHandlers(privateR, db)
+	if err != nil {
+		return
+	}
+	err = setUpMessageHandlers(privateR, conf, db, hub)
+	if err != nil {
+		return
+	}
+	err = setUpPresenceHandlers(privateR, db, hub)
+	if err != nil {
+		return
+	}
+	err = setUpAttachmentHandlers(v1R, privateR, conf, db, store, signer)
+	if err != nil {
+		return
+	}
+	err = setUpAccountHandlers(privateR, conf, db, signer, hub)
 	if err != nil {
 		return
 	}
 	server = &Server{
 		srv: &http.Server{
 			Handler: muxhandlers.CORS(
-				muxhandlers.AllowedHeaders([]string{"content-type"}),
+				muxhandlers.AllowedHeaders([]string{"content-type", "authorization"}),
+				muxhandlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}),
+				muxhandlers.ExposedHeaders([]string{sessionTokenHeader, refreshTokenHeader}),
 				muxhandlers.AllowedOrigins([]string{"*"}),
 				muxhandlers.AllowCredentials(),
 			)(r),
@@ -70,19 +140,307 @@ func setUpMiddlewares(r *mux.Router, conf config.ConfigInfo) {
 	r.Use(muxhandlers.CORS(muxhandlers.AllowedOrigins(conf.Server.AllowedOrigins)))
 }
 
-func setUpAuthHandlers(r *mux.Router, conf config.ConfigInfo, db database.Database) (err error) {
+func newJWTManager(conf config.ConfigInfo) (tokens authUtils.JWTManager, err error) {
+	var keys []authUtils.SigningKey
+	for _, k := range conf.Server.Token.Keys {
+		var key authUtils.SigningKey
+		key, err = authUtils.LoadSigningKey(k.ID, k.Algorithm, k.PrivateKeyFile, k.PublicKeyFile, k.VerifyUntil)
+		if err != nil {
+			return
+		}
+		keys = append(keys, key)
+	}
+
+	keySet, err := authUtils.NewKeySet(conf.Server.Token.SigningKeyID, keys)
+	if err != nil {
+		return
+	}
+
+	tokens = authUtils.NewJWTManager(
+		conf.Server.SecretKey,
+		keySet,
+		conf.Server.Token.Issuer,
+		conf.Server.Token.Audience,
+		conf.Server.Token.AccessLifetime,
+	)
+	return
+}
+
+// newBlobStore initializes the blob store of the configured driver.
+func newBlobStore(conf config.ConfigInfo) (store storage.BlobStore, err error) {
+	switch conf.Storage.Driver {
+	case "local":
+		store, err = local.NewBlobStore(conf.Storage.Local.Dir)
+	case "s3":
+		store, err = s3.NewBlobStore(s3.Properties{
+			Endpoint:        conf.Storage.S3.Endpoint,
+			Region:          conf.Storage.S3.Region,
+			Bucket:          conf.Storage.S3.Bucket,
+			AccessKeyID:     conf.Storage.S3.AccessKeyID,
+			SecretAccessKey: conf.Storage.S3.SecretAccessKey,
+		})
+	default:
+		err = fmt.Errorf("invalid storage driver: unknown driver %s", conf.Storage.Driver)
+	}
+	return
+}
+
+// newMailer initializes the mailer of the configured driver.
+func newMailer(conf config.ConfigInfo) (mailer mail.Mailer, err error) {
+	switch conf.Mail.Driver {
+	case "smtp":
+		mailer, err = smtp.NewMailer(smtp.Properties{
+			Host:     conf.Mail.SMTP.Host,
+			Port:     conf.Mail.SMTP.Port,
+			Username: conf.Mail.SMTP.Username,
+			Password: conf.Mail.SMTP.Password,
+			From:     conf.Mail.From,
+		})
+	case "memory":
+		log.Println("WARNING: the memory mail driver doesn't send the emails, the email verifications and password resets are not delivered")
+		mailer = memory.NewMailer()
+	default:
+		err = fmt.Errorf("invalid mail driver: unknown driver %s", conf.Mail.Driver)
+	}
+	return
+}
+
+func newAuthHandler(conf config.ConfigInfo, db database.Database, tokens authUtils.JWTManager, hub *chat.Hub) (ah auth.AuthHandler, err error) {
 	repo, err := database.GetAuthRepository(db.Repositories)
 	if err != nil {
 		return
 	}
 
-	ah := auth.NewAuthHandler(
+	sessions, err := database.GetSessionRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	mailer, err := newMailer(conf)
+	if err != nil {
+		return
+	}
+
+	ah = auth.NewAuthHandler(
 		repo,
+		sessions,
+		tokens,
+		hub,
+		mailer,
 		handlers.GetRequestReaderImpl(),
 		handlers.GetResponseWriterImpl(),
 		conf,
 	)
+	return
+}
 
+func setUpAuthHandlers(rootR, r, privateR *mux.Router, ah auth.AuthHandler) {
+	rootR.HandleFunc("/.well-known/jwks.json", ah.HandleJWKS).Methods("GET")
+	privateR.HandleFunc("/auth/logout", ah.HandleLogout).Methods("POST")
+	privateR.HandleFunc("/auth/verification", ah.HandleSendVerification).Methods("POST")
+	privateR.HandleFunc("/auth/{provider}/link", ah.HandleLinkStart).Methods("POST")
 	r.HandleFunc("/auth/{action}", ah.HandleAuth).Methods("POST")
+	r.HandleFunc("/auth/{provider}/start", ah.HandleExternalStart).Methods("GET")
+	r.HandleFunc("/auth/{provider}/callback", ah.HandleExternalCallback).Methods("GET")
+}
+
+func setUpChatHandlers(privateR *mux.Router, conf config.ConfigInfo, db database.Database, hub *chat.Hub) (err error) {
+	messages, err := database.GetMessageRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	conversations, err := database.GetConversationRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	blocks, err := database.GetBlockRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	sessions, err := database.GetSessionRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	attachments, err := database.GetAttachmentRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	ch := chat.NewChatHandler(hub, messages, conversations, blocks, sessions, attachments, conf)
+	privateR.HandleFunc("/ws", ch.HandleWebSocket).Methods("GET")
+	return
+}
+
+func setUpConversationHandlers(privateR *mux.Router, db database.Database, signer attachmentUtils.Signer) (err error) {
+	repo, err := database.GetConversationRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	blocks, err := database.GetBlockRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	attachments, err := database.GetAttachmentRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	ch := conversation.NewConversationHandler(repo, blocks, attachments, signer, handlers.GetRequestReaderImpl(), handlers.GetResponseWriterImpl())
+	privateR.HandleFunc("/conversations", ch.HandleCreate).Methods("POST")
+	privateR.HandleFunc("/conversations", ch.HandleList).Methods("GET")
+	privateR.HandleFunc("/conversations/{id}", ch.HandleGet).Methods("GET")
+	privateR.HandleFunc("/conversations/{id}", ch.HandleUpdate).Methods("PATCH")
+	privateR.HandleFunc("/conversations/{id}", ch.HandleDelete).Methods("DELETE")
+	privateR.HandleFunc("/conversations/{id}/members", ch.HandleAddMembers).Methods("POST")
+	privateR.HandleFunc("/conversations/{id}/members/{account_id}", ch.HandleKick).Methods("DELETE")
+	privateR.HandleFunc("/conversations/{id}/members/{account_id}/role", ch.HandleSetRole).Methods("PUT")
+	privateR.HandleFunc("/conversations/{id}/leave", ch.HandleLeave).Methods("POST")
+	privateR.HandleFunc("/conversations/{id}/invites", ch.HandleCreateInvite).Methods("POST")
+	privateR.HandleFunc("/conversations/{id}/invites", ch.HandleListInvites).Methods("GET")
+	privateR.HandleFunc("/conversations/{id}/invites/{invite_id}", ch.HandleRevokeInvite).Methods("DELETE")
+	privateR.HandleFunc("/invites/{token}/join", ch.HandleJoin).Methods("POST")
+	privateR.HandleFunc("/conversations/{id}/roles", ch.HandleListRoles).Methods("GET")
+	privateR.HandleFunc("/conversations/{id}/roles", ch.HandleCreateRole).Methods("POST")
+	privateR.HandleFunc("/conversations/{id}/roles/{role_id}", ch.HandleUpdateRole).Methods("PATCH")
+	privateR.HandleFunc("/conversations/{id}/roles/{role_id}", ch.HandleDeleteRole).Methods("DELETE")
+	return
+}
+
+func setUpContactHandlers(privateR *mux.Router, db database.Database) (err error) {
+	repo, err := database.GetContactRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	ch := contact.NewContactHandler(repo, handlers.GetRequestReaderImpl(), handlers.GetResponseWriterImpl())
+	privateR.HandleFunc("/contacts", ch.HandleAdd).Methods("POST")
+	privateR.HandleFunc("/contacts", ch.HandleList).Methods("GET")
+	privateR.HandleFunc("/contacts/{id}", ch.HandleGet).Methods("GET")
+	privateR.HandleFunc("/contacts/{id}", ch.HandleRename).Methods("PATCH")
+	privateR.HandleFunc("/contacts/{id}", ch.HandleRemove).Methods("DELETE")
+	return
+}
+
+func setUpBlockHandlers(privateR *mux.Router, db database.Database) (err error) {
+	repo, err := database.GetBlockRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	bh := block.NewBlockHandler(repo, handlers.GetRequestReaderImpl(), handlers.GetResponseWriterImpl())
+	privateR.HandleFunc("/blocks", bh.HandleBlock).Methods("POST")
+	privateR.HandleFunc("/blocks", bh.HandleList).Methods("GET")
+	privateR.HandleFunc("/blocks/{account_id}", bh.HandleUnblock).Methods("DELETE")
+	return
+}
+
+func setUpMessageHandlers(privateR *mux.Router, conf config.ConfigInfo, db database.Database, hub *chat.Hub) (err error) {
+	messages, err := database.GetMessageRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	conversations, err := database.GetConversationRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	blocks, err := database.GetBlockRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	mh := message.NewMessageHandler(hub, messages, conversations, blocks, handlers.GetRequestReaderImpl(), handlers.GetResponseWriterImpl(), conf)
+	privateR.HandleFunc("/conversations/{id}/messages", mh.HandleHistory).Methods("GET")
+	privateR.HandleFunc("/conversations/{id}/messages/{message_id}", mh.HandleEdit).Methods("PATCH")
+	privateR.HandleFunc("/conversations/{id}/messages/{message_id}", mh.HandleDelete).Methods("DELETE")
+	privateR.HandleFunc("/conversations/{id}/messages/{message_id}/revisions", mh.HandleRevisions).Methods("GET")
+	privateR.HandleFunc("/conversations/{id}/messages/{message_id}/receipts", mh.HandleReceipts).Methods("GET")
+	privateR.HandleFunc("/conversations/{id}/read", mh.HandleMarkRead).Methods("POST")
+	return
+}
+
+func setUpPresenceHandlers(privateR *mux.Router, db database.Database, hub *chat.Hub) (err error) {
+	repo, err := database.GetPresenceRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	blocks, err := database.GetBlockRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	ph := presence.NewPresenceHandler(hub, repo, blocks, handlers.GetRequestReaderImpl(), handlers.GetResponseWriterImpl())
+	privateR.HandleFunc("/presence", ph.HandleGet).Methods("GET")
+	privateR.HandleFunc("/presence/settings", ph.HandleGetSettings).Methods("GET")
+	privateR.HandleFunc("/presence/settings", ph.HandleUpdateSettings).Methods("PUT")
+	return
+}
+
+func setUpAttachmentHandlers(r, privateR *mux.Router, conf config.ConfigInfo, db database.Database, store storage.BlobStore, signer attachmentUtils.Signer) (err error) {
+	repo, err := database.GetAttachmentRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	processor := attachment.NewProcessor(repo, store, conf.Storage.ThumbnailWorkers)
+	processor.Start()
+
+	ah := attachment.NewAttachmentHandler(repo, store, signer, processor, handlers.GetResponseWriterImpl(), conf)
+	privateR.HandleFunc("/attachments", ah.HandleUpload).Methods("POST")
+	privateR.HandleFunc("/attachments/{id}", ah.HandleGet).Methods("GET")
+
+	// The downloads are authorized by the URL signature.
+	r.HandleFunc("/files/{id}", ah.HandleDownload).Methods("GET", "HEAD")
+	return
+}
+
+func setUpAccountHandlers(privateR *mux.Router, conf config.ConfigInfo, db database.Database, signer attachmentUtils.Signer, hub *chat.Hub) (err error) {
+	repo, err := database.GetAccountRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	attachments, err := database.GetAttachmentRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	blocks, err := database.GetBlockRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	contacts, err := database.GetContactRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	conversations, err := database.GetConversationRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	messages, err := database.GetMessageRepository(db.Repositories)
+	if err != nil {
+		return
+	}
+
+	account.NewPurger(repo, conf.Server.Accounts.DeletionGracePeriod).Start()
+
+	ah := account.NewAccountHandler(repo, blocks, contacts, conversations, messages, attachments, signer, hub, handlers.GetRequestReaderImpl(), handlers.GetResponseWriterImpl(), conf)
+	privateR.HandleFunc("/accounts/me", ah.HandleGetMe).Methods("GET")
+	privateR.HandleFunc("/accounts/me", ah.HandleUpdateMe).Methods("PATCH")
+	privateR.HandleFunc("/accounts/me", ah.HandleDeleteMe).Methods("DELETE")
+	privateR.HandleFunc("/accounts/me/export", ah.HandleExport).Methods("GET")
+	privateR.HandleFunc("/accounts/me/picture", ah.HandleSetPicture).Methods("PUT")
+	privateR.HandleFunc("/accounts/{nickname}", ah.HandleGetProfile).Methods("GET")
 	return
 }
@@ -1,59 +1,85 @@
 package server
 
 import (
-	"context"
-	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"strings"
 
-	"github.com/coffemanfp/chat/config"
-	"github.com/golang-jwt/jwt"
+	authUtils "github.com/coffemanfp/chat/auth"
+	"github.com/coffemanfp/chat/database"
+	sErrors "github.com/coffemanfp/chat/errors"
+	"github.com/coffemanfp/chat/server/handlers"
+	"github.com/coffemanfp/chat/server/handlers/auth"
 	muxhandlers "github.com/gorilla/handlers"
+	"github.com/gorilla/websocket"
 )
 
+// logginMiddleware logs the requests, redacting the access token of the WebSocket upgrades.
 func logginMiddleware(next http.Handler) http.Handler {
-	return muxhandlers.LoggingHandler(os.Stdout, next)
+	logger := muxhandlers.LoggingHandler(os.Stdout, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.ServeHTTP(w, redactAccessToken(r))
+	})
 }
 
-func verifyJWTMiddleware(conf config.ConfigInfo) func(next http.Handler) http.Handler {
+// redactAccessToken gets a shallow copy of the request with the access token param redacted
+// on its RequestURI, which is the URI written by the logger. Its URL keeps the access token.
+func redactAccessToken(r *http.Request) *http.Request {
+	q := r.URL.Query()
+	if q.Get(accessTokenParam) == "" {
+		return r
+	}
+	q.Set(accessTokenParam, "REDACTED")
+
+	u := *r.URL
+	u.RawQuery = q.Encode()
+
+	redacted := r.WithContext(r.Context())
+	redacted.RequestURI = u.RequestURI()
+	return redacted
+}
+
+const (
+	// sessionTokenHeader is the response header used to send the access token after a TmpID exchange.
+	sessionTokenHeader = "X-Session-Token"
+
+	// refreshTokenHeader is the response header used to send the refresh token after a TmpID exchange.
+	refreshTokenHeader = "X-Refresh-Token"
+
+	// accessTokenParam is the query param used to send the access token on the WebSocket upgrades,
+	// because the browsers can't set the Authorization header of a WebSocket request.
+	accessTokenParam = "access_token"
+)
+
+func verifyJWTMiddleware(tokens authUtils.JWTManager, sessions database.SessionRepository, ah auth.AuthHandler) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
-		return authHandler{next, conf}
+		return authHandler{next, tokens, sessions, ah}
 	}
 }
 
 type authHandler struct {
-	h    http.Handler
-	conf config.ConfigInfo
+	h        http.Handler
+	tokens   authUtils.JWTManager
+	sessions database.SessionRepository
+	ah       auth.AuthHandler
 }
 
 func (a authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Header["Authorization"] != nil {
-		tokenString := r.Header["Authorization"][0][7:]
+		scheme, credential := splitAuthorization(r.Header.Get("Authorization"))
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-
-			return []byte(a.conf.Server.SecretKey), nil
-		})
-		if err != nil {
-			fmt.Println(err)
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte("You're Unauthorized due to error parsing the JWT"))
-			return
-		}
-
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			ctx := context.WithValue(r.Context(), "id", int(claims["id"].(float64)))
-			a.h.ServeHTTP(w, r.WithContext(ctx))
-		} else {
+		switch scheme {
+		case "bearer":
+			a.serveWithToken(w, r, credential)
+		case "tmpid":
+			a.serveWithTmpID(w, r, credential)
+		default:
 			w.WriteHeader(http.StatusUnauthorized)
-			_, err := w.Write([]byte("You're Unauthorized due to invalid token"))
-			if err != nil {
-				return
-			}
+			w.Write([]byte("You're Unauthorized due to unsupported authorization scheme"))
 		}
+	} else if token := r.URL.Query().Get(accessTokenParam); token != "" && websocket.IsWebSocketUpgrade(r) {
+		a.serveWithToken(w, r, token)
 	} else {
 		w.WriteHeader(http.StatusUnauthorized)
 		_, err := w.Write([]byte("You're Unauthorized due to No token in the header"))
@@ -63,6 +89,61 @@ func (a authHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (a authHandler) serveWithToken(w http.ResponseWriter, r *http.Request, tokenString string) {
+	claims, err := a.tokens.Parse(tokenString)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("You're Unauthorized due to error parsing the JWT"))
+		return
+	}
+
+	actived, err := a.sessions.UpdateLastSeen(claims.SessionID)
+	if err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("Failed to check the session of the token"))
+		return
+	}
+	if !actived {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("You're Unauthorized due to closed session"))
+		return
+	}
+
+	a.h.ServeHTTP(w, handlers.WithAuth(r, claims.AccountID, claims.SessionID))
+}
+
+// serveWithTmpID exchanges the one use TmpID of a new session on its first auth-required call.
+// The session tokens are sent back in the session and refresh token headers.
+func (a authHandler) serveWithTmpID(w http.ResponseWriter, r *http.Request, tmpID string) {
+	session, tokens, err := a.ah.ExchangeTmpID(tmpID)
+	if err != nil {
+		if _, ok := err.(sErrors.ClientError); !ok {
+			log.Println(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("Failed to exchange the tmp id"))
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("You're Unauthorized due to invalid tmp id"))
+		return
+	}
+
+	w.Header().Set(sessionTokenHeader, tokens.AccessToken)
+	w.Header().Set(refreshTokenHeader, tokens.RefreshToken)
+	a.h.ServeHTTP(w, handlers.WithAuth(r, session.AccountID, session.ID))
+}
+
+// splitAuthorization splits the Authorization header value in its lowercased scheme and credential.
+func splitAuthorization(v string) (scheme, credential string) {
+	parts := strings.SplitN(strings.TrimSpace(v), " ", 2)
+	if len(parts) != 2 {
+		return
+	}
+	return strings.ToLower(parts[0]), strings.TrimSpace(parts[1])
+}
+
 // func verifyJWT(endpointHandler func(writer http.ResponseWriter, r *http.Request)) http.HandlerFunc {
 // 	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 // 		if request.Header["Authorization"] != nil {
@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrBlobNotFound is returned when a blob doesn't exists in the store.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore defines the behaviors to be used by a BlobStore implementation.
+// The blobs are immutable, a key always keeps the same content.
+type BlobStore interface {
+
+	// Put stores a blob, replacing the blob of the same key.
+	//	@param key string: blob key, a relative path separated by "/".
+	//	@param r io.Reader: blob content.
+	//	@param size int64: blob size in bytes.
+	//	@param contentType string: MIME type of the blob.
+	//	@return $1 error: store error.
+	Put(key string, r io.Reader, size int64, contentType string) error
+
+	// Get opens a blob to read its content. The reader must be closed.
+	//	@param key string: blob key.
+	//	@return $1 io.ReadCloser: blob content.
+	//	@return $2 error: ErrBlobNotFound or store error.
+	Get(key string) (io.ReadCloser, error)
+
+	// Delete removes a blob. Deleting a missing blob is not a error.
+	//	@param key string: blob key.
+	//	@return $1 error: store error.
+	Delete(key string) error
+}
@@ -0,0 +1,3 @@
+// Package storage defines the interfaces to be implemented for the different file stores.
+
+package storage
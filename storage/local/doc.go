@@ -0,0 +1,3 @@
+// Package local implements the blob store on the local filesystem.
+
+package local
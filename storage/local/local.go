@@ -0,0 +1,117 @@
+package local
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coffemanfp/chat/storage"
+)
+
+// BlobStore is the implementation of a blob store on a local directory.
+type BlobStore struct {
+	dir string
+}
+
+// NewBlobStore initializes a new local blob store, creating its directory if it doesn't exists.
+//
+//	@param dir string: root directory of the blobs.
+//	@return store storage.BlobStore: is the final interface to keep the BlobStore implementation.
+//	@return err error: directory creation error.
+func NewBlobStore(dir string) (store storage.BlobStore, err error) {
+	err = os.MkdirAll(dir, 0o750)
+	if err != nil {
+		err = fmt.Errorf("failed to create blob store directory %s: %s", dir, err)
+		return
+	}
+	store = BlobStore{
+		dir: dir,
+	}
+	return
+}
+
+func (b BlobStore) Put(key string, r io.Reader, size int64, contentType string) (err error) {
+	path, err := b.path(key)
+	if err != nil {
+		return
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o750)
+	if err != nil {
+		err = fmt.Errorf("failed to create blob directory: %s", err)
+		return
+	}
+
+	// The blob is written to a temporary file and then renamed, so the readers never see a partial blob.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		err = fmt.Errorf("failed to create blob %s: %s", key, err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, r)
+	if cErr := tmp.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to write blob %s: %s", key, err)
+		return
+	}
+	if n != size {
+		err = fmt.Errorf("failed to write blob %s: wrote %d of %d bytes", key, n, size)
+		return
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		err = fmt.Errorf("failed to write blob %s: %s", key, err)
+	}
+	return
+}
+
+func (b BlobStore) Get(key string) (rc io.ReadCloser, err error) {
+	path, err := b.path(key)
+	if err != nil {
+		return
+	}
+
+	rc, err = os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = storage.ErrBlobNotFound
+			return
+		}
+		err = fmt.Errorf("failed to open blob %s: %s", key, err)
+	}
+	return
+}
+
+func (b BlobStore) Delete(key string) (err error) {
+	path, err := b.path(key)
+	if err != nil {
+		return
+	}
+
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		err = fmt.Errorf("failed to delete blob %s: %s", key, err)
+		return
+	}
+	err = nil
+	return
+}
+
+// path gets the file path of a key, rejecting the keys outside of the store directory.
+func (b BlobStore) path(key string) (path string, err error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if key == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		err = fmt.Errorf("invalid blob key %s", key)
+		return
+	}
+	path = filepath.Join(b.dir, clean)
+	return
+}
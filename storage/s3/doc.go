@@ -0,0 +1,4 @@
+// Package s3 implements the blob store on a S3 compatible object storage, like AWS S3 or MinIO.
+// The requests are signed with the AWS Signature Version 4.
+
+package s3
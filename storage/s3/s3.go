@@ -0,0 +1,226 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/coffemanfp/chat/storage"
+)
+
+// unsignedPayload is the payload hash of the requests which don't sign their body.
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// Properties keeps the connection properties of a S3 compatible bucket.
+type Properties struct {
+	// Endpoint is the base URL of the storage, like https://s3.us-east-1.amazonaws.com or http://localhost:9000.
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// BlobStore is the implementation of a blob store on a S3 compatible bucket.
+// The bucket is addressed with the path style, supported by the S3 compatible storages.
+type BlobStore struct {
+	props    Properties
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewBlobStore initializes a new S3 blob store.
+//
+//	@param props Properties: connection properties of the bucket.
+//	@return store storage.BlobStore: is the final interface to keep the BlobStore implementation.
+//	@return err error: invalid properties error.
+func NewBlobStore(props Properties) (store storage.BlobStore, err error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(props.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		err = fmt.Errorf("invalid s3 endpoint %s", props.Endpoint)
+		return
+	}
+	if props.Bucket == "" || props.Region == "" {
+		err = fmt.Errorf("invalid s3 properties: missing bucket or region")
+		return
+	}
+
+	store = BlobStore{
+		props:    props,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: time.Minute},
+	}
+	return
+}
+
+func (b BlobStore) Put(key string, r io.Reader, size int64, contentType string) (err error) {
+	req, err := b.newRequest(http.MethodPut, key, r)
+	if err != nil {
+		return
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+
+	res, err := b.do(req)
+	if err != nil {
+		return
+	}
+	res.Body.Close()
+	return
+}
+
+func (b BlobStore) Get(key string) (rc io.ReadCloser, err error) {
+	req, err := b.newRequest(http.MethodGet, key, nil)
+	if err != nil {
+		return
+	}
+
+	res, err := b.do(req)
+	if err != nil {
+		return
+	}
+	rc = res.Body
+	return
+}
+
+func (b BlobStore) Delete(key string) (err error) {
+	req, err := b.newRequest(http.MethodDelete, key, nil)
+	if err != nil {
+		return
+	}
+
+	res, err := b.do(req)
+	if err == storage.ErrBlobNotFound {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+	res.Body.Close()
+	return
+}
+
+// newRequest builds a request to the object of the key.
+func (b BlobStore) newRequest(method, key string, body io.Reader) (req *http.Request, err error) {
+	u := *b.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + b.props.Bucket + "/" + strings.TrimPrefix(key, "/")
+	u.RawPath = encodePath(u.Path)
+
+	req, err = http.NewRequest(method, u.String(), body)
+	if err != nil {
+		err = fmt.Errorf("failed to build s3 request of %s: %s", key, err)
+	}
+	return
+}
+
+// do signs and sends the request, mapping the missing objects to storage.ErrBlobNotFound.
+func (b BlobStore) do(req *http.Request) (res *http.Response, err error) {
+	b.sign(req, time.Now().UTC())
+
+	res, err = b.client.Do(req)
+	if err != nil {
+		err = fmt.Errorf("failed to request s3 %s %s: %s", req.Method, req.URL.Path, err)
+		return
+	}
+
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		err = storage.ErrBlobNotFound
+		return
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		res.Body.Close()
+		err = fmt.Errorf("failed to request s3 %s %s: unexpected status %d: %s", req.Method, req.URL.Path, res.StatusCode, msg)
+	}
+	return
+}
+
+// sign adds the AWS Signature Version 4 authorization to the request.
+// The body is not signed, the transport must be protected with TLS.
+func (b BlobStore) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if req.Header.Get("Content-Type") != "" {
+		signedHeaders = append(signedHeaders, "content-type")
+	}
+	sort.Strings(signedHeaders)
+
+	var canonicalHeaders strings.Builder
+	for _, h := range signedHeaders {
+		v := req.Header.Get(h)
+		if h == "host" {
+			// The client sends the host of the URL, not a header.
+			v = req.URL.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		unsignedPayload,
+	}, "\n")
+
+	scope := date + "/" + b.props.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+b.props.SecretAccessKey), date)
+	key = hmacSHA256(key, b.props.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.props.AccessKeyID, scope, strings.Join(signedHeaders, ";"), signature,
+	))
+}
+
+// encodePath encodes the path with the S3 rules: every byte except the unreserved ones and "/".
+func encodePath(path string) string {
+	var sb strings.Builder
+	for _, c := range []byte(path) {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
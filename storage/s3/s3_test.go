@@ -0,0 +1,246 @@
+package s3
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coffemanfp/chat/storage"
+)
+
+const (
+	testAccessKeyID     = "access-key"
+	testSecretAccessKey = "secret-key"
+	testRegion          = "us-east-1"
+	testBucket          = "chat-bucket"
+)
+
+// authorizationRegex parses the Authorization header of a AWS Signature Version 4 request.
+var authorizationRegex = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=([^/]+)/(\d{8})/([^/]+)/s3/aws4_request, SignedHeaders=([a-z0-9;-]+), Signature=([0-9a-f]{64})$`)
+
+// stubS3Server is a S3 compatible server which verifies the signatures of the requests
+// with its secret, and keeps the objects in memory.
+type stubS3Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func newStubS3Server(t *testing.T) (s *stubS3Server) {
+	s = &stubS3Server{objects: make(map[string][]byte)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return
+}
+
+func (s *stubS3Server) serve(w http.ResponseWriter, r *http.Request) {
+	if !verifySignature(r) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("SignatureDoesNotMatch"))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := r.URL.Path
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := ioutil.ReadAll(r.Body)
+		s.objects[key] = body
+	case http.MethodGet:
+		body, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	case http.MethodDelete:
+		if _, ok := s.objects[key]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// verifySignature rebuilds the signature of the request as received by the server.
+func verifySignature(r *http.Request) bool {
+	m := authorizationRegex.FindStringSubmatch(r.Header.Get("Authorization"))
+	if m == nil || m[1] != testAccessKeyID || m[3] != testRegion {
+		return false
+	}
+	date, signedHeaders, signature := m[2], strings.Split(m[4], ";"), m[5]
+
+	amzDate := r.Header.Get("X-Amz-Date")
+	if !strings.HasPrefix(amzDate, date) {
+		return false
+	}
+
+	var headers strings.Builder
+	for _, h := range signedHeaders {
+		v := r.Header.Get(h)
+		if h == "host" {
+			v = r.Host
+		}
+		headers.WriteString(h + ":" + v + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.Query().Encode(),
+		headers.String(),
+		m[4],
+		r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+testSecretAccessKey), date)
+	key = hmacSHA256(key, testRegion)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		date + "/" + testRegion + "/s3/aws4_request",
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign)) == signature
+}
+
+func newTestBlobStore(t *testing.T, endpoint, secret string) BlobStore {
+	store, err := NewBlobStore(Properties{
+		Endpoint:        endpoint,
+		Region:          testRegion,
+		Bucket:          testBucket,
+		AccessKeyID:     testAccessKeyID,
+		SecretAccessKey: secret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store.(BlobStore)
+}
+
+// TestSignKnownRequest checks the signature of a request against a signature computed apart.
+func TestSignKnownRequest(t *testing.T) {
+	store := newTestBlobStore(t, "http://localhost:9000", testSecretAccessKey)
+
+	req, err := store.newRequest(http.MethodPut, "blobs/a b+ñ.png", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "image/png")
+	store.sign(req, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	if got, want := req.URL.EscapedPath(), "/chat-bucket/blobs/a%20b%2B%C3%B1.png"; got != want {
+		t.Errorf("path = %s, want %s", got, want)
+	}
+
+	want := "AWS4-HMAC-SHA256 Credential=access-key/20240102/us-east-1/s3/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date, " +
+		"Signature=18d2f2d129e44177adb484928c0c2f284530efa8d9156a230c37bf309679d387"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %s, want %s", got, want)
+	}
+}
+
+func TestBlobStore(t *testing.T) {
+	srv := newStubS3Server(t)
+
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{name: "hash key", key: "ab/cd/abcdef0123456789"},
+		{name: "variant key", key: "ab/cd/abcdef0123456789_256"},
+		{name: "escaped key", key: "dir/a b+ñ%.png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestBlobStore(t, srv.URL, testSecretAccessKey)
+			content := []byte("content of " + tt.key)
+
+			err := store.Put(tt.key, bytes.NewReader(content), int64(len(content)), "image/png")
+			if err != nil {
+				t.Fatalf("Put failed: %s", err)
+			}
+
+			rc, err := store.Get(tt.key)
+			if err != nil {
+				t.Fatalf("Get failed: %s", err)
+			}
+			got, _ := ioutil.ReadAll(rc)
+			rc.Close()
+			if !bytes.Equal(got, content) {
+				t.Errorf("Get = %q, want %q", got, content)
+			}
+
+			err = store.Delete(tt.key)
+			if err != nil {
+				t.Fatalf("Delete failed: %s", err)
+			}
+
+			_, err = store.Get(tt.key)
+			if err != storage.ErrBlobNotFound {
+				t.Errorf("Get of deleted key error = %v, want ErrBlobNotFound", err)
+			}
+
+			err = store.Delete(tt.key)
+			if err != nil {
+				t.Errorf("Delete of deleted key failed: %s", err)
+			}
+		})
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.objects) != 0 {
+		t.Errorf("objects left = %d, want none", len(srv.objects))
+	}
+}
+
+func TestBlobStoreWrongSecret(t *testing.T) {
+	srv := newStubS3Server(t)
+	store := newTestBlobStore(t, srv.URL, "wrong-secret")
+
+	err := store.Put("ab/cd/key", strings.NewReader("content"), 7, "text/plain")
+	if err == nil || !strings.Contains(err.Error(), "unexpected status 403") {
+		t.Errorf("Put error = %v, want forbidden", err)
+	}
+}
+
+func TestNewBlobStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		props   Properties
+		wantErr bool
+	}{
+		{name: "valid", props: Properties{Endpoint: "https://s3.us-east-1.amazonaws.com", Region: testRegion, Bucket: testBucket}},
+		{name: "endpoint with path", props: Properties{Endpoint: "http://localhost:9000/s3/", Region: testRegion, Bucket: testBucket}},
+		{name: "missing scheme", props: Properties{Endpoint: "localhost:9000", Region: testRegion, Bucket: testBucket}, wantErr: true},
+		{name: "unsupported scheme", props: Properties{Endpoint: "ftp://localhost", Region: testRegion, Bucket: testBucket}, wantErr: true},
+		{name: "missing bucket", props: Properties{Endpoint: "http://localhost:9000", Region: testRegion}, wantErr: true},
+		{name: "missing region", props: Properties{Endpoint: "http://localhost:9000", Bucket: testBucket}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewBlobStore(tt.props)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewBlobStore error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,4 @@
+// Package thumbnail generates the square thumbnails of the uploaded images with the standard library.
+// The thumbnails are re-encoded, so the metadata of the original images, like EXIF, is never kept.
+
+package thumbnail
@@ -0,0 +1,184 @@
+package thumbnail
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"strconv"
+
+	// The GIF decoder is registered for image.Decode.
+	_ "image/gif"
+)
+
+const (
+	// MaxPixels is the max number of pixels of a image to process, to avoid decompression bombs.
+	MaxPixels = 40_000_000
+
+	// jpegQuality is the quality of the JPEG thumbnails.
+	jpegQuality = 85
+)
+
+// Sizes are the sides in pixels of the generated square thumbnails.
+var Sizes = []int{64, 128, 256, 512}
+
+// supportedTypes are the MIME types of the images which can be decoded.
+var supportedTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// Thumbnail is a square thumbnail of a image.
+type Thumbnail struct {
+	// Name is the thumbnail size as text, like "256".
+	Name     string
+	MimeType string
+	Width    int
+	Height   int
+	Data     []byte
+}
+
+// SupportedTypes gets the MIME types of the images which can be processed.
+func SupportedTypes() []string {
+	return append([]string(nil), supportedTypes...)
+}
+
+// Supported checks if the images of the MIME type can be processed.
+func Supported(mimeType string) bool {
+	for _, t := range supportedTypes {
+		if t == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+// Generate generates the square thumbnails of all the Sizes of a image.
+// The image is cropped to its centered square and turned by its EXIF orientation.
+// The JPEG images generate JPEG thumbnails, the others PNG thumbnails to keep the transparency.
+//
+//	@param data []byte: image content.
+//	@param mimeType string: image MIME type.
+//	@return thumbnails []Thumbnail: generated thumbnails, ordered by size.
+//	@return err error: unsupported, too large or invalid image error.
+func Generate(data []byte, mimeType string) (thumbnails []Thumbnail, err error) {
+	if !Supported(mimeType) {
+		err = fmt.Errorf("unsupported image type %s", mimeType)
+		return
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		err = fmt.Errorf("failed to decode image config: %s", err)
+		return
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > MaxPixels {
+		err = fmt.Errorf("invalid image size %dx%d", config.Width, config.Height)
+		return
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		err = fmt.Errorf("failed to decode image: %s", err)
+		return
+	}
+
+	orientation := 1
+	if mimeType == "image/jpeg" {
+		orientation = jpegOrientation(data)
+	}
+
+	square := squareCrop(src)
+	for _, size := range Sizes {
+		img := orient(resize(square, size), orientation)
+
+		var buf bytes.Buffer
+		thumbnail := Thumbnail{
+			Name:   strconv.Itoa(size),
+			Width:  size,
+			Height: size,
+		}
+		if mimeType == "image/jpeg" {
+			thumbnail.MimeType = "image/jpeg"
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+		} else {
+			thumbnail.MimeType = "image/png"
+			err = png.Encode(&buf, img)
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to encode thumbnail %d: %s", size, err)
+			return
+		}
+		thumbnail.Data = buf.Bytes()
+		thumbnails = append(thumbnails, thumbnail)
+	}
+	return
+}
+
+// squareCrop gets the centered square of the image.
+func squareCrop(src image.Image) image.Image {
+	b := src.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+
+	x := b.Min.X + (b.Dx()-side)/2
+	y := b.Min.Y + (b.Dy()-side)/2
+	rect := image.Rect(0, 0, side, side)
+
+	dst := image.NewRGBA(rect)
+	draw.Draw(dst, rect, src, image.Pt(x, y), draw.Src)
+	return dst
+}
+
+// resize scales the square image to the size provided, averaging the source pixels of each
+// destination pixel. The smaller images are scaled up with the nearest pixel.
+func resize(src image.Image, size int) *image.RGBA {
+	rgba, ok := src.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(src.Bounds())
+		draw.Draw(rgba, rgba.Bounds(), src, src.Bounds().Min, draw.Src)
+	}
+	side := rgba.Bounds().Dx()
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0, y1 := span(y, side, size)
+		for x := 0; x < size; x++ {
+			x0, x1 := span(x, side, size)
+
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				i := rgba.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += uint32(rgba.Pix[i])
+					g += uint32(rgba.Pix[i+1])
+					b += uint32(rgba.Pix[i+2])
+					a += uint32(rgba.Pix[i+3])
+					n++
+					i += 4
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// span gets the source pixels range of a destination pixel, with at least a pixel.
+func span(i, side, size int) (start, end int) {
+	start = i * side / size
+	end = (i + 1) * side / size
+	if end <= start {
+		end = start + 1
+	}
+	if end > side {
+		end = side
+	}
+	return
+}
@@ -0,0 +1,326 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// exifSegment builds a JPEG APP1 segment with a EXIF TIFF structure of the entries provided.
+// Each entry is a tag and its SHORT value.
+func exifSegment(order binary.ByteOrder, entries ...[2]uint16) []byte {
+	var tiff bytes.Buffer
+	if order == binary.LittleEndian {
+		tiff.WriteString("II")
+	} else {
+		tiff.WriteString("MM")
+	}
+	binary.Write(&tiff, order, uint16(42))
+	binary.Write(&tiff, order, uint32(8))
+	binary.Write(&tiff, order, uint16(len(entries)))
+	for _, e := range entries {
+		binary.Write(&tiff, order, e[0])
+		binary.Write(&tiff, order, uint16(3))
+		binary.Write(&tiff, order, uint32(1))
+		binary.Write(&tiff, order, e[1])
+		binary.Write(&tiff, order, uint16(0))
+	}
+	binary.Write(&tiff, order, uint32(0))
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// withSegments inserts the segments after the SOI marker of the JPEG image.
+func withSegments(jpegData []byte, segments ...[]byte) []byte {
+	data := append([]byte(nil), jpegData[:2]...)
+	for _, s := range segments {
+		data = append(data, s...)
+	}
+	return append(data, jpegData[2:]...)
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	plain := encodeJPEG(t, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	app0 := []byte{0xFF, 0xE0, 0x00, 0x04, 0x00, 0x00}
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{name: "no exif", data: plain, want: 1},
+		{name: "little endian", data: withSegments(plain, exifSegment(binary.LittleEndian, [2]uint16{exifOrientationTag, 6})), want: 6},
+		{name: "big endian", data: withSegments(plain, exifSegment(binary.BigEndian, [2]uint16{exifOrientationTag, 8})), want: 8},
+		{name: "after other segment", data: withSegments(plain, app0, exifSegment(binary.BigEndian, [2]uint16{exifOrientationTag, 3})), want: 3},
+		{name: "after other tag", data: withSegments(plain, exifSegment(binary.LittleEndian, [2]uint16{0x010F, 1}, [2]uint16{exifOrientationTag, 5})), want: 5},
+		{name: "out of range", data: withSegments(plain, exifSegment(binary.LittleEndian, [2]uint16{exifOrientationTag, 9})), want: 1},
+		{name: "zero", data: withSegments(plain, exifSegment(binary.LittleEndian, [2]uint16{exifOrientationTag, 0})), want: 1},
+		{name: "missing tag", data: withSegments(plain, exifSegment(binary.LittleEndian, [2]uint16{0x010F, 6})), want: 1},
+		{name: "truncated", data: withSegments(plain, exifSegment(binary.LittleEndian, [2]uint16{exifOrientationTag, 6}))[:20], want: 1},
+		{name: "not a jpeg", data: []byte("GIF89a"), want: 1},
+		{name: "empty", data: nil, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.want {
+				t.Errorf("jpegOrientation = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// pixel encodes the source position of a pixel in its color.
+func pixel(x, y int) color.RGBA {
+	return color.RGBA{R: uint8(x), G: uint8(y), A: 255}
+}
+
+func TestOrient(t *testing.T) {
+	// The source is a 2x2 image, each displayed pixel is compared with its source position.
+	src := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			src.SetRGBA(x, y, pixel(x, y))
+		}
+	}
+
+	tests := []struct {
+		orientation int
+		// want are the source positions of the displayed top-left, top-right, bottom-left and bottom-right pixels.
+		want [4][2]int
+	}{
+		{orientation: 1, want: [4][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}}},
+		{orientation: 2, want: [4][2]int{{1, 0}, {0, 0}, {1, 1}, {0, 1}}},
+		{orientation: 3, want: [4][2]int{{1, 1}, {0, 1}, {1, 0}, {0, 0}}},
+		{orientation: 4, want: [4][2]int{{0, 1}, {1, 1}, {0, 0}, {1, 0}}},
+		{orientation: 5, want: [4][2]int{{0, 0}, {0, 1}, {1, 0}, {1, 1}}},
+		{orientation: 6, want: [4][2]int{{0, 1}, {0, 0}, {1, 1}, {1, 0}}},
+		{orientation: 7, want: [4][2]int{{1, 1}, {1, 0}, {0, 1}, {0, 0}}},
+		{orientation: 8, want: [4][2]int{{1, 0}, {1, 1}, {0, 0}, {0, 1}}},
+	}
+
+	for _, tt := range tests {
+		dst := orient(src, tt.orientation)
+		for i, pos := range [4][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+			want := pixel(tt.want[i][0], tt.want[i][1])
+			if got := dst.RGBAAt(pos[0], pos[1]); got != want {
+				t.Errorf("orientation %d pixel %v = source %d,%d, want source %d,%d", tt.orientation, pos, got.R, got.G, want.R, want.G)
+			}
+		}
+	}
+}
+
+func TestSquareCrop(t *testing.T) {
+	tests := []struct {
+		name   string
+		bounds image.Rectangle
+		// wantOrigin is the source position of the top-left pixel of the crop.
+		wantOrigin image.Point
+		wantSide   int
+	}{
+		{name: "square", bounds: image.Rect(0, 0, 4, 4), wantOrigin: image.Pt(0, 0), wantSide: 4},
+		{name: "landscape", bounds: image.Rect(0, 0, 6, 4), wantOrigin: image.Pt(1, 0), wantSide: 4},
+		{name: "portrait", bounds: image.Rect(0, 0, 3, 8), wantOrigin: image.Pt(0, 2), wantSide: 3},
+		{name: "odd margin", bounds: image.Rect(0, 0, 7, 4), wantOrigin: image.Pt(1, 0), wantSide: 4},
+		{name: "not zero origin", bounds: image.Rect(10, 20, 16, 24), wantOrigin: image.Pt(11, 20), wantSide: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := image.NewRGBA(tt.bounds)
+			for y := tt.bounds.Min.Y; y < tt.bounds.Max.Y; y++ {
+				for x := tt.bounds.Min.X; x < tt.bounds.Max.X; x++ {
+					src.SetRGBA(x, y, pixel(x, y))
+				}
+			}
+
+			dst := squareCrop(src)
+			if got := dst.Bounds(); got != image.Rect(0, 0, tt.wantSide, tt.wantSide) {
+				t.Fatalf("bounds = %v, want %dx%d", got, tt.wantSide, tt.wantSide)
+			}
+
+			want := pixel(tt.wantOrigin.X, tt.wantOrigin.Y)
+			if got := color.RGBAModel.Convert(dst.At(0, 0)).(color.RGBA); got != want {
+				t.Errorf("top-left pixel = source %d,%d, want source %d,%d", got.R, got.G, want.R, want.G)
+			}
+		})
+	}
+}
+
+func TestResize(t *testing.T) {
+	// The source is a 4x4 image with a white top-left quadrant, a gray pixel at 1,2 and black pixels elsewhere.
+	src := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			c := color.RGBA{A: 255}
+			if x < 2 && y < 2 {
+				c = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+			}
+			if x == 1 && y == 2 {
+				c = color.RGBA{R: 100, G: 100, B: 100, A: 255}
+			}
+			src.SetRGBA(x, y, c)
+		}
+	}
+
+	tests := []struct {
+		name string
+		size int
+		// want are the expected red values of the pixels of the first column.
+		want []uint8
+	}{
+		{name: "same size", size: 4, want: []uint8{255, 255, 0, 0}},
+		{name: "downscale", size: 2, want: []uint8{255, 25}},
+		{name: "single pixel", size: 1, want: []uint8{70}},
+		{name: "upscale", size: 8, want: []uint8{255, 255, 255, 255, 0, 0, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := resize(src, tt.size)
+			if got := dst.Bounds(); got != image.Rect(0, 0, tt.size, tt.size) {
+				t.Fatalf("bounds = %v, want %dx%d", got, tt.size, tt.size)
+			}
+			for y, want := range tt.want {
+				if got := dst.RGBAAt(0, y).R; got != want {
+					t.Errorf("pixel 0,%d red = %d, want %d", y, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSpan(t *testing.T) {
+	tests := []struct {
+		i, side, size      int
+		wantStart, wantEnd int
+	}{
+		{i: 0, side: 512, size: 256, wantStart: 0, wantEnd: 2},
+		{i: 255, side: 512, size: 256, wantStart: 510, wantEnd: 512},
+		{i: 1, side: 300, size: 64, wantStart: 4, wantEnd: 9},
+		{i: 3, side: 2, size: 8, wantStart: 0, wantEnd: 1},
+		{i: 7, side: 2, size: 8, wantStart: 1, wantEnd: 2},
+	}
+
+	for _, tt := range tests {
+		start, end := span(tt.i, tt.side, tt.size)
+		if start != tt.wantStart || end != tt.wantEnd {
+			t.Errorf("span(%d, %d, %d) = %d, %d, want %d, %d", tt.i, tt.side, tt.size, start, end, tt.wantStart, tt.wantEnd)
+		}
+	}
+}
+
+// pngHeader builds a PNG with just its header, of the size provided.
+func pngHeader(width, height uint32) []byte {
+	ihdr := make([]byte, 17)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], width)
+	binary.BigEndian.PutUint32(ihdr[8:], height)
+	ihdr[12], ihdr[13] = 8, 6
+
+	data := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0d")
+	data = append(data, ihdr...)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(ihdr))
+	return append(data, crc...)
+}
+
+func TestGenerate(t *testing.T) {
+	// The source is a landscape image with a red top-left corner of its centered square.
+	src := image.NewRGBA(image.Rect(0, 0, 96, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 96; x++ {
+			c := color.RGBA{B: 255, A: 255}
+			if x >= 16 && x < 48 && y < 32 {
+				c = color.RGBA{R: 255, A: 255}
+			}
+			src.SetRGBA(x, y, c)
+		}
+	}
+	var pngData bytes.Buffer
+	err := png.Encode(&pngData, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jpegData := encodeJPEG(t, src)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		mimeType string
+		wantErr  bool
+		wantMime string
+		// wantRed is the corner of the thumbnails expected to be red.
+		wantRed image.Point
+	}{
+		{name: "png", data: pngData.Bytes(), mimeType: "image/png", wantMime: "image/png", wantRed: image.Pt(0, 0)},
+		{name: "jpeg", data: jpegData, mimeType: "image/jpeg", wantMime: "image/jpeg", wantRed: image.Pt(0, 0)},
+		{
+			name:     "rotated jpeg",
+			data:     withSegments(jpegData, exifSegment(binary.BigEndian, [2]uint16{exifOrientationTag, 6})),
+			mimeType: "image/jpeg",
+			wantMime: "image/jpeg",
+			wantRed:  image.Pt(1, 0),
+		},
+		{name: "unsupported type", data: pngData.Bytes(), mimeType: "image/webp", wantErr: true},
+		{name: "invalid image", data: []byte("not an image"), mimeType: "image/png", wantErr: true},
+		{name: "too large image", data: pngHeader(10000, 10000), mimeType: "image/png", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thumbnails, err := Generate(tt.data, tt.mimeType)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Generate succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Generate failed: %s", err)
+			}
+			if len(thumbnails) != len(Sizes) {
+				t.Fatalf("len(thumbnails) = %d, want %d", len(thumbnails), len(Sizes))
+			}
+
+			for i, th := range thumbnails {
+				if th.Width != Sizes[i] || th.Height != Sizes[i] || th.MimeType != tt.wantMime {
+					t.Errorf("thumbnail %s = %dx%d %s, want %dx%d %s", th.Name, th.Width, th.Height, th.MimeType, Sizes[i], Sizes[i], tt.wantMime)
+				}
+
+				img, _, err := image.Decode(bytes.NewReader(th.Data))
+				if err != nil {
+					t.Fatalf("failed to decode thumbnail %s: %s", th.Name, err)
+				}
+				if img.Bounds().Dx() != Sizes[i] || img.Bounds().Dy() != Sizes[i] {
+					t.Errorf("thumbnail %s bounds = %v", th.Name, img.Bounds())
+				}
+
+				// The corner pixel is checked with a margin for the JPEG compression.
+				last := Sizes[i] - 1
+				corner := image.Pt(tt.wantRed.X*last, tt.wantRed.Y*last)
+				r, _, b, _ := img.At(corner.X, corner.Y).RGBA()
+				if r>>8 < 200 || b>>8 > 55 {
+					t.Errorf("thumbnail %s pixel %v = red %d blue %d, want red", th.Name, corner, r>>8, b>>8)
+				}
+			}
+		})
+	}
+}